@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"acacia/core/auth"   // Import the auth package for API key management
+	"acacia/core/config" // Import the config package to find the configured key store
+	"fmt"                // Import fmt for formatted I/O operations
+	"strings"            // Import strings for joining roles
+	"text/tabwriter"     // Import tabwriter for aligned table output
+	"time"               // Import time for TTLs, grace periods and timestamps
+
+	"github.com/spf13/cobra" // Import Cobra for building powerful modern CLI applications
+)
+
+var (
+	apikeyStore string        // apikeyStore is the key store file, defaults to auth.api_keys_file.
+	apikeyJSON  bool          // apikeyJSON prints raw JSON instead of a table.
+	apikeyName  string        // apikeyName is the label of a new key.
+	apikeyRoles []string      // apikeyRoles are the roles granted to a new key.
+	apikeyTTL   time.Duration // apikeyTTL is the lifetime of a new key, zero for none.
+	apikeyGrace time.Duration // apikeyGrace is how long a rotated key keeps working.
+	apikeyAll   bool          // apikeyAll also lists expired and revoked keys.
+)
+
+// init function is called before main. It sets up the Cobra commands and flags.
+func init() {
+	rootCmd.AddCommand(apikeyCmd)
+	apikeyCmd.PersistentFlags().StringVar(&apikeyStore, "store", "", "API key store file (default: auth.api_keys_file from the config)")
+	apikeyCreateCmd.Flags().StringVar(&apikeyName, "name", "", "human-readable label for the key")
+	apikeyCreateCmd.Flags().StringSliceVar(&apikeyRoles, "role", nil, "role granted to the key (repeatable)")
+	apikeyCreateCmd.Flags().DurationVar(&apikeyTTL, "ttl", 0, "lifetime of the key, e.g. 2160h (default: no expiry)")
+	apikeyRotateCmd.Flags().DurationVar(&apikeyGrace, "grace", 24*time.Hour, "how long the old key keeps working")
+	apikeyListCmd.Flags().BoolVar(&apikeyJSON, "json", false, "print the result as JSON")
+	apikeyListCmd.Flags().BoolVar(&apikeyAll, "all", false, "include expired and revoked keys")
+	apikeyCmd.AddCommand(apikeyCreateCmd) // acacia apikey create
+	apikeyCmd.AddCommand(apikeyListCmd)   // acacia apikey list
+	apikeyCmd.AddCommand(apikeyRotateCmd) // acacia apikey rotate
+	apikeyCmd.AddCommand(apikeyRevokeCmd) // acacia apikey revoke
+}
+
+// apikeyCmd is the parent command for managing service account API keys.
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage API keys for service accounts",
+}
+
+// apikeyCreateCmd issues a new key and prints its secret once.
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create <service-account>",
+	Short: "Issue a new API key for a service account",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := apikeyManager()
+		if err != nil {
+			return err
+		}
+		secret, key, err := manager.Create(cmd.Context(), auth.APIKeySpec{
+			Name:           apikeyName,
+			ServiceAccount: args[0],
+			Roles:          apikeyRoles,
+			TTL:            apikeyTTL,
+		})
+		if err != nil {
+			return err
+		}
+		printIssuedKey(cmd, secret, key)
+		return nil
+	},
+}
+
+// apikeyListCmd lists the stored keys without their secrets.
+var apikeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := apikeyManager()
+		if err != nil {
+			return err
+		}
+		keys, err := manager.List(cmd.Context())
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if !apikeyAll {
+			active := keys[:0]
+			for _, key := range keys {
+				if key.Active(now) {
+					active = append(active, key)
+				}
+			}
+			keys = active
+		}
+		if apikeyJSON {
+			return printJSON(cmd, keys)
+		}
+		if len(keys) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "no API keys")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tACCOUNT\tROLES\tEXPIRES\tLAST USED\tSTATUS")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, orDash(key.Name), key.ServiceAccount,
+				orDash(strings.Join(key.Roles, ",")), formatTime(key.ExpiresAt), formatTime(key.LastUsedAt), keyStatus(key, now))
+		}
+		return w.Flush()
+	},
+}
+
+// apikeyRotateCmd replaces a key, keeping the old one valid for the grace period.
+var apikeyRotateCmd = &cobra.Command{
+	Use:   "rotate <id>",
+	Short: "Replace an API key; the old key keeps working for --grace",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := apikeyManager()
+		if err != nil {
+			return err
+		}
+		secret, key, err := manager.Rotate(cmd.Context(), args[0], apikeyGrace)
+		if err != nil {
+			return err
+		}
+		printIssuedKey(cmd, secret, key)
+		fmt.Fprintf(cmd.OutOrStdout(), "Key %s keeps working for %s, or until it expires if sooner.\n", args[0], apikeyGrace)
+		return nil
+	},
+}
+
+// apikeyRevokeCmd disables a key immediately.
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API key immediately",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := apikeyManager()
+		if err != nil {
+			return err
+		}
+		if err := manager.Revoke(cmd.Context(), args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Key %s revoked.\n", args[0])
+		return nil
+	},
+}
+
+// apikeyManager opens the key store named by --store or the configuration.
+func apikeyManager() (*auth.APIKeyManager, error) {
+	path := apikeyStore
+	if path == "" {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return nil, fmt.Errorf("load config: %w", err)
+		}
+		path = cfg.Auth.APIKeysFile
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no API key store: pass --store or set auth.api_keys_file")
+	}
+	store, err := auth.NewFileKeyStore(path)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewAPIKeyManager(store, auth.APIKeyOptions{}), nil
+}
+
+// printIssuedKey prints a newly issued key. The secret cannot be shown again.
+func printIssuedKey(cmd *cobra.Command, secret string, key *auth.APIKey) {
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Created key %s for %s (roles: %s, expires: %s).\n", key.ID, key.ServiceAccount,
+		orDash(strings.Join(key.Roles, ",")), formatTime(key.ExpiresAt))
+	fmt.Fprintln(out, "Store the key now, it will not be shown again:")
+	fmt.Fprintln(out, secret)
+}
+
+// formatTime renders t as RFC 3339, or "-" if it is zero.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// keyStatus describes whether key can still be used.
+func keyStatus(key *auth.APIKey, now time.Time) string {
+	switch {
+	case !key.RevokedAt.IsZero():
+		return "revoked"
+	case !key.Active(now):
+		return "expired"
+	case key.ReplacedBy != "":
+		return "rotated to " + key.ReplacedBy
+	default:
+		return "active"
+	}
+}
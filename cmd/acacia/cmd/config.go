@@ -1,16 +1,52 @@
 package cmd
 
 import (
+	"acacia/cmd/acacia/internal/admin"
+	"acacia/cmd/acacia/internal/pluginloader"
 	"acacia/core/config"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configValidateCmd)
 	configCmd.AddCommand(configGenerateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configReloadCmd)
+	configCmd.AddCommand(configKeygenCmd)
+	configCmd.AddCommand(configSignCmd)
+	configCmd.AddCommand(configMigrateCmd)
+
+	configValidateCmd.Flags().StringVar(&configFile, "file", "", "base config file to validate (default: searched like serve does)")
+	configValidateCmd.Flags().StringArrayVar(&configOverrides, "set", nil, "override a setting, e.g. --set modules.chat.workers=8 (repeatable)")
+	configShowCmd.Flags().StringVar(&configFile, "file", "", "base config file (default: searched like serve does)")
+	configShowCmd.Flags().StringArrayVar(&configOverrides, "set", nil, "override a setting, e.g. --set modules.chat.workers=8 (repeatable)")
+	configShowCmd.Flags().BoolVar(&configShowEffective, "effective", false, "print the merged settings with the source of each value")
+	configValidateCmd.Flags().StringVar(&configPluginDir, "plugins", "build/plugins", "plugin directory whose modules and gateways declare typed configs")
+	configSchemaCmd.Flags().StringVar(&configPluginDir, "plugins", "build/plugins", "plugin directory whose modules and gateways declare typed configs")
+	configSchemaCmd.Flags().StringVarP(&configSchemaOutput, "output", "o", "", "write the schema to this file instead of stdout")
+	configReloadCmd.Flags().StringVar(&configReloadSocket, "socket", admin.DefaultSocketPath, "admin socket of the running server")
+	configReloadCmd.Flags().BoolVar(&configReloadJSON, "json", false, "print the reload report as JSON")
+	configKeygenCmd.Flags().StringVar(&configKeyFile, "key-file", "config-signing.key", "file to write the private key to")
+	configSignCmd.Flags().StringVar(&configKeyFile, "key-file", "config-signing.key", "file holding the private key")
+	configMigrateCmd.Flags().StringVar(&configFile, "file", "", "base config file whose layers are migrated (default: searched like serve does)")
+	configMigrateCmd.Flags().StringVar(&configPluginDir, "plugins", "build/plugins", "plugin directory whose modules and gateways declare config migrations")
+	configMigrateCmd.Flags().BoolVar(&configMigrateDryRun, "dry-run", false, "print the changes without writing the files")
+	configSignCmd.Flags().StringVarP(&configSignOutput, "output", "o", "", "write the signature to this file instead of stdout (e.g. <file>.sig)")
 
 	configGenerateCmd.Flags().Bool("from-modules", false, "Generate complete config from all module defaults")
 	configGenerateCmd.Flags().Bool("update-modules", false, "Regenerate default configs for existing modules")
@@ -22,19 +58,201 @@ var configCmd = &cobra.Command{
 	Short: "Manage configuration",
 }
 
+var (
+	configFile      string   // configFile is the base config file to operate on; empty searches for it like serve does.
+	configOverrides []string // configOverrides are --set path=value settings applied over every layer.
+	configPluginDir string   // configPluginDir holds the plugins whose typed configs are validated.
+
+	configShowEffective bool // configShowEffective prints the merged settings instead of the layers.
+
+	configSchemaOutput string // configSchemaOutput is where `config schema` writes; empty for stdout.
+
+	configReloadSocket string // configReloadSocket is the admin socket of the server to reload.
+	configReloadJSON   bool   // configReloadJSON prints the reload report as JSON.
+
+	configKeyFile    string // configKeyFile holds the private key that signs remote config documents.
+	configSignOutput string // configSignOutput is where `config sign` writes the signature; empty for stdout.
+
+	configMigrateDryRun bool // configMigrateDryRun prints the migration diffs without writing the files.
+)
+
 var configValidateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate the configuration file",
+	Long: `Validate the configuration file against the configuration schema (see "acacia config
+schema"), then decode the typed configuration of every module and gateway in the plugin
+directory. All problems are reported at once with their file and line.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		_, err := config.LoadConfig()
+		cfg, err := loadConfigFile()
 		if err != nil {
 			return fmt.Errorf("configuration validation failed: %w", err)
 		}
+		plugins, err := openConfigPlugins()
+		if err != nil {
+			return err
+		}
+		modules, gateways := plugins.TypedConfigs()
+
+		// The schema catches mistakes anywhere in the file; decoding catches what the schema
+		// cannot express, such as duration bounds. Report each value once.
+		var problems config.ValidationErrors
+		seen := make(map[string]bool)
+		for _, err := range []error{
+			cfg.ValidateSchema(config.GenerateSchema(modules, gateways)),
+			cfg.ValidateComponents(modules, gateways),
+		} {
+			var errs config.ValidationErrors
+			if !errors.As(err, &errs) {
+				if err != nil {
+					return fmt.Errorf("configuration validation failed: %w", err)
+				}
+				continue
+			}
+			for _, e := range errs {
+				if !seen[e.Path] {
+					seen[e.Path] = true
+					problems = append(problems, e)
+				}
+			}
+		}
+		if len(problems) > 0 {
+			for _, e := range problems {
+				fmt.Fprintln(os.Stderr, e)
+			}
+			return fmt.Errorf("configuration validation failed: %d problem(s)", len(problems))
+		}
 		fmt.Println("Configuration is valid.")
 		return nil
 	},
 }
 
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration",
+	Long: `Print the JSON Schema of config.yaml, including the typed configuration of every module
+and gateway in the plugin directory. Point your editor's YAML language server at it for
+completion and inline errors, or validate configuration files with it in CI.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins, err := openConfigPlugins()
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(config.GenerateSchema(plugins.TypedConfigs()), "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal schema: %w", err)
+		}
+		data = append(data, '\n')
+		if configSchemaOutput == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(configSchemaOutput, data, 0644); err != nil {
+			return fmt.Errorf("write schema: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Schema written to %s.\n", configSchemaOutput)
+		return nil
+	},
+}
+
+// openConfigPlugins instantiates the components in the plugin directory so that their typed
+// configs are known. A missing directory leaves only the core settings.
+func openConfigPlugins() (*pluginloader.Plugins, error) {
+	plugins, err := pluginloader.Open(configPluginDir, zap.NewNop())
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		fmt.Fprintf(os.Stderr, "Plugin directory %s not found, covering core settings only.\n", configPluginDir)
+		return &pluginloader.Plugins{}, nil
+	case err != nil:
+		return nil, fmt.Errorf("load plugins: %w", err)
+	}
+	return plugins, nil
+}
+
+// loadConfigFile loads the layered config based on --file, or on the file serve would find,
+// with the --set overrides.
+func loadConfigFile() (*config.Config, error) {
+	return config.Load(config.LoadOptions{File: configFile, Overrides: configOverrides})
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the configuration layers or the merged settings",
+	Long: `Show the layers merged into the configuration, in increasing precedence: the base file,
+its conf.d fragments, the overlay for the environment and the --set overrides. Environment
+variables and defaults apply too, but are not files.
+
+With --effective, print the merged settings as YAML instead, each annotated with where its
+value comes from. Secret references are shown, not their values.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if !configShowEffective {
+			layers := cfg.Layers()
+			if len(layers) == 0 {
+				fmt.Fprintln(out, "no config files, using defaults and environment variables")
+				return nil
+			}
+			for i, layer := range layers {
+				fmt.Fprintf(out, "%d. %s\n", i+1, layer)
+			}
+			return nil
+		}
+
+		doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{effectiveNode(cfg, cfg.Effective(), "")}}
+		encoder := yaml.NewEncoder(out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return fmt.Errorf("print settings: %w", err)
+		}
+		return encoder.Close()
+	},
+}
+
+// effectiveNode renders the settings under path as YAML, with the origin of each value as a
+// comment. Keys are sorted; lists and scalars are values, so a list has a single origin.
+func effectiveNode(cfg *config.Config, value interface{}, path string) *yaml.Node {
+	settings, ok := value.(map[string]interface{})
+	if !ok {
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			node = &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(value)}
+		}
+		if node.Kind == yaml.SequenceNode && len(node.Content) > 0 && node.Content[0].Kind == yaml.ScalarNode {
+			node.Style = yaml.FlowStyle // Short lists on one line, next to their origin
+		}
+		return node
+	}
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range keys {
+		child := path + "." + key
+		if path == "" {
+			child = key
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+		valueNode := effectiveNode(cfg, settings[key], child)
+		if _, nested := settings[key].(map[string]interface{}); !nested {
+			comment := "# " + cfg.Origin(child).String()
+			if valueNode.Kind == yaml.ScalarNode || valueNode.Style == yaml.FlowStyle {
+				valueNode.LineComment = comment
+			} else {
+				keyNode.LineComment = comment
+			}
+		}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node
+}
+
 var configGenerateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Generate configuration files",
@@ -104,3 +322,197 @@ func generateMinimalConfig(cmd *cobra.Command) error {
 	fmt.Println("Minimal configuration generated successfully.")
 	return nil
 }
+
+var configReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Reload the configuration of a running server",
+	Long: `Ask a running server, through its admin socket, to reload its configuration files. The
+new configuration is validated first; if it is invalid, nothing is applied and the reasons are
+printed. Otherwise the changes and the components that accepted or rejected them are listed.
+Sending SIGHUP to the server also reloads it, with the outcome in the server's log.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+		defer cancel()
+		var report config.ReloadReport
+		if err := admin.Call(ctx, configReloadSocket, admin.Request{Command: "config.reload"}, &report); err != nil {
+			return err
+		}
+		if configReloadJSON {
+			return printJSON(cmd, report)
+		}
+
+		out := cmd.OutOrStdout()
+		if len(report.Changes) == 0 {
+			fmt.Fprintln(out, "Configuration reloaded, nothing changed.")
+			return nil
+		}
+		fmt.Fprintf(out, "Configuration reloaded, %d change(s):\n", len(report.Changes))
+		for _, c := range report.Changes {
+			fmt.Fprintf(out, "  %s\n", c)
+		}
+		if len(report.Components) > 0 {
+			fmt.Fprintln(out)
+			w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "COMPONENT\tRESULT")
+			for _, c := range report.Components {
+				result := "accepted"
+				if c.Error != "" {
+					result = "rejected: " + c.Error
+				}
+				fmt.Fprintf(w, "%s\t%s\n", c.Component, result)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		if rejected := report.Rejected(); len(rejected) > 0 {
+			return fmt.Errorf("%d component(s) rejected the new configuration", len(rejected))
+		}
+		return nil
+	},
+}
+
+var configKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a key pair for signing remote configuration",
+	Long: `Generate an Ed25519 key pair for remote configuration documents. The private key is written
+to --key-file, readable by its owner only; keep it where documents are published. The public key
+is printed, to be passed to "acacia serve --remote-config-key".`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := os.Stat(configKeyFile); err == nil {
+			return fmt.Errorf("%s already exists", configKeyFile)
+		}
+		public, private, err := config.GenerateSigningKey()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(configKeyFile, []byte(private+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to write private key: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Private key written to %s\nPublic key: %s\n", configKeyFile, public)
+		return nil
+	},
+}
+
+var configSignCmd = &cobra.Command{
+	Use:   "sign <file>",
+	Short: "Sign a remote configuration document",
+	Long: `Sign a configuration document with the private key from "acacia config keygen". Publish the
+signature along with the document: in the X-Acacia-Signature header or at the document's URL
+followed by .sig for HTTP, or under the document's key followed by .sig in a key/value store.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := os.ReadFile(configKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read private key: %w", err)
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		sig, err := config.SignDocument(strings.TrimSpace(string(key)), data)
+		if err != nil {
+			return err
+		}
+		if configSignOutput == "" {
+			fmt.Fprintln(cmd.OutOrStdout(), sig)
+			return nil
+		}
+		return os.WriteFile(configSignOutput, []byte(sig+"\n"), 0644)
+	},
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate [file...]",
+	Short: "Upgrade configuration files to the current version",
+	Long: `Upgrade configuration files written for an older version of Acacia, or of its modules and
+gateways, and print the changes as a diff. Each file is rewritten in place, after being copied to
+<file>.bak. YAML files keep their comments; JSON files are rewritten with sorted keys.
+
+Without arguments, the files the configuration is loaded from are migrated: the base file, its
+conf.d fragments and the overlay of the selected environment. Name other files, such as the
+overlays of other environments, as arguments. Old files also work without migrating them, as
+they are upgraded in memory when loaded, but their sections may then differ from the docs.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args
+		if len(files) == 0 {
+			cfg, err := loadConfigFile()
+			if err != nil {
+				return err
+			}
+			for _, layer := range cfg.Layers() {
+				if layer != config.OverrideSource {
+					files = append(files, layer)
+				}
+			}
+			if len(files) == 0 {
+				return errors.New("no config file found; name the files to migrate")
+			}
+		}
+		plugins, err := openConfigPlugins()
+		if err != nil {
+			return err
+		}
+		modules, gateways := plugins.Migrations()
+
+		out := cmd.OutOrStdout()
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			migrated, report, err := config.MigrateDocument(file, data, modules, gateways)
+			if err != nil {
+				return err
+			}
+			if !report.Changed {
+				fmt.Fprintf(out, "%s is up to date (version %d).\n", file, report.To)
+				continue
+			}
+			switch {
+			case report.From != report.To:
+				fmt.Fprintf(out, "%s: version %d -> %d\n", file, report.From, report.To)
+			case len(report.Applied) == 0:
+				fmt.Fprintf(out, "%s: recording version %d\n", file, report.To)
+			default:
+				fmt.Fprintf(out, "%s:\n", file)
+			}
+			for _, step := range report.Applied {
+				fmt.Fprintf(out, "  %s\n", step)
+			}
+			fmt.Fprint(out, unifiedDiff(file, file, data, migrated))
+			if configMigrateDryRun {
+				continue
+			}
+			if err := writeMigrated(file, data, migrated); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Migrated %s, previous version saved to %s.\n", file, file+".bak")
+		}
+		return nil
+	},
+}
+
+// writeMigrated saves the original content of file to <file>.bak, then replaces file with the
+// migrated content. The replacement is renamed into place, so that a running server watching
+// the file never reads it half written.
+func writeMigrated(file string, original, migrated []byte) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file+".bak", original, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("back up %s: %w", file, err)
+	}
+	tmp := file + ".migrating"
+	if err := os.WriteFile(tmp, migrated, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("write %s: %w", file, err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write %s: %w", file, err)
+	}
+	return nil
+}
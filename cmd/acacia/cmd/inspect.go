@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"acacia/cmd/acacia/internal/admin" // Import the admin package to talk to the running server
+	"acacia/core/registry"             // Import the registry package for the introspection types
+	"context"                          // Import context for the request timeout
+	"encoding/json"                    // Import json for --json output
+	"fmt"                              // Import fmt for formatted I/O operations
+	"sort"                             // Import sort for deterministic metadata output
+	"strings"                          // Import strings for metadata formatting
+	"text/tabwriter"                   // Import tabwriter for aligned table output
+	"time"                             // Import time for timeouts and timestamps
+
+	"github.com/spf13/cobra" // Import Cobra for building powerful modern CLI applications
+)
+
+var (
+	inspectSocket string // inspectSocket is the admin socket of the server to inspect.
+	inspectJSON   bool   // inspectJSON prints raw JSON instead of a table.
+)
+
+// init function is called before main. It sets up the Cobra commands and flags.
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.PersistentFlags().StringVar(&inspectSocket, "socket", admin.DefaultSocketPath, "admin socket of the running server")
+	inspectCmd.PersistentFlags().BoolVar(&inspectJSON, "json", false, "print the result as JSON")
+	inspectCmd.AddCommand(inspectServicesCmd) // acacia inspect services
+	inspectCmd.AddCommand(inspectGatewaysCmd) // acacia inspect gateways
+}
+
+// inspectCmd is the parent command for inspecting a running Acacia server.
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Inspect the service registry of a running Acacia server",
+}
+
+// inspectServicesCmd lists the services registered in the running server.
+var inspectServicesCmd = &cobra.Command{
+	Use:   "services [name]",
+	Short: "List registered services, optionally only those with the given name",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var services []registry.ServiceInfo
+		if err := callAdmin(cmd, "services", &services); err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			filtered := services[:0:0]
+			for _, s := range services {
+				if s.Name == args[0] {
+					filtered = append(filtered, s)
+				}
+			}
+			services = filtered
+		}
+		if inspectJSON {
+			return printJSON(cmd, services)
+		}
+		if len(services) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "no services registered")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tMODULE\tVERSION\tTYPE\tREGISTERED\tMETADATA")
+		for _, s := range services {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.ModuleName, orDash(s.Version), s.Type, s.RegisteredAt.Format(time.RFC3339), formatMetadata(s.Metadata))
+		}
+		return w.Flush()
+	},
+}
+
+// inspectGatewaysCmd lists the gateways registered in the running server.
+var inspectGatewaysCmd = &cobra.Command{
+	Use:   "gateways",
+	Short: "List registered gateways",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var gateways []registry.GatewayInfo
+		if err := callAdmin(cmd, "gateways", &gateways); err != nil {
+			return err
+		}
+		if inspectJSON {
+			return printJSON(cmd, gateways)
+		}
+		if len(gateways) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "no gateways registered")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTYPE\tREGISTERED")
+		for _, g := range gateways {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", g.Name, g.Type, g.RegisteredAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	},
+}
+
+// callAdmin runs an admin command against the server's socket with a short timeout.
+func callAdmin(cmd *cobra.Command, command string, result interface{}) error {
+	ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
+	defer cancel()
+	return admin.Call(ctx, inspectSocket, admin.Request{Command: command}, result)
+}
+
+// printJSON writes v as indented JSON.
+func printJSON(cmd *cobra.Command, v interface{}) error {
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// orDash returns s, or "-" if it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatMetadata renders metadata as sorted key=value pairs.
+func formatMetadata(md map[string]string) string {
+	if len(md) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(md))
+	for k, v := range md {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"acacia/core/config" // Import the config package for the vault
+	"bufio"              // Import bufio to read a secret from stdin
+	"fmt"                // Import fmt for formatted I/O operations
+	"io"                 // Import io to read a secret from stdin
+	"os"                 // Import os to read the key file
+	"strings"            // Import strings to trim the secret and key
+
+	"github.com/spf13/cobra" // Import Cobra for building powerful modern CLI applications
+)
+
+var (
+	secretVault   string // secretVault is the vault file, defaults to secrets.vault_file.
+	secretKeyFile string // secretKeyFile holds the vault key, defaults to secrets.vault_key.
+)
+
+// init function is called before main. It sets up the Cobra commands and flags.
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.PersistentFlags().StringVar(&secretVault, "vault", "", "vault file (default: secrets.vault_file from the config)")
+	secretCmd.PersistentFlags().StringVar(&secretKeyFile, "key-file", "", "file holding the vault key (default: secrets.vault_key from the config)")
+	secretCmd.AddCommand(secretKeygenCmd) // acacia secret keygen
+	secretCmd.AddCommand(secretSetCmd)    // acacia secret set
+	secretCmd.AddCommand(secretListCmd)   // acacia secret list
+	secretCmd.AddCommand(secretDeleteCmd) // acacia secret delete
+}
+
+// secretCmd is the parent command for managing the local encrypted vault.
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage secrets in the local encrypted vault",
+	Long: `Manage secrets in the local encrypted vault. Configuration values refer to them as
+${vault:<name>}, and they are decrypted when the configuration is loaded.`,
+}
+
+// secretKeygenCmd prints a new vault key.
+var secretKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Print a new random vault key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := config.GenerateVaultKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), key)
+		return nil
+	},
+}
+
+// secretSetCmd stores a secret read from stdin, so that it stays out of the shell history.
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret read from stdin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := openVault()
+		if err != nil {
+			return err
+		}
+		if f, ok := cmd.InOrStdin().(*os.File); ok {
+			if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Enter the value of %s: ", args[0])
+			}
+		}
+		value, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("read secret: %w", err)
+		}
+		value = strings.TrimRight(value, "\r\n")
+		if value == "" {
+			return fmt.Errorf("empty secret")
+		}
+		if err := vault.Set(args[0], value); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Secret %s stored, refer to it as ${vault:%s}.\n", args[0], args[0])
+		return nil
+	},
+}
+
+// secretListCmd lists the names of the stored secrets, never their values.
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of the stored secrets",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := openVault()
+		if err != nil {
+			return err
+		}
+		names := vault.Names()
+		if len(names) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "no secrets")
+			return nil
+		}
+		for _, name := range names {
+			fmt.Fprintln(cmd.OutOrStdout(), name)
+		}
+		return nil
+	},
+}
+
+// secretDeleteCmd removes a secret.
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Remove a secret from the vault",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := openVault()
+		if err != nil {
+			return err
+		}
+		if err := vault.Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Secret %s deleted.\n", args[0])
+		return nil
+	},
+}
+
+// openVault opens the vault named by the flags or the configuration.
+func openVault() (*config.Vault, error) {
+	path, key := secretVault, ""
+	if secretKeyFile != "" {
+		data, err := os.ReadFile(secretKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read vault key: %w", err)
+		}
+		key = string(data)
+	}
+	if path == "" || key == "" {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return nil, fmt.Errorf("load config: %w", err)
+		}
+		if path == "" {
+			path = cfg.Secrets.VaultFile
+		}
+		if key == "" {
+			key = cfg.Secrets.VaultKey
+		}
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no vault: pass --vault or set secrets.vault_file")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("no vault key: pass --key-file or set secrets.vault_key")
+	}
+	return config.OpenVault(path, key)
+}
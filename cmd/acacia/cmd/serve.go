@@ -1,18 +1,23 @@
 package cmd
 
 import (
+	"acacia/cmd/acacia/internal/admin"        // Import the admin package for the local admin socket
 	"acacia/cmd/acacia/internal/pluginloader" // Import the pluginloader package
 
-	"acacia/core/auth"    // Import the auth package for AccessController
-	"acacia/core/config"  // Import the configuration package
-	"acacia/core/kernel"  // Import the kernel package for the Kernel interface
-	"acacia/core/logger"  // Import the logging package
-	"acacia/core/metrics" // Import the metrics package
+	"acacia/core/auth"          // Import the auth package for AccessController
+	"acacia/core/config"        // Import the configuration package
+	"acacia/core/config/remote" // Import the remote configuration sources
+	"acacia/core/kernel"        // Import the kernel package for the Kernel interface
+	"acacia/core/logger"        // Import the logging package
+	"acacia/core/metrics"       // Import the metrics package
 
 	"context"   // Import context for managing request-scoped values, cancellation signals, and deadlines
+	"fmt"       // For formatting errors
 	"log"       // Import log for simple logging
+	"net/url"   // For parsing remote config addresses
 	"os"        // For operating system functionalities, like signal handling
 	"os/signal" // For listening to OS signals
+	"strings"   // For splitting remote config addresses
 	"syscall"   // For specific system calls, like SIGINT and SIGTERM
 	"time"      // Import time for duration and timeout functionalities
 
@@ -24,6 +29,48 @@ import (
 func init() {
 	// Add the 'serveCmd' as a subcommand of the 'rootCmd'.
 	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveAdminSocket, "admin-socket", admin.DefaultSocketPath, "path of the local admin socket (empty to disable)")
+	serveCmd.Flags().StringVar(&serveConfigFile, "config", "", "base config file (default: config.yaml in ., ./configs or /etc/acacia)")
+	serveCmd.Flags().StringArrayVar(&serveOverrides, "set", nil, "override a setting, e.g. --set modules.chat.workers=8 (repeatable)")
+	serveCmd.Flags().StringArrayVar(&serveRemoteConfigs, "remote-config", nil, "remote config layer, an http(s):// URL or etcd://host:port/key (repeatable)")
+	serveCmd.Flags().StringVar(&serveRemoteKey, "remote-config-key", "", "base64 Ed25519 public key remote config documents must be signed with")
+	serveCmd.Flags().DurationVar(&serveRemotePoll, "remote-poll-interval", remote.DefaultPollInterval, "how often HTTP remote configs are checked for changes")
+}
+
+var (
+	serveAdminSocket string   // serveAdminSocket is where the admin socket listens for CLI commands such as `acacia inspect`.
+	serveConfigFile  string   // serveConfigFile is the base config file; its fragments and environment overlay are merged into it.
+	serveOverrides   []string // serveOverrides are --set path=value settings applied over every other layer.
+
+	serveRemoteConfigs []string      // serveRemoteConfigs are the addresses of the remote layers, merged over the files in order.
+	serveRemoteKey     string        // serveRemoteKey, if set, is the public key remote documents must be signed with.
+	serveRemotePoll    time.Duration // serveRemotePoll is the polling interval of HTTP remote layers.
+)
+
+// remoteSources creates the config sources for the --remote-config addresses.
+func remoteSources() ([]config.Source, error) {
+	var sources []config.Source
+	for _, address := range serveRemoteConfigs {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote config %q: %w", address, err)
+		}
+		switch u.Scheme {
+		case "http", "https":
+			src := remote.NewHTTPSource(address)
+			src.Interval = serveRemotePoll
+			sources = append(sources, src)
+		case "etcd":
+			key := strings.TrimPrefix(u.Path, "/")
+			if u.Host == "" || key == "" {
+				return nil, fmt.Errorf("invalid remote config %q: expected etcd://host:port/key", address)
+			}
+			sources = append(sources, remote.NewKVSource(remote.NewEtcdKV("http://"+u.Host), "etcd://"+u.Host, key))
+		default:
+			return nil, fmt.Errorf("invalid remote config %q: unsupported scheme %q", address, u.Scheme)
+		}
+	}
+	return sources, nil
 }
 
 // serveCmd is the Cobra command for running the Acacia server.
@@ -34,25 +81,98 @@ var serveCmd = &cobra.Command{
 		// Create context for logging (with component name)
 		ctx := context.WithValue(cmd.Context(), "componentName", "serve")
 
-		// Load application configuration from its source (e.g., file, environment variables).
-		cfg, err := config.LoadConfig()
+		// Load application configuration from its sources (e.g., files, remote layers, environment
+		// variables), reloading it when its files or remote layers change.
+		sources, err := remoteSources()
+		if err != nil {
+			return err
+		}
+		configs, err := config.NewManager(config.LoadOptions{
+			File:      serveConfigFile,
+			Overrides: serveOverrides,
+			Sources:   sources,
+			SourceKey: serveRemoteKey,
+			Watch:     true,
+		})
 		if err != nil {
 			logger.Fatal(ctx, "Failed to load configuration", zap.Error(err))
 		}
-		logger.Info(ctx, "Configuration loaded successfully", zap.Any("config", cfg))
-
-		// Create an RBAC provider from the loaded configuration.
-		rbacProvider := auth.NewConfigRBACProvider(cfg.Auth.Roles)
+		defer configs.Close()
+		cfg := configs.Current()
+		logger.Info(ctx, "Configuration loaded successfully", zap.Any("config", cfg)) // Secrets are redacted by Config.MarshalJSON
+
+		// Create an RBAC provider from the role file if one is configured, otherwise from the
+		// roles in the config. Either way, role changes take effect without a restart.
+		var rbacProvider auth.RBACProvider
+		if cfg.Auth.RolesFile != "" {
+			if len(cfg.Auth.Roles) > 0 {
+				logger.Warn(ctx, "auth.roles is ignored because auth.roles_file is set", zap.String("rolesFile", cfg.Auth.RolesFile))
+			}
+			fileProvider, err := auth.NewFileRBACProvider(cfg.Auth.RolesFile)
+			if err != nil {
+				logger.Fatal(ctx, "Failed to load role file", zap.Error(err))
+			}
+			if err := fileProvider.Watch(func(err error) {
+				if err != nil {
+					logger.Error(ctx, "Rejected role file change, keeping previous roles", zap.Error(err))
+					return
+				}
+				logger.Info(ctx, "Roles reloaded", zap.String("rolesFile", cfg.Auth.RolesFile))
+			}); err != nil {
+				logger.Warn(ctx, "Role file changes will not be picked up", zap.Error(err))
+			}
+			defer fileProvider.Close()
+			rbacProvider = fileProvider
+		} else {
+			configProvider := auth.NewConfigRBACProvider(cfg.Auth.Roles)
+			configs.Subscribe(func(c *config.Config, changes config.ChangeSet) []config.ComponentResult {
+				if !changes.Changed("auth.roles") {
+					return nil
+				}
+				if err := configProvider.SetRoles(c.Auth.Roles); err != nil {
+					logger.Error(ctx, "Rejected role changes, keeping previous roles", zap.Error(err))
+					return []config.ComponentResult{{Component: "auth.roles", Error: err.Error()}}
+				}
+				logger.Info(ctx, "Roles reloaded from configuration", zap.Int("count", len(c.Auth.Roles)))
+				return []config.ComponentResult{{Component: "auth.roles"}}
+			})
+			rbacProvider = configProvider
+		}
 
-		// Create a DefaultAccessController instance with the provider.
-		var accessController auth.AccessController = auth.NewDefaultAccessController(rbacProvider)
+		// Create a DefaultAccessController instance with the provider, recording decisions to the
+		// audit trail if one is configured.
+		acOptions := auth.AccessControllerOptions{CacheSize: cfg.Auth.DecisionCacheSize}
+		if audit := cfg.Auth.Audit; audit != nil && audit.File != "" {
+			auditFile, err := os.OpenFile(audit.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+			if err != nil {
+				logger.Fatal(ctx, "Failed to open authorization audit file", zap.Error(err))
+			}
+			defer auditFile.Close()
+			acOptions.Audit = auth.NewJSONAuditSink(auditFile)
+			acOptions.AuditAllowed = audit.Allowed
+			for _, skip := range audit.Skip {
+				acOptions.AuditSkip = append(acOptions.AuditSkip, auth.Permission(skip))
+			}
+			logger.Info(ctx, "Authorization audit enabled", zap.String("file", audit.File), zap.Bool("allowed", audit.Allowed))
+		}
+		var accessController auth.AccessController = auth.NewDefaultAccessControllerWithOptions(rbacProvider, acOptions)
+
+		// Layer attribute-based policies over RBAC if any are configured.
+		if len(cfg.Auth.Policies) > 0 {
+			policies, err := auth.NewPolicyEngine(cfg.Auth.Policies)
+			if err != nil {
+				logger.Fatal(ctx, "Failed to load access policies", zap.Error(err))
+			}
+			accessController = auth.WithPolicies(accessController, policies)
+			logger.Info(ctx, "Access policies loaded", zap.Int("count", len(cfg.Auth.Policies)))
+		}
 
 		// Inject the access controller into logger and metrics packages
 		logger.SetAccessController(accessController)
 		metrics.SetAccessController(accessController)
 
 		// Create a new kernel instance with the access controller
-		k := kernel.New(cfg, accessController)
+		k := kernel.New(configs, accessController)
 
 		// Load plugins (modules and gateways) from the build/plugins directory
 		pluginDir := "build/plugins" // Assuming plugins are built into this directory
@@ -68,11 +188,43 @@ var serveCmd = &cobra.Command{
 		}
 		log.Printf("Acacia server started.") // Log that the server has started.
 
+		// reloadConfig reloads the configuration on request of trigger and logs the outcome.
+		reloadConfig := func(trigger string) (*config.ReloadReport, error) {
+			report, err := configs.Reload()
+			switch {
+			case err != nil:
+				logger.Error(ctx, "Configuration reload rejected, keeping the previous one", zap.String("trigger", trigger), zap.Error(err))
+			case len(report.Rejected()) > 0:
+				logger.Warn(ctx, "Configuration reloaded, but some components rejected it", zap.String("trigger", trigger), zap.Stringer("report", report))
+			default:
+				logger.Info(ctx, "Configuration reloaded", zap.String("trigger", trigger), zap.Stringer("report", report))
+			}
+			return report, err
+		}
+
+		// Serve admin commands (e.g. `acacia inspect services`) on a local socket.
+		if serveAdminSocket != "" {
+			adminServer := admin.NewServer(serveAdminSocket, auth.NewDefaultPrincipal("admin-socket", "system", nil).WithPermissions("service.*", "gateway.*"))
+			admin.RegisterKernelHandlers(adminServer, k)
+			admin.RegisterConfigHandlers(adminServer, func() (*config.ReloadReport, error) { return reloadConfig("admin socket") })
+			if err := adminServer.Start(); err != nil {
+				logger.Error(ctx, "Failed to start admin socket", zap.String("socket", serveAdminSocket), zap.Error(err))
+			} else {
+				defer adminServer.Close()
+				logger.Info(ctx, "Admin socket listening", zap.String("socket", serveAdminSocket))
+			}
+		}
+
 		// Set up a channel to listen for OS interrupt signals (SIGINT, SIGTERM).
-		// These signals are used to trigger a graceful shutdown.
+		// These signals are used to trigger a graceful shutdown; SIGHUP reloads the configuration.
 		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-		<-sigCh // Block until an OS signal is received.
+		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		for sig := range sigCh { // Block until a shutdown signal is received.
+			if sig != syscall.SIGHUP {
+				break
+			}
+			_, _ = reloadConfig("SIGHUP")
+		}
 
 		// Initiate graceful shutdown.
 		// Create a context with a timeout for the shutdown process to prevent indefinite blocking.
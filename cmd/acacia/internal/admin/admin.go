@@ -0,0 +1,238 @@
+// Package admin implements the local administration socket of a running Acacia server.
+// The server listens on a Unix socket readable only by its owner; each connection carries
+// a single JSON request and receives a single JSON response.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"acacia/core/auth"
+	"acacia/core/config"
+	"acacia/core/kernel"
+)
+
+// DefaultSocketPath is where `acacia serve` listens and CLI commands connect by default.
+const DefaultSocketPath = "acacia.sock"
+
+// requestTimeout bounds how long a single admin request may take.
+const requestTimeout = 30 * time.Second
+
+// Request is a single admin command.
+type Request struct {
+	Command string            `json:"command"`
+	Args    map[string]string `json:"args,omitempty"`
+}
+
+// Response carries the JSON-encoded result of a command, or the error it failed with.
+type Response struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// HandlerFunc handles one admin command. Its result is encoded as JSON.
+type HandlerFunc func(ctx context.Context, req Request) (interface{}, error)
+
+// Server serves admin commands on a Unix socket.
+type Server struct {
+	path      string
+	principal auth.Principal
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	ln       net.Listener
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a server for the socket at path. Requests run as principal, which should
+// hold the permissions the registered commands need; access to the socket itself is limited
+// by file permissions.
+func NewServer(path string, principal auth.Principal) *Server {
+	return &Server{
+		path:      path,
+		principal: principal,
+		handlers:  make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers fn for command, replacing any previous handler.
+func (s *Server) Handle(command string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[command] = fn
+}
+
+// Start listens on the socket and serves requests in the background until Close is called.
+// A stale socket left by a previous process is removed first. The socket is created inside a
+// private directory and only moved to its path once restricted to the owner, so there is no
+// window in which other local users can connect.
+func (s *Server) Start() error {
+	if conn, err := net.Dial("unix", s.path); err == nil {
+		conn.Close()
+		return fmt.Errorf("admin socket %s is already in use", s.path)
+	}
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove stale admin socket %s: %w", s.path, err)
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(s.path), ".acacia-admin-") // Created with mode 0700
+	if err != nil {
+		return fmt.Errorf("create private directory for admin socket %s: %w", s.path, err)
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "sock")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return fmt.Errorf("listen on admin socket %s: %w", s.path, err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false) // The socket is moved; Close removes it from s.path
+	if err := os.Chmod(tmp, 0600); err != nil {
+		ln.Close()
+		return fmt.Errorf("restrict admin socket %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		ln.Close()
+		return fmt.Errorf("move admin socket into place at %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.serve(ln)
+	return nil
+}
+
+// Close stops accepting connections, waits for in-flight requests and removes the socket.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	ln := s.ln
+	s.ln = nil
+	s.mu.Unlock()
+	if ln == nil {
+		return nil
+	}
+	err := ln.Close()
+	if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) && err == nil {
+		err = fmt.Errorf("remove admin socket %s: %w", s.path, rmErr)
+	}
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return // Listener closed
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	var req Request
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		resp.Error = fmt.Sprintf("decode request: %v", err)
+	} else {
+		resp = s.dispatch(req)
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// dispatch runs the handler for req as the server's principal.
+func (s *Server) dispatch(req Request) Response {
+	s.mu.RLock()
+	fn, ok := s.handlers[req.Command]
+	s.mu.RUnlock()
+	if !ok {
+		return Response{Error: fmt.Sprintf("unknown admin command %q", req.Command)}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	ctx = auth.ContextWithPrincipal(ctx, s.principal)
+
+	result, err := fn(ctx, req)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("encode result: %v", err)}
+	}
+	return Response{Result: data}
+}
+
+// RegisterKernelHandlers registers the commands that inspect k:
+//
+//	services  registered service providers
+//	gateways  registered gateways
+func RegisterKernelHandlers(s *Server, k kernel.Kernel) {
+	s.Handle("services", func(ctx context.Context, req Request) (interface{}, error) {
+		return k.DescribeServices(ctx)
+	})
+	s.Handle("gateways", func(ctx context.Context, req Request) (interface{}, error) {
+		return k.DescribeGateways(ctx)
+	})
+}
+
+// RegisterConfigHandlers registers the commands that manage the configuration:
+//
+//	config.reload  reload the configuration with reload and report how the components took it
+//
+// A configuration that fails validation is not applied, and the command fails with the reason.
+func RegisterConfigHandlers(s *Server, reload func() (*config.ReloadReport, error)) {
+	s.Handle("config.reload", func(ctx context.Context, req Request) (interface{}, error) {
+		return reload()
+	})
+}
+
+// Call sends command to the server listening at path and decodes its result into result,
+// which may be nil if the result is not needed.
+func Call(ctx context.Context, path string, req Request, result interface{}) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return fmt.Errorf("connect to admin socket %s (is `acacia serve` running?): %w", path, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("send admin command %q: %w", req.Command, err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("read admin response for %q: %w", req.Command, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("admin command %q failed: %s", req.Command, resp.Error)
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("decode admin result for %q: %w", req.Command, err)
+	}
+	return nil
+}
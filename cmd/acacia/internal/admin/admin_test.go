@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"acacia/core/auth"
+	"acacia/core/config"
+)
+
+func TestServerRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	s := NewServer(path, auth.NewDefaultPrincipal("admin-socket", "system", nil))
+	s.Handle("echo", func(ctx context.Context, req Request) (interface{}, error) {
+		p := auth.PrincipalFromContext(ctx)
+		if p == nil {
+			return nil, errors.New("no principal")
+		}
+		return map[string]string{"principal": p.ID(), "value": req.Args["value"]}, nil
+	})
+	s.Handle("fail", func(ctx context.Context, req Request) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Close()
+
+	var got map[string]string
+	if err := Call(context.Background(), path, Request{Command: "echo", Args: map[string]string{"value": "hi"}}, &got); err != nil {
+		t.Fatalf("Call(echo): %v", err)
+	}
+	if got["principal"] != "admin-socket" || got["value"] != "hi" {
+		t.Errorf("echo result = %v", got)
+	}
+
+	if err := Call(context.Background(), path, Request{Command: "fail"}, nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Call(fail): got %v, want handler error", err)
+	}
+	if err := Call(context.Background(), path, Request{Command: "nope"}, nil); err == nil {
+		t.Error("unknown command should fail")
+	}
+
+	if err := NewServer(path, nil).Start(); err == nil {
+		t.Error("starting a second server on a socket in use should fail")
+	}
+
+	// Only the owner may connect, and nothing is left behind once closed
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("socket directory holds %d entries, want only the socket", len(entries))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket should be removed on Close, Stat = %v", err)
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	s := NewServer(path, auth.NewDefaultPrincipal("admin-socket", "system", nil))
+	reloadErr := error(nil)
+	RegisterConfigHandlers(s, func() (*config.ReloadReport, error) {
+		if reloadErr != nil {
+			return nil, reloadErr
+		}
+		return &config.ReloadReport{
+			Changes:    config.ChangeSet{{Path: "modules.chat.workers", Kind: config.ChangeModified, Old: 4, New: 8}},
+			Components: []config.ComponentResult{{Component: "modules.chat", Error: "busy"}},
+		}, nil
+	})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Close()
+
+	var report config.ReloadReport
+	if err := Call(context.Background(), path, Request{Command: "config.reload"}, &report); err != nil {
+		t.Fatalf("Call(config.reload): %v", err)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Kind != config.ChangeModified || len(report.Rejected()) != 1 {
+		t.Errorf("report = %s", &report)
+	}
+
+	reloadErr = errors.New("invalid configuration: environment: must be one of development, staging, production")
+	if err := Call(context.Background(), path, Request{Command: "config.reload"}, nil); err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Errorf("Call(config.reload) = %v, want the validation error", err)
+	}
+}
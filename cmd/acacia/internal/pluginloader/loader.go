@@ -23,17 +23,60 @@ type Logger interface {
 	Debug(msg string, fields ...zap.Field) // Add Debug method
 }
 
-// LoadPlugins scans the specified plugin directory, loads Go plugin binaries,
-// and adds them to the kernel, providing them with their respective configurations.
-func LoadPlugins(k kernel.Kernel, pluginDir string, cfg *config.Config, logger Logger) error {
+// Plugins holds the components instantiated from a plugin directory.
+type Plugins struct {
+	Modules  []kernel.Module
+	Gateways []kernel.Gateway
+}
+
+// TypedConfigs returns the components that declare a typed configuration, keyed by name, as
+// expected by config.Config.ValidateComponents.
+func (p *Plugins) TypedConfigs() (modules, gateways map[string]config.TypedConfig) {
+	modules = make(map[string]config.TypedConfig)
+	gateways = make(map[string]config.TypedConfig)
+	for _, m := range p.Modules {
+		if spec, ok := m.(config.TypedConfig); ok {
+			modules[m.Name()] = spec
+		}
+	}
+	for _, g := range p.Gateways {
+		if spec, ok := g.(config.TypedConfig); ok {
+			gateways[g.Name()] = spec
+		}
+	}
+	return modules, gateways
+}
+
+// Migrations returns the components that declare config migrations, keyed by name, as expected
+// by config.MigrateDocument.
+func (p *Plugins) Migrations() (modules, gateways map[string]config.MigratingConfig) {
+	modules = make(map[string]config.MigratingConfig)
+	gateways = make(map[string]config.MigratingConfig)
+	for _, m := range p.Modules {
+		if spec, ok := m.(config.MigratingConfig); ok {
+			modules[m.Name()] = spec
+		}
+	}
+	for _, g := range p.Gateways {
+		if spec, ok := g.(config.MigratingConfig); ok {
+			gateways[g.Name()] = spec
+		}
+	}
+	return modules, gateways
+}
+
+// Open scans the specified plugin directory, loads Go plugin binaries and instantiates the
+// modules and gateways they export, without adding them to a kernel.
+func Open(pluginDir string, logger Logger) (*Plugins, error) {
 	logger.Info("Scanning for plugins", zap.String("directory", pluginDir))
 
 	files, err := fs.ReadDir(osFS{}, pluginDir) // Use osFS for ReadDir
 	if err != nil {
 		logger.Error("Failed to read plugin directory", zap.String("directory", pluginDir), zap.Error(err))
-		return fmt.Errorf("read plugin directory %s: %w", pluginDir, err)
+		return nil, fmt.Errorf("read plugin directory %s: %w", pluginDir, err)
 	}
 
+	plugins := &Plugins{}
 	for _, file := range files {
 		if file.IsDir() {
 			continue
@@ -51,70 +94,104 @@ func LoadPlugins(k kernel.Kernel, pluginDir string, cfg *config.Config, logger L
 		p, err := plugin.Open(pluginPath)
 		if err != nil {
 			logger.Error("Failed to open plugin", zap.String("path", pluginPath), zap.Error(err))
-			return fmt.Errorf("open plugin %s: %w", pluginPath, err)
+			return nil, fmt.Errorf("open plugin %s: %w", pluginPath, err)
 		}
 
 		// Try to load as a Module
-		newModuleSym, err := p.Lookup("NewModule")
-		if err == nil {
+		if newModuleSym, err := p.Lookup("NewModule"); err == nil {
 			if newModuleFunc, ok := newModuleSym.(func() kernel.Module); ok {
-				moduleInstance := newModuleFunc()
-				// Configure the module before adding/starting
-				if moduleConfig, ok := cfg.Modules[moduleInstance.Name()]; ok {
-					if err := moduleInstance.Configure(moduleConfig); err != nil {
-						logger.Error("Failed to configure module", zap.String("module", moduleInstance.Name()), zap.Error(err))
-						return fmt.Errorf("configure module %s: %w", moduleInstance.Name(), err)
-					}
-				} else {
-					logger.Warn("No configuration found for module", zap.String("module", moduleInstance.Name()))
-					// Allow module to proceed without specific config if not found.
-					// Modules should handle nil or empty config gracefully if it's optional.
-					if err := moduleInstance.Configure(nil); err != nil {
-						logger.Error("Failed to configure module with nil config", zap.String("module", moduleInstance.Name()), zap.Error(err))
-						return fmt.Errorf("configure module %s with nil config: %w", moduleInstance.Name(), err)
-					}
-				}
-				// Create context with system principal for plugin loading
-				systemPrincipal := auth.NewDefaultPrincipal("plugin-loader", "system", []string{"kernel.module.*"})
-				ctx := auth.ContextWithPrincipal(context.Background(), systemPrincipal)
-
-				if err := k.AddModule(ctx, moduleInstance); err != nil {
-					logger.Error("Failed to add loaded module to kernel", zap.String("module", moduleInstance.Name()), zap.Error(err))
-					return fmt.Errorf("add module %s: %w", moduleInstance.Name(), err)
-				}
-				logger.Info("Successfully loaded and added module plugin", zap.String("module", moduleInstance.Name()), zap.String("path", pluginPath))
+				plugins.Modules = append(plugins.Modules, newModuleFunc())
 				continue // Move to next file
 			}
 		}
 
 		// Try to load as a Gateway
-		newGatewaySym, err := p.Lookup("NewGateway")
-		if err == nil {
+		if newGatewaySym, err := p.Lookup("NewGateway"); err == nil {
 			if newGatewayFunc, ok := newGatewaySym.(func() kernel.Gateway); ok {
-				gatewayInstance := newGatewayFunc()
-				// Configure the gateway before adding/starting
-				// Pass only the specific gateway's configuration
-				if gatewayConfig, ok := cfg.Gateways[gatewayInstance.Name()]; ok {
-					if err := gatewayInstance.Configure(gatewayConfig); err != nil {
-						logger.Error("Failed to configure gateway", zap.String("gateway", gatewayInstance.Name()), zap.Error(err))
-						return fmt.Errorf("configure gateway %s: %w", gatewayInstance.Name(), err)
-					}
-				} else {
-					logger.Warn("No configuration found for gateway", zap.String("gateway", gatewayInstance.Name()))
-					// Continue without configuration, or return an error if configuration is mandatory
-					// For now, we'll allow it to proceed without specific config if not found.
-				}
-				if err := k.AddGateway(gatewayInstance); err != nil {
-					logger.Error("Failed to add loaded gateway to kernel", zap.String("gateway", gatewayInstance.Name()), zap.Error(err))
-					return fmt.Errorf("add gateway %s: %w", gatewayInstance.Name(), err)
-				}
-				logger.Info("Successfully loaded and added gateway plugin", zap.String("gateway", gatewayInstance.Name()), zap.String("path", pluginPath))
+				plugins.Gateways = append(plugins.Gateways, newGatewayFunc())
 				continue // Move to next file
 			}
 		}
 
 		logger.Warn("Plugin does not export NewModule or NewGateway function", zap.String("path", pluginPath))
 	}
+	return plugins, nil
+}
+
+// LoadPlugins loads the plugins in the specified directory and adds their components to the
+// kernel, providing them with their respective configurations. The typed configurations of
+// all components are validated first, so that every error is reported before anything starts.
+func LoadPlugins(k kernel.Kernel, pluginDir string, cfg *config.Config, logger Logger) error {
+	plugins, err := Open(pluginDir, logger)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ValidateComponents(plugins.TypedConfigs()); err != nil {
+		logger.Error("Invalid plugin configuration", zap.Error(err))
+		return fmt.Errorf("invalid plugin configuration:\n%w", err)
+	}
+
+	// The plugin loader authorizes adding the modules; the kernel then issues each module its
+	// own principal, and the module's lifecycle calls run as that principal.
+	systemPrincipal := auth.NewDefaultPrincipal("plugin-loader", "system", nil).WithPermissions("kernel.module.*")
+	ctx := auth.ContextWithPrincipal(context.Background(), systemPrincipal)
+
+	for _, moduleInstance := range plugins.Modules {
+		// Configure untyped modules before adding/starting; the kernel decodes typed configs
+		if _, typed := moduleInstance.(config.TypedConfig); !typed {
+			if moduleConfig, ok := cfg.Modules[moduleInstance.Name()]; ok {
+				moduleConfig, err := config.MigrateSection(config.SectionModules+"."+moduleInstance.Name(), moduleInstance, moduleConfig)
+				if err != nil {
+					logger.Error("Failed to migrate module configuration", zap.String("module", moduleInstance.Name()), zap.Error(err))
+					return fmt.Errorf("configure module %s: %w", moduleInstance.Name(), err)
+				}
+				if err := moduleInstance.Configure(moduleConfig); err != nil {
+					logger.Error("Failed to configure module", zap.String("module", moduleInstance.Name()), zap.Error(err))
+					return fmt.Errorf("configure module %s: %w", moduleInstance.Name(), err)
+				}
+			} else {
+				logger.Warn("No configuration found for module", zap.String("module", moduleInstance.Name()))
+				// Allow module to proceed without specific config if not found.
+				// Modules should handle nil or empty config gracefully if it's optional.
+				if err := moduleInstance.Configure(nil); err != nil {
+					logger.Error("Failed to configure module with nil config", zap.String("module", moduleInstance.Name()), zap.Error(err))
+					return fmt.Errorf("configure module %s with nil config: %w", moduleInstance.Name(), err)
+				}
+			}
+		}
+		if err := k.AddModule(ctx, moduleInstance); err != nil {
+			logger.Error("Failed to add loaded module to kernel", zap.String("module", moduleInstance.Name()), zap.Error(err))
+			return fmt.Errorf("add module %s: %w", moduleInstance.Name(), err)
+		}
+		logger.Info("Successfully loaded and added module plugin", zap.String("module", moduleInstance.Name()))
+	}
+
+	for _, gatewayInstance := range plugins.Gateways {
+		// Configure untyped gateways before adding/starting, passing only the specific
+		// gateway's configuration; the kernel decodes typed configs
+		if _, typed := gatewayInstance.(config.TypedConfig); !typed {
+			if gatewayConfig, ok := cfg.Gateways[gatewayInstance.Name()]; ok {
+				gatewayConfig, err := config.MigrateSection(config.SectionGateways+"."+gatewayInstance.Name(), gatewayInstance, gatewayConfig)
+				if err != nil {
+					logger.Error("Failed to migrate gateway configuration", zap.String("gateway", gatewayInstance.Name()), zap.Error(err))
+					return fmt.Errorf("configure gateway %s: %w", gatewayInstance.Name(), err)
+				}
+				if err := gatewayInstance.Configure(gatewayConfig); err != nil {
+					logger.Error("Failed to configure gateway", zap.String("gateway", gatewayInstance.Name()), zap.Error(err))
+					return fmt.Errorf("configure gateway %s: %w", gatewayInstance.Name(), err)
+				}
+			} else {
+				logger.Warn("No configuration found for gateway", zap.String("gateway", gatewayInstance.Name()))
+				// Continue without configuration, or return an error if configuration is mandatory
+				// For now, we'll allow it to proceed without specific config if not found.
+			}
+		}
+		if err := k.AddGateway(gatewayInstance); err != nil {
+			logger.Error("Failed to add loaded gateway to kernel", zap.String("gateway", gatewayInstance.Name()), zap.Error(err))
+			return fmt.Errorf("add gateway %s: %w", gatewayInstance.Name(), err)
+		}
+		logger.Info("Successfully loaded and added gateway plugin", zap.String("gateway", gatewayInstance.Name()))
+	}
 	return nil
 }
 
@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PrincipalTypeService is the principal type of API key holders such as bots and internal tools.
+const PrincipalTypeService = "service"
+
+// APIKeyPrefix starts every API key, so keys are recognizable in logs and secret scanners and
+// can be told apart from JWTs. A key looks like "ak_<id>_<secret>".
+const APIKeyPrefix = "ak_"
+
+// APIKeyHeader is the HTTP header APIKeyMiddleware reads keys from.
+const APIKeyHeader = "X-API-Key"
+
+// DefaultLastUsedResolution is how stale an API key's LastUsedAt may get before a successful
+// authentication writes it back to the store.
+const DefaultLastUsedResolution = time.Minute
+
+var (
+	// ErrInvalidAPIKey is returned for malformed, unknown, expired or revoked API keys.
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyNotFound is returned by a KeyStore when no key has the requested ID.
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKey is the stored record of an API key. Only a hash of the secret is kept; the secret
+// itself is shown once, when the key is created or rotated.
+type APIKey struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`            // Human-readable label, e.g. "matchmaking-bot"
+	ServiceAccount string                 `json:"service_account"` // ID of the principal the key authenticates as
+	Roles          []string               `json:"roles"`           // Roles granted to requests made with this key
+	Attributes     map[string]interface{} `json:"attributes,omitempty"`
+	Hash           string                 `json:"hash"` // Hex SHA-256 of the secret
+	CreatedAt      time.Time              `json:"created_at"`
+	ExpiresAt      time.Time              `json:"expires_at,omitempty"` // Zero means the key does not expire
+	LastUsedAt     time.Time              `json:"last_used_at,omitempty"`
+	RevokedAt      time.Time              `json:"revoked_at,omitempty"`
+	ReplacedBy     string                 `json:"replaced_by,omitempty"` // ID of the key this one was rotated to
+}
+
+// Active reports whether the key may still be used at now.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt.IsZero() && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// KeyStore persists API keys.
+type KeyStore interface {
+	// Get returns the key with id, or ErrAPIKeyNotFound.
+	Get(ctx context.Context, id string) (*APIKey, error)
+	// Put creates or replaces a key.
+	Put(ctx context.Context, key *APIKey) error
+	// Delete removes a key. Deleting an unknown key is not an error.
+	Delete(ctx context.Context, id string) error
+	// List returns all keys sorted by creation time.
+	List(ctx context.Context) ([]*APIKey, error)
+	// Touch records that the key was used at t.
+	Touch(ctx context.Context, id string, t time.Time) error
+}
+
+// APIKeyOptions configures an APIKeyManager.
+type APIKeyOptions struct {
+	// LastUsedResolution limits how often LastUsedAt is written back, defaults to DefaultLastUsedResolution.
+	LastUsedResolution time.Duration
+}
+
+// APIKeyManager issues, rotates and revokes API keys, and authenticates them as a PrincipalFactory.
+// Principals it returns have type "service", the key's service account as ID, the key's roles,
+// and the key's attributes plus "api_key_id" and "api_key_name".
+type APIKeyManager struct {
+	store      KeyStore
+	resolution time.Duration
+	now        func() time.Time
+}
+
+// NewAPIKeyManager creates a manager backed by store.
+func NewAPIKeyManager(store KeyStore, opts APIKeyOptions) *APIKeyManager {
+	if opts.LastUsedResolution <= 0 {
+		opts.LastUsedResolution = DefaultLastUsedResolution
+	}
+	return &APIKeyManager{store: store, resolution: opts.LastUsedResolution, now: time.Now}
+}
+
+// APIKeySpec describes a key to create.
+type APIKeySpec struct {
+	Name           string
+	ServiceAccount string
+	Roles          []string
+	Attributes     map[string]interface{}
+	TTL            time.Duration // Zero for a key that does not expire
+}
+
+// Create issues a new key and returns its secret, which cannot be recovered later, and its record.
+func (m *APIKeyManager) Create(ctx context.Context, spec APIKeySpec) (string, *APIKey, error) {
+	if spec.ServiceAccount == "" {
+		return "", nil, fmt.Errorf("create API key: service account is required")
+	}
+	now := m.now()
+	key := &APIKey{
+		Name:           spec.Name,
+		ServiceAccount: spec.ServiceAccount,
+		Roles:          append([]string{}, spec.Roles...),
+		Attributes:     spec.Attributes,
+		CreatedAt:      now,
+	}
+	if spec.TTL > 0 {
+		key.ExpiresAt = now.Add(spec.TTL)
+	}
+	secret, err := m.issue(ctx, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("create API key: %w", err)
+	}
+	return secret, key, nil
+}
+
+// Rotate issues a replacement for the key with id, carrying over its name, service account,
+// roles, attributes and expiry time. The old key keeps working for grace, so clients
+// can switch over without downtime; a zero grace retires it immediately.
+func (m *APIKeyManager) Rotate(ctx context.Context, id string, grace time.Duration) (string, *APIKey, error) {
+	old, err := m.store.Get(ctx, id)
+	if err != nil {
+		return "", nil, fmt.Errorf("rotate API key %s: %w", id, err)
+	}
+	now := m.now()
+	if !old.Active(now) {
+		return "", nil, fmt.Errorf("rotate API key %s: key is no longer active", id)
+	}
+
+	key := &APIKey{
+		Name:           old.Name,
+		ServiceAccount: old.ServiceAccount,
+		Roles:          old.Roles,
+		Attributes:     old.Attributes,
+		CreatedAt:      now,
+		ExpiresAt:      old.ExpiresAt, // Rotation must not extend an expiring key's life
+	}
+	secret, err := m.issue(ctx, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("rotate API key %s: %w", id, err)
+	}
+
+	graceEnd := now.Add(grace)
+	if old.ExpiresAt.IsZero() || graceEnd.Before(old.ExpiresAt) {
+		old.ExpiresAt = graceEnd
+	}
+	old.ReplacedBy = key.ID
+	if err := m.store.Put(ctx, old); err != nil {
+		return "", nil, fmt.Errorf("rotate API key %s: retire old key: %w", id, err)
+	}
+	return secret, key, nil
+}
+
+// Revoke disables the key with id immediately.
+func (m *APIKeyManager) Revoke(ctx context.Context, id string) error {
+	key, err := m.store.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("revoke API key %s: %w", id, err)
+	}
+	if key.RevokedAt.IsZero() {
+		key.RevokedAt = m.now()
+	}
+	if err := m.store.Put(ctx, key); err != nil {
+		return fmt.Errorf("revoke API key %s: %w", id, err)
+	}
+	return nil
+}
+
+// List returns all keys, including expired and revoked ones.
+func (m *APIKeyManager) List(ctx context.Context) ([]*APIKey, error) {
+	return m.store.List(ctx)
+}
+
+// NewPrincipal authenticates an API key and returns the service principal it stands for.
+func (m *APIKeyManager) NewPrincipal(ctx context.Context, credential string) (Principal, error) {
+	id, secret, err := parseAPIKey(credential)
+	if err != nil {
+		return nil, err
+	}
+	key, err := m.store.Get(ctx, id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("%w: unknown key", ErrInvalidAPIKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("look up API key: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 {
+		return nil, fmt.Errorf("%w: unknown key", ErrInvalidAPIKey)
+	}
+	now := m.now()
+	switch {
+	case !key.RevokedAt.IsZero():
+		return nil, fmt.Errorf("%w: key %s was revoked", ErrInvalidAPIKey, key.ID)
+	case !key.Active(now):
+		return nil, fmt.Errorf("%w: key %s expired", ErrInvalidAPIKey, key.ID)
+	}
+
+	// Last-used tracking is best effort and throttled, so that busy bots do not turn every
+	// request into a store write. A failure here must not reject a valid key.
+	if now.Sub(key.LastUsedAt) >= m.resolution {
+		_ = m.store.Touch(ctx, key.ID, now)
+	}
+
+	attributes := make(map[string]interface{}, len(key.Attributes)+2)
+	for k, v := range key.Attributes {
+		attributes[k] = v
+	}
+	attributes["api_key_id"] = key.ID
+	attributes["api_key_name"] = key.Name
+	return NewPrincipalWithAttributes(key.ServiceAccount, PrincipalTypeService, append([]string{}, key.Roles...), attributes), nil
+}
+
+// issue generates an ID and secret for key and stores it.
+func (m *APIKeyManager) issue(ctx context.Context, key *APIKey) (string, error) {
+	idBytes := make([]byte, 8)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", err
+	}
+	key.ID = hex.EncodeToString(idBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	key.Hash = hashSecret(secret)
+	if err := m.store.Put(ctx, key); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + key.ID + "_" + secret, nil
+}
+
+// WithAPIKeys returns a PrincipalFactory that authenticates credentials carrying the API key
+// prefix with keys and passes everything else to next, so a gateway can accept both user JWTs
+// and service API keys as bearer tokens. A nil next rejects anything that is not an API key.
+func WithAPIKeys(keys *APIKeyManager, next PrincipalFactory) PrincipalFactory {
+	return PrincipalFactoryFunc(func(ctx context.Context, credential string) (Principal, error) {
+		if IsAPIKey(credential) || next == nil {
+			return keys.NewPrincipal(ctx, credential)
+		}
+		return next.NewPrincipal(ctx, credential)
+	})
+}
+
+// APIKeyMiddleware is an HTTP middleware for gateways that authenticates the X-API-Key header
+// with factory and stores the principal in the request context. Requests without a valid key
+// are rejected with 401 Unauthorized.
+func APIKeyMiddleware(factory PrincipalFactory) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := strings.TrimSpace(r.Header.Get(APIKeyHeader))
+			if key == "" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			p, err := factory.NewPrincipal(r.Context(), key)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// IsAPIKey reports whether credential looks like an API key rather than, say, a JWT.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// parseAPIKey splits "ak_<id>_<secret>" into its ID and secret.
+func parseAPIKey(credential string) (string, string, error) {
+	if !IsAPIKey(credential) {
+		return "", "", fmt.Errorf("%w: missing %q prefix", ErrInvalidAPIKey, APIKeyPrefix)
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(credential, APIKeyPrefix), "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", fmt.Errorf("%w: malformed key", ErrInvalidAPIKey)
+	}
+	return id, secret, nil
+}
+
+// hashSecret hashes an API key secret. Secrets are 256 random bits, so a fast hash is enough;
+// there is nothing to brute-force the way there is with passwords.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
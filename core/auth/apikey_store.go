@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// keyFileLockRetry is how long FileKeyStore waits between attempts to acquire its lock file.
+	keyFileLockRetry = 10 * time.Millisecond
+	// keyFileLockStale is the age after which a leftover lock file is assumed to belong to a crashed process.
+	keyFileLockStale = 10 * time.Second
+)
+
+// MemoryKeyStore is a KeyStore that keeps keys in memory, for tests and single-process setups
+// that issue keys at startup.
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+// NewMemoryKeyStore creates an empty MemoryKeyStore.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: make(map[string]APIKey)}
+}
+
+// Get returns a copy of the key with id.
+func (s *MemoryKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+// Put creates or replaces a key.
+func (s *MemoryKeyStore) Put(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = *key
+	return nil
+}
+
+// Delete removes a key.
+func (s *MemoryKeyStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, id)
+	return nil
+}
+
+// List returns copies of all keys sorted by creation time.
+func (s *MemoryKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedKeys(s.keys), nil
+}
+
+// Touch records that the key was used at t.
+func (s *MemoryKeyStore) Touch(ctx context.Context, id string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if t.After(key.LastUsedAt) {
+		key.LastUsedAt = t
+		s.keys[id] = key
+	}
+	return nil
+}
+
+// FileKeyStore is a KeyStore backed by a JSON file, so keys issued with "acacia apikey" are
+// seen by running servers. Like the remote registry's FileStore, updates are serialized through
+// a lock file and written atomically via rename. Reads are served from memory and the file is
+// only parsed again when its modification time or size changes.
+type FileKeyStore struct {
+	path string
+	now  func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]APIKey
+	modTime time.Time
+	size    int64
+	loaded  bool
+}
+
+// NewFileKeyStore creates a FileKeyStore that persists keys at path. The parent directory is
+// created if it does not exist; a missing file is an empty store.
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create API key store directory: %w", err)
+	}
+	return &FileKeyStore{path: path, now: time.Now}, nil
+}
+
+// Get returns a copy of the key with id.
+func (s *FileKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	keys, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+// Put creates or replaces a key.
+func (s *FileKeyStore) Put(ctx context.Context, key *APIKey) error {
+	return s.update(ctx, func(keys map[string]APIKey) error {
+		keys[key.ID] = *key
+		return nil
+	})
+}
+
+// Delete removes a key.
+func (s *FileKeyStore) Delete(ctx context.Context, id string) error {
+	return s.update(ctx, func(keys map[string]APIKey) error {
+		delete(keys, id)
+		return nil
+	})
+}
+
+// List returns copies of all keys sorted by creation time.
+func (s *FileKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	keys, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(keys), nil
+}
+
+// Touch records that the key was used at t.
+func (s *FileKeyStore) Touch(ctx context.Context, id string, t time.Time) error {
+	return s.update(ctx, func(keys map[string]APIKey) error {
+		key, ok := keys[id]
+		if !ok {
+			return ErrAPIKeyNotFound
+		}
+		if t.After(key.LastUsedAt) {
+			key.LastUsedAt = t
+			keys[id] = key
+		}
+		return nil
+	})
+}
+
+// current returns the cached keys, reloading them if the file changed on disk. The returned
+// map must not be modified.
+func (s *FileKeyStore) current() (map[string]APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.keys, s.loaded = map[string]APIKey{}, true
+		s.modTime, s.size = time.Time{}, 0
+		return s.keys, nil
+	case err != nil:
+		return nil, fmt.Errorf("stat API key store %s: %w", s.path, err)
+	case s.loaded && info.ModTime().Equal(s.modTime) && info.Size() == s.size:
+		return s.keys, nil
+	}
+	keys, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.keys, s.loaded = keys, true
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return s.keys, nil
+}
+
+// update applies fn to the stored keys under the file lock.
+func (s *FileKeyStore) update(ctx context.Context, fn func(map[string]APIKey) error) error {
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := fn(keys); err != nil {
+		return err
+	}
+	if err := s.write(keys); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.loaded = false // Pick up our own write on the next read
+	s.mu.Unlock()
+	return nil
+}
+
+// read loads the keys from disk. A missing file yields an empty set.
+func (s *FileKeyStore) read() (map[string]APIKey, error) {
+	keys := make(map[string]APIKey)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read API key store %s: %w", s.path, err)
+	}
+	if len(data) == 0 {
+		return keys, nil
+	}
+	var list []APIKey
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse API key store %s: %w", s.path, err)
+	}
+	for _, key := range list {
+		keys[key.ID] = key
+	}
+	return keys, nil
+}
+
+// write atomically replaces the store file with keys. The file only holds hashes, but it is
+// still kept private to the owner since it lists every service account and its roles.
+func (s *FileKeyStore) write(keys map[string]APIKey) error {
+	list := make([]APIKey, 0, len(keys))
+	for _, key := range sortedKeys(keys) {
+		list = append(list, *key)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal API key store: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write API key store %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replace API key store %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// lock acquires the store's lock file, waiting until ctx is done.
+func (s *FileKeyStore) lock(ctx context.Context) (func(), error) {
+	lockPath := s.path + ".lock"
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("lock API key store %s: %w", s.path, err)
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && s.now().Sub(info.ModTime()) > keyFileLockStale {
+			os.Remove(lockPath) // Left behind by a crashed process
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("lock API key store %s: %w", s.path, ctx.Err())
+		case <-time.After(keyFileLockRetry):
+		}
+	}
+}
+
+// sortedKeys returns copies of keys ordered by creation time, then ID.
+func sortedKeys(keys map[string]APIKey) []*APIKey {
+	list := make([]*APIKey, 0, len(keys))
+	for _, key := range keys {
+		key := key
+		list = append(list, &key)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
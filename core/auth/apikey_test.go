@@ -0,0 +1,182 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"acacia/core/auth"
+)
+
+func TestAPIKeyManager(t *testing.T) {
+	ctx := context.Background()
+	store := auth.NewMemoryKeyStore()
+	manager := auth.NewAPIKeyManager(store, auth.APIKeyOptions{})
+
+	secret, key, err := manager.Create(ctx, auth.APIKeySpec{Name: "matchmaker", ServiceAccount: "bot-matchmaker", Roles: []string{"matchmaking"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(secret, auth.APIKeyPrefix) || strings.Contains(key.Hash, strings.TrimPrefix(secret, auth.APIKeyPrefix+key.ID+"_")) {
+		t.Fatalf("unexpected secret %q / hash %q", secret, key.Hash)
+	}
+
+	p, err := manager.NewPrincipal(ctx, secret)
+	if err != nil {
+		t.Fatalf("NewPrincipal: %v", err)
+	}
+	if p.ID() != "bot-matchmaker" || p.Type() != auth.PrincipalTypeService || len(p.Roles()) != 1 || p.Roles()[0] != "matchmaking" {
+		t.Errorf("principal = %s/%s/%v", p.ID(), p.Type(), p.Roles())
+	}
+	if attrs := auth.PrincipalAttributes(p); attrs["api_key_id"] != key.ID || attrs["api_key_name"] != "matchmaker" {
+		t.Errorf("attributes = %v", attrs)
+	}
+	if stored, _ := store.Get(ctx, key.ID); stored.LastUsedAt.IsZero() {
+		t.Error("LastUsedAt should be recorded")
+	}
+
+	for _, bad := range []string{"", "not-a-key", auth.APIKeyPrefix + key.ID, secret + "x", auth.APIKeyPrefix + "ffff_" + "secret"} {
+		if _, err := manager.NewPrincipal(ctx, bad); !errors.Is(err, auth.ErrInvalidAPIKey) {
+			t.Errorf("NewPrincipal(%q) = %v, want ErrInvalidAPIKey", bad, err)
+		}
+	}
+
+	if err := manager.Revoke(ctx, key.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := manager.NewPrincipal(ctx, secret); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("revoked key accepted: %v", err)
+	}
+}
+
+func TestAPIKeyManager_ExpiryAndRotation(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys", "api_keys.json")
+	store, err := auth.NewFileKeyStore(path)
+	if err != nil {
+		t.Fatalf("NewFileKeyStore: %v", err)
+	}
+	manager := auth.NewAPIKeyManager(store, auth.APIKeyOptions{})
+
+	oldSecret, old, err := manager.Create(ctx, auth.APIKeySpec{ServiceAccount: "ci", Roles: []string{"deployer"}, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	newSecret, replacement, err := manager.Rotate(ctx, old.ID, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if replacement.ServiceAccount != "ci" || replacement.Roles[0] != "deployer" || replacement.ExpiresAt.IsZero() {
+		t.Errorf("replacement = %+v", replacement)
+	}
+
+	// Both keys work during the grace period.
+	for _, secret := range []string{oldSecret, newSecret} {
+		if _, err := manager.NewPrincipal(ctx, secret); err != nil {
+			t.Fatalf("key rejected during grace period: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := manager.NewPrincipal(ctx, oldSecret); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("old key accepted after grace period: %v", err)
+	}
+	if _, err := manager.NewPrincipal(ctx, newSecret); err != nil {
+		t.Errorf("new key rejected: %v", err)
+	}
+
+	// A second store on the same file sees the same keys.
+	other, err := auth.NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := other.List(ctx)
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("List = %d keys, %v", len(keys), err)
+	}
+	if keys[0].ID != old.ID || keys[0].ReplacedBy != replacement.ID {
+		t.Errorf("old key record = %+v", keys[0])
+	}
+}
+
+func TestAPIKeyManager_RotationKeepsExpiry(t *testing.T) {
+	ctx := context.Background()
+	manager := auth.NewAPIKeyManager(auth.NewMemoryKeyStore(), auth.APIKeyOptions{})
+
+	_, key, err := manager.Create(ctx, auth.APIKeySpec{ServiceAccount: "ci", TTL: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expiresAt := key.ExpiresAt
+
+	// Rotating close to expiry, again and again, never extends the key's life
+	var secret string
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		secret, key, err = manager.Rotate(ctx, key.ID, 0)
+		if err != nil {
+			t.Fatalf("Rotate %d: %v", i, err)
+		}
+		if !key.ExpiresAt.Equal(expiresAt) {
+			t.Fatalf("rotation %d moved expiry from %s to %s", i, expiresAt, key.ExpiresAt)
+		}
+	}
+	time.Sleep(time.Until(expiresAt) + 20*time.Millisecond)
+	if _, err := manager.NewPrincipal(ctx, secret); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("rotated key accepted after the original expiry: %v", err)
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	ctx := context.Background()
+	manager := auth.NewAPIKeyManager(auth.NewMemoryKeyStore(), auth.APIKeyOptions{})
+	secret, _, err := manager.Create(ctx, auth.APIKeySpec{ServiceAccount: "bot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := auth.PrincipalFactoryFunc(func(ctx context.Context, token string) (auth.Principal, error) {
+		if token != "user-token" {
+			return nil, auth.ErrInvalidToken
+		}
+		return auth.NewDefaultPrincipal("alice", "user", nil), nil
+	})
+
+	var seen string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := auth.PrincipalFromContext(r.Context())
+		seen = p.Type() + ":" + p.ID()
+	})
+	tests := []struct {
+		name    string
+		handler http.Handler
+		header  string
+		value   string
+		want    string
+	}{
+		{"api key header", auth.APIKeyMiddleware(manager)(ok), auth.APIKeyHeader, secret, "service:bot"},
+		{"missing header", auth.APIKeyMiddleware(manager)(ok), auth.APIKeyHeader, "", ""},
+		{"bearer api key", auth.BearerMiddleware(auth.WithAPIKeys(manager, users))(ok), "Authorization", "Bearer " + secret, "service:bot"},
+		{"bearer user token", auth.BearerMiddleware(auth.WithAPIKeys(manager, users))(ok), "Authorization", "Bearer user-token", "user:alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = ""
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.value != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+			if seen != tt.want {
+				t.Errorf("principal = %q, want %q", seen, tt.want)
+			}
+			if tt.want == "" && rec.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", rec.Code)
+			}
+		})
+	}
+}
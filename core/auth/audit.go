@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEvent records a single authorization decision.
+type AuditEvent struct {
+	Time          time.Time  `json:"time"`
+	Principal     string     `json:"principal"`
+	PrincipalType string     `json:"principal_type"`
+	Permission    Permission `json:"permission"`
+	Allowed       bool       `json:"allowed"`
+	Rule          Permission `json:"rule,omitempty"`
+	Role          string     `json:"role,omitempty"`
+	Reason        string     `json:"reason"`
+	// Caller is the function outside the auth package that asked for the decision,
+	// e.g. "acacia/core/kernel.(*kernel).AddModule".
+	Caller string `json:"caller,omitempty"`
+}
+
+// AuditSink receives authorization decisions. Record is called synchronously on the checking
+// goroutine, so implementations should be fast and must be safe for concurrent use.
+type AuditSink interface {
+	Record(event AuditEvent)
+}
+
+// AuditSinkFunc adapts a function to the AuditSink interface.
+type AuditSinkFunc func(event AuditEvent)
+
+// Record calls f(event).
+func (f AuditSinkFunc) Record(event AuditEvent) { f(event) }
+
+// JSONAuditSink writes one JSON object per decision to a writer, such as an append-only file.
+type JSONAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditSink returns a sink writing JSON lines to w.
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{enc: json.NewEncoder(w)}
+}
+
+// Record writes event as a single line. Write errors are dropped; authorization must not fail
+// because the audit trail is unavailable.
+func (s *JSONAuditSink) Record(event AuditEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.enc.Encode(event)
+}
+
+// auditCaller returns the first function on the stack outside this package.
+func auditCaller() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs) // Skip runtime.Callers, auditCaller and its caller
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "acacia/core/auth.") {
+			return frame.Function
+		}
+		if !more {
+			return ""
+		}
+	}
+}
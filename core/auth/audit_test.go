@@ -0,0 +1,136 @@
+package auth_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"acacia/core/auth"
+)
+
+// countingProvider counts role lookups to observe cache hits. It does not report versions.
+type countingProvider struct {
+	mu      sync.Mutex
+	roles   map[string]*auth.Role
+	lookups int
+}
+
+func (c *countingProvider) GetRole(name string) (*auth.Role, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lookups++
+	r, ok := c.roles[name]
+	return r, ok
+}
+
+func (c *countingProvider) set(role auth.Role) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roles[role.Name] = &role
+}
+
+func TestDecisionCache(t *testing.T) {
+	provider := &countingProvider{roles: map[string]*auth.Role{}}
+	provider.set(auth.Role{Name: "player", Permissions: []auth.Permission{"core.log"}})
+	ac := auth.NewDefaultAccessControllerWithOptions(provider, auth.AccessControllerOptions{CacheSize: 2})
+	p := auth.NewDefaultPrincipal("u", "user", []string{"player"})
+
+	for i := 0; i < 10; i++ {
+		if !ac.HasPermission(p, "core.log") {
+			t.Fatal("core.log should be allowed")
+		}
+	}
+	if provider.lookups != 1 {
+		t.Errorf("provider consulted %d times, want 1", provider.lookups)
+	}
+
+	// A principal with the same ID but other roles must not share the cached decision.
+	if ac.HasPermission(auth.NewDefaultPrincipal("u", "user", nil), "core.log") {
+		t.Error("decision leaked between principals with different roles")
+	}
+
+	// Filling the cache evicts the least recently used decision.
+	ac.HasPermission(p, "core.metrics.access")
+	ac.HasPermission(p, "core.events.publish.x")
+	before := provider.lookups
+	ac.HasPermission(p, "core.log")
+	if provider.lookups == before {
+		t.Error("evicted decision should be recomputed")
+	}
+
+	// Without versions, role changes only apply once the cache is invalidated.
+	provider.set(auth.Role{Name: "player"})
+	if !ac.HasPermission(p, "core.log") {
+		t.Fatal("cached decision expected before invalidation")
+	}
+	ac.(*auth.DefaultAccessController).InvalidateCache()
+	if ac.HasPermission(p, "core.log") {
+		t.Error("revoked permission should be denied after invalidation")
+	}
+}
+
+func TestDecisionCache_VersionedProvider(t *testing.T) {
+	provider := auth.NewConfigRBACProvider([]auth.Role{{Name: "player", Permissions: []auth.Permission{"core.log"}}})
+	ac := auth.NewDefaultAccessController(provider)
+	p := auth.NewDefaultPrincipal("u", "user", []string{"player"})
+
+	if !ac.HasPermission(p, "core.log") {
+		t.Fatal("core.log should be allowed")
+	}
+	if err := provider.SetRoles([]auth.Role{{Name: "player"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ac.HasPermission(p, "core.log") {
+		t.Error("role change should invalidate cached decisions")
+	}
+}
+
+func TestAuditSink(t *testing.T) {
+	var events []auth.AuditEvent
+	sink := auth.AuditSinkFunc(func(e auth.AuditEvent) { events = append(events, e) })
+	provider := auth.NewConfigRBACProvider([]auth.Role{{Name: "player", Permissions: []auth.Permission{"service.chat.*"}}})
+	p := auth.NewDefaultPrincipal("u", "user", []string{"player"})
+
+	ac := auth.NewDefaultAccessControllerWithOptions(provider, auth.AccessControllerOptions{
+		Audit:     sink,
+		AuditSkip: []auth.Permission{"core.log"},
+	})
+	ac.HasPermission(p, "service.chat.room.access") // Allowed, not recorded
+	ac.HasPermission(p, "core.log")                 // Denied, but skipped
+	ac.HasPermission(p, "service.admin.access")
+	ac.HasPermission(p, "service.admin.access") // Cached decisions are still audited
+
+	if len(events) != 2 {
+		t.Fatalf("recorded %d events, want 2: %+v", len(events), events)
+	}
+	e := events[0]
+	if e.Allowed || e.Principal != "u" || e.PrincipalType != "user" || e.Permission != "service.admin.access" || e.Reason == "" {
+		t.Errorf("event = %+v", e)
+	}
+	if !strings.HasSuffix(e.Caller, "TestAuditSink") {
+		t.Errorf("Caller = %q, want the test function", e.Caller)
+	}
+
+	var buf bytes.Buffer
+	ac = auth.NewDefaultAccessControllerWithOptions(provider, auth.AccessControllerOptions{Audit: auth.NewJSONAuditSink(&buf), AuditAllowed: true})
+	ac.HasPermission(p, "service.chat.room.access")
+	var logged auth.AuditEvent
+	if err := json.Unmarshal(buf.Bytes(), &logged); err != nil {
+		t.Fatalf("audit line %q: %v", buf.String(), err)
+	}
+	if !logged.Allowed || logged.Rule != "service.chat.*" || logged.Role != "player" {
+		t.Errorf("logged = %+v", logged)
+	}
+}
+
+func TestComponentPrincipal(t *testing.T) {
+	a, b := auth.ComponentPrincipal("scheduler"), auth.ComponentPrincipal("scheduler")
+	if a != b {
+		t.Error("ComponentPrincipal should return a shared principal per component")
+	}
+	if a.ID() != "scheduler" || a.Type() != "component" || len(a.Roles()) != 0 {
+		t.Errorf("principal = %s/%s/%v", a.ID(), a.Type(), a.Roles())
+	}
+}
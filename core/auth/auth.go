@@ -4,6 +4,9 @@ package auth
 import (
 	"context"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -36,10 +39,12 @@ func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
 // Permission defines a specific action that can be performed.
 type Permission string
 
-// Role defines a collection of permissions.
+// Role defines a collection of permissions. A role also holds the permissions of the roles it
+// inherits, transitively; deny rules in any of them still override allow rules.
 type Role struct {
 	Name        string
 	Permissions []Permission
+	Inherits    []string
 }
 
 // RBACProvider defines the interface for a source of RBAC rules.
@@ -70,15 +75,54 @@ type AccessController interface {
 // It can be configured with an RBACProvider to enforce rules.
 type DefaultAccessController struct {
 	rbacProvider RBACProvider
+	compiled     roleCache      // Permission tries of the provider's roles
+	decisions    *decisionCache // nil if caching is disabled
+
+	audit        AuditSink
+	auditAllowed bool
+	auditSkip    *permissionTrie // Permissions never audited, nil for none
+}
+
+// AccessControllerOptions tunes a DefaultAccessController.
+type AccessControllerOptions struct {
+	// CacheSize bounds the number of cached permission decisions. Zero uses
+	// DefaultDecisionCacheSize; a negative size disables caching.
+	CacheSize int
+	// Audit, if set, receives every denied decision, and allowed ones too if AuditAllowed is set.
+	Audit        AuditSink
+	AuditAllowed bool
+	// AuditSkip lists permission patterns that are never audited, e.g. "core.log" to keep the
+	// per-log-line check out of the trail.
+	AuditSkip []Permission
 }
 
 // NewDefaultAccessController creates a new DefaultAccessController.
 // If the provider is nil, it returns a controller that allows all actions.
 func NewDefaultAccessController(provider RBACProvider) AccessController {
+	return NewDefaultAccessControllerWithOptions(provider, AccessControllerOptions{})
+}
+
+// NewDefaultAccessControllerWithOptions creates a DefaultAccessController with a decision cache and
+// audit sink configured by opts. If the provider is nil, it returns a controller that allows all actions.
+func NewDefaultAccessControllerWithOptions(provider RBACProvider, opts AccessControllerOptions) AccessController {
 	if provider == nil {
 		return &allowAllAccessController{}
 	}
-	return &DefaultAccessController{rbacProvider: provider}
+	d := &DefaultAccessController{
+		rbacProvider: provider,
+		audit:        opts.Audit,
+		auditAllowed: opts.AuditAllowed,
+	}
+	switch {
+	case opts.CacheSize == 0:
+		d.decisions = newDecisionCache(DefaultDecisionCacheSize)
+	case opts.CacheSize > 0:
+		d.decisions = newDecisionCache(opts.CacheSize)
+	}
+	if len(opts.AuditSkip) > 0 {
+		d.auditSkip, _ = compilePermissions("", opts.AuditSkip)
+	}
+	return d
 }
 
 // allowAllAccessController is an implementation of AccessController that grants all permissions.
@@ -101,6 +145,13 @@ func (a *allowAllAccessController) CanReloadModule(ctx context.Context, p Princi
 	return true
 }
 func (a *allowAllAccessController) HasPermission(p Principal, perm Permission) bool { return true }
+func (a *allowAllAccessController) Explain(p Principal, perm Permission) Explanation {
+	e := Explanation{Permission: perm, Allowed: true, Reason: "no RBAC provider configured, all access is allowed"}
+	if p != nil {
+		e.Principal = p.ID()
+	}
+	return e
+}
 
 func (d *DefaultAccessController) CanLog(ctx context.Context, p Principal) bool {
 	return d.HasPermission(p, "core.log")
@@ -134,47 +185,68 @@ func sanitizePermissionComponent(component string) string {
 	return permissionSanitizer.ReplaceAllString(component, "_")
 }
 
-// HasPermission checks if the principal has the required permission, either directly or through one of its roles.
-// It supports wildcards, where a permission like "core.module.*" grants access to any permission starting with "core.module.".
+// HasPermission checks if the principal holds the required permission, either directly or through
+// one of its roles and the roles they inherit. See permission.go for the pattern syntax; a matching
+// deny rule overrides any allow rule.
+// Decisions are cached until the provider's roles change.
 func (d *DefaultAccessController) HasPermission(p Principal, perm Permission) bool {
-	// First check if the principal has the permission directly in its roles
-	for _, roleName := range p.Roles() {
-		// Check if the role name itself matches the permission (direct role-based access)
-		if roleName == string(perm) {
-			return true
-		}
-		// Check for wildcard permissions in role names
-		if len(roleName) > 2 && roleName[len(roleName)-2:] == ".*" {
-			prefix := roleName[:len(roleName)-2]
-			if len(perm) > len(prefix) && string(perm)[:len(prefix)] == prefix {
-				return true
-			}
-		}
+	if p == nil || d.decisions == nil {
+		return d.record(p, d.evaluate(p, perm)).Allowed
+	}
+
+	var version uint64
+	if v, ok := d.rbacProvider.(VersionedRBACProvider); ok {
+		version = v.Version()
+	}
+	key := decisionKey(p, perm)
+	e, ok := d.decisions.get(key, version)
+	if !ok {
+		e = d.evaluate(p, perm)
+		d.decisions.put(key, version, e)
+	}
+	return d.record(p, e).Allowed
+}
+
+// InvalidateCache drops all cached decisions. Providers implementing VersionedRBACProvider
+// do not need it; others should trigger it whenever their roles change.
+func (d *DefaultAccessController) InvalidateCache() {
+	if d.decisions != nil {
+		d.decisions.reset()
 	}
+}
 
-	// If no RBAC provider is configured, we rely on the direct role check above
-	if d.rbacProvider == nil {
-		return false
-	}
-
-	// Check permissions from all roles assigned to the principal via RBAC provider
-	for _, roleName := range p.Roles() {
-		if role, ok := d.rbacProvider.GetRole(roleName); ok {
-			for _, rolePerm := range role.Permissions {
-				if rolePerm == perm {
-					return true
-				}
-				// Check for wildcard permission
-				if len(rolePerm) > 2 && rolePerm[len(rolePerm)-2:] == ".*" {
-					prefix := rolePerm[:len(rolePerm)-2]
-					if len(perm) > len(prefix) && perm[:len(prefix)] == prefix {
-						return true
-					}
-				}
-			}
+// record passes a decision to the audit sink if it should be audited, and returns it.
+func (d *DefaultAccessController) record(p Principal, e Explanation) Explanation {
+	if d.audit == nil || (e.Allowed && !d.auditAllowed) {
+		return e
+	}
+	if d.auditSkip != nil {
+		skip := false
+		d.auditSkip.match(strings.Split(string(e.Permission), "."), 0, func(permissionRule) { skip = true })
+		if skip {
+			return e
 		}
 	}
-	return false
+	event := AuditEvent{
+		Time:       time.Now(),
+		Principal:  e.Principal,
+		Permission: e.Permission,
+		Allowed:    e.Allowed,
+		Rule:       e.Rule,
+		Role:       e.Role,
+		Reason:     e.Reason,
+		Caller:     auditCaller(),
+	}
+	if p != nil {
+		event.PrincipalType = p.Type()
+	}
+	d.audit.Record(event)
+	return e
+}
+
+// Explain reports whether the principal holds perm and which rule and role decided it.
+func (d *DefaultAccessController) Explain(p Principal, perm Permission) Explanation {
+	return d.evaluate(p, perm)
 }
 
 // Principal represents the entity performing an action (e.g., a user, a module, a gateway).
@@ -188,11 +260,29 @@ type Principal interface {
 	Roles() []string
 }
 
+// AttributedPrincipal is implemented by principals that carry attributes such as a tenant,
+// region or game shard. Attributes are used by ABAC policy conditions.
+type AttributedPrincipal interface {
+	Principal
+	// Attributes returns the principal's attributes. The returned map must not be modified.
+	Attributes() map[string]interface{}
+}
+
+// PrincipalAttributes returns the attributes of p, or nil if p carries none.
+func PrincipalAttributes(p Principal) map[string]interface{} {
+	if ap, ok := p.(AttributedPrincipal); ok {
+		return ap.Attributes()
+	}
+	return nil
+}
+
 // DefaultPrincipal is a simple implementation of Principal for internal components.
 type DefaultPrincipal struct {
 	id            string
 	principalType string
 	roles         []string
+	attributes    map[string]interface{}
+	permissions   []Permission
 }
 
 // NewDefaultPrincipal creates a new DefaultPrincipal.
@@ -204,6 +294,20 @@ func NewDefaultPrincipal(id, principalType string, roles []string) *DefaultPrinc
 	}
 }
 
+// NewPrincipalWithAttributes creates a new DefaultPrincipal carrying the given attributes.
+func NewPrincipalWithAttributes(id, principalType string, roles []string, attributes map[string]interface{}) *DefaultPrincipal {
+	p := NewDefaultPrincipal(id, principalType, roles)
+	p.attributes = attributes
+	return p
+}
+
+// WithPermissions grants the principal permissions directly, independent of its roles, and
+// returns it. This is meant for kernel components rather than end users.
+func (p *DefaultPrincipal) WithPermissions(perms ...Permission) *DefaultPrincipal {
+	p.permissions = append(p.permissions, perms...)
+	return p
+}
+
 func (p *DefaultPrincipal) ID() string {
 	return p.id
 }
@@ -215,3 +319,27 @@ func (p *DefaultPrincipal) Type() string {
 func (p *DefaultPrincipal) Roles() []string {
 	return p.roles
 }
+
+// Attributes returns the principal's attributes, which may be nil.
+func (p *DefaultPrincipal) Attributes() map[string]interface{} {
+	return p.attributes
+}
+
+// Permissions returns the permissions granted directly to the principal.
+func (p *DefaultPrincipal) Permissions() []Permission {
+	return p.permissions
+}
+
+// componentPrincipals caches the principals returned by ComponentPrincipal.
+var componentPrincipals sync.Map // map[string]Principal
+
+// ComponentPrincipal returns the shared principal of type "component" for the named component.
+// Packages that check permissions on hot paths, such as the logger and metrics, use it instead
+// of allocating a new principal for every call.
+func ComponentPrincipal(name string) Principal {
+	if p, ok := componentPrincipals.Load(name); ok {
+		return p.(Principal)
+	}
+	p, _ := componentPrincipals.LoadOrStore(name, NewDefaultPrincipal(name, "component", []string{}))
+	return p.(Principal)
+}
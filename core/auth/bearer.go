@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" header value.
+// The scheme is matched case-insensitively.
+func BearerToken(header string) (string, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return "", ErrMissingCredentials
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", fmt.Errorf("%w: expected a bearer token", ErrInvalidToken)
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// AuthenticateBearer turns an Authorization header value into a principal using factory and
+// returns ctx with that principal attached.
+func AuthenticateBearer(ctx context.Context, factory PrincipalFactory, header string) (context.Context, error) {
+	token, err := BearerToken(header)
+	if err != nil {
+		return ctx, err
+	}
+	p, err := factory.NewPrincipal(ctx, token)
+	if err != nil {
+		return ctx, err
+	}
+	return ContextWithPrincipal(ctx, p), nil
+}
+
+// BearerMiddleware is an HTTP middleware for gateways that authenticates each request's bearer
+// token with factory and stores the principal in the request context. Requests without a
+// valid token are rejected with 401 Unauthorized.
+func BearerMiddleware(factory PrincipalFactory) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := AuthenticateBearer(r.Context(), factory, r.Header.Get("Authorization"))
+			if err != nil {
+				challenge := `Bearer`
+				if !errors.Is(err, ErrMissingCredentials) {
+					challenge = `Bearer error="invalid_token"`
+				}
+				w.Header().Set("WWW-Authenticate", challenge)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -1,29 +1,164 @@
 package auth
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
 
-// ConfigRBACProvider implements the RBACProvider interface using roles defined in the application config.
-type ConfigRBACProvider struct {
-	mu    sync.RWMutex
-	roles map[string]*Role
+// roleStore holds a set of roles that can be replaced as a whole. Readers always see either
+// the old or the new set, never a mix of both.
+type roleStore struct {
+	mu      sync.RWMutex
+	roles   map[string]*Role
+	version atomic.Uint64 // Incremented on every replacement, see VersionedRBACProvider
 }
 
-// NewConfigRBACProvider creates a new RBAC provider based on the auth configuration.
-func NewConfigRBACProvider(roles []Role) RBACProvider {
+// GetRole retrieves a role by name.
+func (s *roleStore) GetRole(name string) (*Role, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	role, ok := s.roles[name]
+	return role, ok
+}
+
+// Roles returns a copy of the current roles, sorted by name.
+func (s *roleStore) Roles() []Role {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	roles := make([]Role, 0, len(s.roles))
+	for _, role := range s.roles {
+		roles = append(roles, *role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+// swap validates roles and replaces the current set with them. On error the current set is kept.
+func (s *roleStore) swap(roles []Role) error {
+	if err := ValidateRoles(roles); err != nil {
+		return err
+	}
+	s.replace(roles)
+	return nil
+}
+
+func (s *roleStore) replace(roles []Role) {
 	roleMap := make(map[string]*Role, len(roles))
 	for i := range roles {
 		role := roles[i]
 		roleMap[role.Name] = &role
 	}
-	return &ConfigRBACProvider{
-		roles: roleMap,
-	}
+	s.mu.Lock()
+	s.roles = roleMap
+	s.version.Add(1)
+	s.mu.Unlock()
 }
 
-// GetRole retrieves a role by name from the provider's internal map.
-func (p *ConfigRBACProvider) GetRole(name string) (*Role, bool) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	role, ok := p.roles[name]
-	return role, ok
+// Version changes every time the roles are replaced.
+func (s *roleStore) Version() uint64 {
+	return s.version.Load()
+}
+
+// ConfigRBACProvider implements the RBACProvider interface using roles defined in the application config.
+type ConfigRBACProvider struct {
+	roleStore
+}
+
+// NewConfigRBACProvider creates a new RBAC provider based on the auth configuration.
+func NewConfigRBACProvider(roles []Role) *ConfigRBACProvider {
+	p := &ConfigRBACProvider{}
+	p.replace(roles)
+	return p
+}
+
+// SetRoles validates roles and atomically replaces the provider's roles with them, typically
+// from a config change hook. If validation fails the previous roles stay in effect.
+func (p *ConfigRBACProvider) SetRoles(roles []Role) error {
+	return p.swap(roles)
+}
+
+// ValidateRoles checks role definitions before they are applied: names must be present and
+// unique, permission patterns well-formed, and inherited roles must exist without forming a cycle.
+// All problems are reported together.
+func ValidateRoles(roles []Role) error {
+	var errs []error
+	byName := make(map[string]*Role, len(roles))
+	for i := range roles {
+		role := &roles[i]
+		if strings.TrimSpace(role.Name) == "" {
+			errs = append(errs, fmt.Errorf("role #%d: missing name", i))
+			continue
+		}
+		if _, dup := byName[role.Name]; dup {
+			errs = append(errs, fmt.Errorf("role %q: defined more than once", role.Name))
+			continue
+		}
+		byName[role.Name] = role
+		for _, perm := range role.Permissions {
+			if err := ValidatePermission(perm); err != nil {
+				errs = append(errs, fmt.Errorf("role %q: %w", role.Name, err))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names) // Deterministic error order
+
+	for _, name := range names {
+		role := byName[name]
+		for _, parent := range role.Inherits {
+			if _, ok := byName[parent]; !ok {
+				errs = append(errs, fmt.Errorf("role %q: inherits unknown role %q", role.Name, parent))
+			}
+		}
+	}
+
+	// Depth-first search for inheritance cycles. Each cycle is reported once.
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(byName))
+	var visit func(name string, path []string)
+	visit = func(name string, path []string) {
+		switch state[name] {
+		case visiting:
+			start := 0
+			for i, n := range path {
+				if n == name {
+					start = i
+					break
+				}
+			}
+			errs = append(errs, fmt.Errorf("inheritance cycle: %s", strings.Join(append(path[start:], name), " -> ")))
+			return
+		case done:
+			return
+		}
+		role, ok := byName[name]
+		if !ok {
+			return
+		}
+		state[name] = visiting
+		for _, parent := range role.Inherits {
+			visit(parent, append(path, name))
+		}
+		state[name] = done
+	}
+	for _, name := range names {
+		visit(name, nil)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid roles: %w", errors.Join(errs...))
+	}
+	return nil
 }
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// DefaultDecisionCacheSize is the number of permission decisions a DefaultAccessController
+// remembers when no size is configured.
+const DefaultDecisionCacheSize = 4096
+
+// VersionedRBACProvider is implemented by providers whose roles can change at runtime. Version
+// must change whenever the roles do; cached decisions made under an older version are discarded.
+// Providers that cannot report versions should call InvalidateCache on the controller instead.
+type VersionedRBACProvider interface {
+	RBACProvider
+	Version() uint64
+}
+
+// decisionCache is a bounded LRU cache of permission decisions.
+type decisionCache struct {
+	mu      sync.Mutex
+	size    int
+	version uint64 // Provider version the entries were computed under
+	entries map[string]*list.Element
+	order   *list.List // Front is most recently used
+}
+
+type cacheEntry struct {
+	key         string
+	explanation Explanation
+}
+
+func newDecisionCache(size int) *decisionCache {
+	return &decisionCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// get returns the cached decision for key if it was made under version.
+func (c *decisionCache) get(key string, version uint64) (Explanation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version != c.version {
+		c.resetLocked(version)
+		return Explanation{}, false
+	}
+	el, ok := c.entries[key]
+	if !ok {
+		return Explanation{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).explanation, true
+}
+
+// put stores a decision made under version, evicting the least recently used one if full.
+func (c *decisionCache) put(key string, version uint64, e Explanation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version != c.version {
+		c.resetLocked(version)
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).explanation = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, explanation: e})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// reset drops every cached decision.
+func (c *decisionCache) reset() {
+	c.mu.Lock()
+	c.resetLocked(c.version)
+	c.mu.Unlock()
+}
+
+func (c *decisionCache) resetLocked(version uint64) {
+	c.version = version
+	c.entries = make(map[string]*list.Element, c.size)
+	c.order.Init()
+}
+
+// decisionKey identifies a decision by everything RBAC looks at: the principal's identity,
+// roles and direct permissions, and the permission being checked. Attributes do not affect
+// RBAC decisions and are left out.
+func decisionKey(p Principal, perm Permission) string {
+	var b strings.Builder
+	b.WriteString(p.Type())
+	b.WriteByte(0)
+	b.WriteString(p.ID())
+	b.WriteByte(0)
+	for _, role := range p.Roles() {
+		b.WriteString(role)
+		b.WriteByte(1)
+	}
+	if holder, ok := p.(PermissionHolder); ok {
+		b.WriteByte(0)
+		for _, direct := range holder.Permissions() {
+			b.WriteString(string(direct))
+			b.WriteByte(1)
+		}
+	}
+	b.WriteByte(0)
+	b.WriteString(string(perm))
+	return b.String()
+}
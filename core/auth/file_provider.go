@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// fileReloadDelay coalesces the bursts of events editors and deploy tools produce for a single save.
+const fileReloadDelay = 100 * time.Millisecond
+
+// roleFile is the layout of a role file:
+//
+//	roles:
+//	  - name: moderator
+//	    inherits: [player]
+//	    permissions: ["service.moderation.*"]
+type roleFile struct {
+	Roles []struct {
+		Name        string   `yaml:"name" json:"name"`
+		Permissions []string `yaml:"permissions" json:"permissions"`
+		Inherits    []string `yaml:"inherits" json:"inherits"`
+	} `yaml:"roles" json:"roles"`
+}
+
+// FileRBACProvider serves roles from a YAML or JSON file and, once Watch is called, reloads
+// them whenever the file changes. Invalid edits are rejected and the previous roles are kept.
+type FileRBACProvider struct {
+	roleStore
+	path string
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// NewFileRBACProvider loads and validates the role file at path. Files ending in ".json" are
+// parsed as JSON, anything else as YAML.
+func NewFileRBACProvider(path string) (*FileRBACProvider, error) {
+	p := &FileRBACProvider{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload rereads the role file and applies it if it is valid.
+func (p *FileRBACProvider) Reload() error {
+	roles, err := readRoleFile(p.path)
+	if err != nil {
+		return err
+	}
+	if err := p.swap(roles); err != nil {
+		return fmt.Errorf("role file %s: %w", p.path, err)
+	}
+	return nil
+}
+
+// Watch reloads the role file whenever it changes until Close is called. onReload, if not nil,
+// is called after every reload attempt with its result.
+func (p *FileRBACProvider) Watch(onReload func(error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.watcher != nil {
+		return fmt.Errorf("role file %s is already being watched", p.path)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch role file: %w", err)
+	}
+	// Watch the directory rather than the file so that atomic replacements (write to a temp
+	// file, then rename) are picked up too.
+	if err := watcher.Add(filepath.Dir(p.path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watch role file: %w", err)
+	}
+	p.watcher = watcher
+	p.done = make(chan struct{})
+	go p.watch(watcher, p.done, onReload)
+	return nil
+}
+
+func (p *FileRBACProvider) watch(watcher *fsnotify.Watcher, done chan struct{}, onReload func(error)) {
+	defer close(done)
+	name := filepath.Clean(p.path)
+	var timer *time.Timer
+	var fire <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != name || event.Op == fsnotify.Chmod {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(fileReloadDelay)
+			} else {
+				timer.Reset(fileReloadDelay)
+			}
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			err := p.Reload()
+			if onReload != nil {
+				onReload(err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			if onReload != nil {
+				onReload(fmt.Errorf("watch role file %s: %w", p.path, err))
+			}
+		}
+	}
+}
+
+// Close stops watching the role file. The last loaded roles remain available.
+func (p *FileRBACProvider) Close() error {
+	p.mu.Lock()
+	watcher, done := p.watcher, p.done
+	p.watcher, p.done = nil, nil
+	p.mu.Unlock()
+	if watcher == nil {
+		return nil
+	}
+	err := watcher.Close()
+	<-done
+	return err
+}
+
+func readRoleFile(path string) ([]Role, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read role file: %w", err)
+	}
+	var doc roleFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse role file %s: %w", path, err)
+	}
+
+	roles := make([]Role, len(doc.Roles))
+	for i, r := range doc.Roles {
+		roles[i] = Role{Name: r.Name, Inherits: r.Inherits}
+		for _, perm := range r.Permissions {
+			roles[i].Permissions = append(roles[i].Permissions, Permission(perm))
+		}
+	}
+	return roles, nil
+}
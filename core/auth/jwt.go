@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// PrincipalFactory turns a credential presented by a client, such as a bearer token, into a Principal.
+// Gateways use it to authenticate requests before placing the principal in the request context.
+type PrincipalFactory interface {
+	NewPrincipal(ctx context.Context, credential string) (Principal, error)
+}
+
+// PrincipalFactoryFunc adapts a function to the PrincipalFactory interface.
+type PrincipalFactoryFunc func(ctx context.Context, credential string) (Principal, error)
+
+// NewPrincipal calls f(ctx, credential).
+func (f PrincipalFactoryFunc) NewPrincipal(ctx context.Context, credential string) (Principal, error) {
+	return f(ctx, credential)
+}
+
+var (
+	// ErrInvalidToken is returned when a token is malformed, badly signed, expired or otherwise rejected.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrMissingCredentials is returned when a request carries no credentials at all.
+	ErrMissingCredentials = errors.New("missing credentials")
+)
+
+// Supported JWT signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// JWTConfig configures JWT verification and how verified claims map to a Principal.
+// At least one of SecretFile, PublicKeyFiles, JWKSFile or JWKSURL must be set.
+type JWTConfig struct {
+	// Algorithms lists the accepted signing algorithms. Empty accepts HS256, RS256 and EdDSA,
+	// each only with keys of the matching type.
+	Algorithms []string `mapstructure:"algorithms"`
+	// Issuer, if set, must match the token's "iss" claim.
+	Issuer string `mapstructure:"issuer"`
+	// Audience, if set, must contain at least one entry of the token's "aud" claim.
+	Audience []string `mapstructure:"audience"`
+	// LeewaySeconds tolerates clock skew when checking "exp", "nbf" and "iat".
+	LeewaySeconds int `mapstructure:"leeway_seconds"`
+	// AllowMissingExpiry accepts tokens without an "exp" claim. By default they are rejected.
+	AllowMissingExpiry bool `mapstructure:"allow_missing_expiry"`
+
+	SecretFile         string   `mapstructure:"secret_file"`          // Shared secret for HS256
+	PublicKeyFiles     []string `mapstructure:"public_key_files"`     // PEM encoded RSA or Ed25519 public keys or certificates
+	JWKSFile           string   `mapstructure:"jwks_file"`            // Local JWKS document
+	JWKSURL            string   `mapstructure:"jwks_url"`             // Remote JWKS document, refreshed periodically
+	JWKSRefreshSeconds int      `mapstructure:"jwks_refresh_seconds"` // Defaults to one hour
+
+	Claims ClaimMapping `mapstructure:"claims"`
+}
+
+// ClaimMapping selects the claims a Principal is built from. Paths are dot-separated and
+// descend into nested objects, e.g. "realm_access.roles". A claim whose name itself contains
+// dots (such as a namespaced URL) is matched literally first.
+type ClaimMapping struct {
+	ID          string `mapstructure:"id"`           // Principal ID claim, defaults to "sub"
+	Type        string `mapstructure:"type"`         // Principal type claim, optional
+	DefaultType string `mapstructure:"default_type"` // Type used when Type is unset or absent, defaults to "user"
+	Roles       string `mapstructure:"roles"`        // Roles claim, defaults to "roles"; a list or a space/comma separated string
+
+	// Attributes maps principal attribute names to claim paths, e.g. {"tenant": "org.id"}.
+	// Absent claims are left out of the principal's attributes.
+	Attributes map[string]string `mapstructure:"attributes"`
+}
+
+// JWTPrincipalFactory is a PrincipalFactory that verifies signed JWTs.
+type JWTPrincipalFactory struct {
+	keys   keySource
+	parser *jwt.Parser
+	claims ClaimMapping
+}
+
+// NewJWTPrincipalFactory loads the configured keys and returns a factory that verifies tokens with them.
+func NewJWTPrincipalFactory(cfg JWTConfig) (*JWTPrincipalFactory, error) {
+	algorithms := cfg.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{AlgHS256, AlgRS256, AlgEdDSA}
+	}
+	for _, alg := range algorithms {
+		switch alg {
+		case AlgHS256, AlgRS256, AlgEdDSA:
+		default:
+			return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+		}
+	}
+
+	keys, err := loadKeySource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithLeeway(time.Duration(cfg.LeewaySeconds) * time.Second),
+		jwt.WithIssuedAt(),
+	}
+	if !cfg.AllowMissingExpiry {
+		opts = append(opts, jwt.WithExpirationRequired())
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if len(cfg.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(cfg.Audience...))
+	}
+
+	claims := cfg.Claims
+	if claims.ID == "" {
+		claims.ID = "sub"
+	}
+	if claims.DefaultType == "" {
+		claims.DefaultType = "user"
+	}
+	if claims.Roles == "" {
+		claims.Roles = "roles"
+	}
+
+	return &JWTPrincipalFactory{
+		keys:   keys,
+		parser: jwt.NewParser(opts...),
+		claims: claims,
+	}, nil
+}
+
+// Verify checks the token's signature and standard claims ("exp", "nbf", "iat", and "iss"
+// and "aud" if configured) and returns its claims.
+func (f *JWTPrincipalFactory) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	_, err := f.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return f.keys.keysFor(ctx, kid, t.Method.Alg())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+// NewPrincipal verifies token and builds a Principal from its claims.
+func (f *JWTPrincipalFactory) NewPrincipal(ctx context.Context, token string) (Principal, error) {
+	claims, err := f.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := claimAt(claims, f.claims.ID).(string)
+	if id == "" {
+		return nil, fmt.Errorf("%w: claim %q is missing or not a string", ErrInvalidToken, f.claims.ID)
+	}
+
+	principalType := f.claims.DefaultType
+	if f.claims.Type != "" {
+		if t, ok := claimAt(claims, f.claims.Type).(string); ok && t != "" {
+			principalType = t
+		}
+	}
+
+	roles, err := rolesFrom(claimAt(claims, f.claims.Roles))
+	if err != nil {
+		return nil, fmt.Errorf("%w: claim %q: %v", ErrInvalidToken, f.claims.Roles, err)
+	}
+
+	var attributes map[string]interface{}
+	for name, path := range f.claims.Attributes {
+		if v := claimAt(claims, path); v != nil {
+			if attributes == nil {
+				attributes = make(map[string]interface{}, len(f.claims.Attributes))
+			}
+			attributes[name] = v
+		}
+	}
+	return NewPrincipalWithAttributes(id, principalType, roles, attributes), nil
+}
+
+// claimAt resolves a dot-separated claim path, returning nil if it does not exist.
+func claimAt(claims map[string]interface{}, path string) interface{} {
+	if v, ok := claims[path]; ok {
+		return v
+	}
+	var current interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if current, ok = m[part]; !ok {
+			return nil
+		}
+	}
+	return current
+}
+
+// rolesFrom converts a roles claim to a list of role names. A missing claim means no roles.
+func rolesFrom(v interface{}) ([]string, error) {
+	switch roles := v.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return strings.FieldsFunc(roles, func(r rune) bool { return r == ' ' || r == ',' }), nil
+	case []interface{}:
+		result := make([]string, 0, len(roles))
+		for _, role := range roles {
+			s, ok := role.(string)
+			if !ok {
+				return nil, fmt.Errorf("role %v is not a string", role)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("expected a list or a string, got %T", v)
+	}
+}
@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// defaultJWKSRefresh is how often a remote JWKS document is refetched.
+	defaultJWKSRefresh = time.Hour
+	// jwksMissRefetch is the minimum interval between refetches triggered by an unknown key ID,
+	// so that tokens with bogus key IDs cannot hammer the JWKS endpoint.
+	jwksMissRefetch = 30 * time.Second
+)
+
+// verificationKey is a key tokens may be signed with. kid and alg are optional restrictions.
+type verificationKey struct {
+	kid string
+	alg string
+	key interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// keySource supplies the keys that may have signed a token with the given key ID and algorithm.
+type keySource interface {
+	keysFor(ctx context.Context, kid, alg string) (interface{}, error)
+}
+
+// loadKeySource builds the key source described by cfg.
+func loadKeySource(cfg JWTConfig) (keySource, error) {
+	var static []verificationKey
+
+	if cfg.SecretFile != "" {
+		secret, err := os.ReadFile(cfg.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT secret: %w", err)
+		}
+		secret = []byte(strings.TrimSpace(string(secret)))
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("JWT secret file %s is empty", cfg.SecretFile)
+		}
+		static = append(static, verificationKey{alg: AlgHS256, key: secret})
+	}
+
+	for _, path := range cfg.PublicKeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT public key: %w", err)
+		}
+		key, err := parsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse JWT public key %s: %w", path, err)
+		}
+		static = append(static, verificationKey{key: key})
+	}
+
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("read JWKS: %w", err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse JWKS %s: %w", cfg.JWKSFile, err)
+		}
+		static = append(static, keys...)
+	}
+
+	var remote *remoteJWKS
+	if cfg.JWKSURL != "" {
+		refresh := time.Duration(cfg.JWKSRefreshSeconds) * time.Second
+		if refresh <= 0 {
+			refresh = defaultJWKSRefresh
+		}
+		remote = &remoteJWKS{
+			url:     cfg.JWKSURL,
+			client:  &http.Client{Timeout: 10 * time.Second},
+			refresh: refresh,
+			now:     time.Now,
+		}
+	}
+
+	if len(static) == 0 && remote == nil {
+		return nil, fmt.Errorf("no JWT verification keys configured")
+	}
+	return &keySet{static: static, remote: remote}, nil
+}
+
+// keySet combines statically loaded keys with an optional remote JWKS document.
+type keySet struct {
+	static []verificationKey
+	remote *remoteJWKS
+}
+
+func (s *keySet) keysFor(ctx context.Context, kid, alg string) (interface{}, error) {
+	keys := s.static
+	if s.remote != nil {
+		remoteKeys, err := s.remote.keys(ctx, kid)
+		if err != nil && len(keys) == 0 {
+			return nil, err
+		}
+		keys = append(append([]verificationKey(nil), keys...), remoteKeys...)
+	}
+	return selectKeys(keys, kid, alg)
+}
+
+// selectKeys picks the keys compatible with alg. If a key is registered under kid, only
+// keys with that ID are used; otherwise keys without an ID are tried.
+func selectKeys(keys []verificationKey, kid, alg string) (interface{}, error) {
+	var byID, anonymous []jwt.VerificationKey
+	for _, k := range keys {
+		if !keyMatchesAlg(k, alg) {
+			continue
+		}
+		switch {
+		case kid != "" && k.kid == kid:
+			byID = append(byID, k.key)
+		case k.kid == "":
+			anonymous = append(anonymous, k.key)
+		}
+	}
+
+	candidates := byID
+	if len(candidates) == 0 {
+		candidates = anonymous
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no %s verification key found for key ID %q", alg, kid)
+	case 1:
+		return candidates[0], nil
+	default:
+		return jwt.VerificationKeySet{Keys: candidates}, nil
+	}
+}
+
+// keyMatchesAlg reports whether k may verify signatures made with alg.
+func keyMatchesAlg(k verificationKey, alg string) bool {
+	if k.alg != "" && k.alg != alg {
+		return false
+	}
+	switch k.key.(type) {
+	case []byte:
+		return alg == AlgHS256
+	case *rsa.PublicKey:
+		return alg == AlgRS256
+	case ed25519.PublicKey:
+		return alg == AlgEdDSA
+	default:
+		return false
+	}
+}
+
+// parsePublicKeyPEM parses a PEM encoded RSA or Ed25519 public key or certificate.
+func parsePublicKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// jwk is a single JSON Web Key (RFC 7517). Only the fields needed for verification are decoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"` // RSA modulus
+	E   string `json:"e"` // RSA exponent
+	X   string `json:"x"` // OKP public key
+	K   string `json:"k"` // Symmetric key
+}
+
+// parseJWKS decodes the signature keys of a JWKS document. Keys of unsupported types or
+// meant for encryption are skipped; a document without any usable key is an error.
+func parseJWKS(data []byte) ([]verificationKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var keys []verificationKey
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %w", i, k.Kid, err)
+		}
+		if key == nil {
+			continue // Unsupported key type
+		}
+		keys = append(keys, verificationKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no supported signature keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key material, returning nil for unsupported key types.
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 {
+			return nil, fmt.Errorf("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode public key: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key length %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("decode secret: %w", err)
+		}
+		return secret, nil
+	default:
+		return nil, nil
+	}
+}
+
+// remoteJWKS caches a JWKS document fetched over HTTP.
+type remoteJWKS struct {
+	url     string
+	client  *http.Client
+	refresh time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	cached   []verificationKey
+	fetched  time.Time
+	err      error     // Why the last fetch failed while nothing was cached
+	failedAt time.Time // When it failed; the error is returned until jwksMissRefetch has passed
+	inflight *jwksFetch
+}
+
+// jwksFetch is a fetch in progress that concurrent callers wait on rather than fetching again.
+type jwksFetch struct {
+	done chan struct{}
+}
+
+// keys returns the cached keys, refetching them when they are stale or kid is unknown.
+// If a refetch fails, the previously cached keys keep being used. The HTTP request is made
+// without holding r.mu, and only by one caller at a time; the others wait for its result.
+func (r *remoteJWKS) keys(ctx context.Context, kid string) ([]verificationKey, error) {
+	r.mu.Lock()
+	now := r.now()
+	age := now.Sub(r.fetched)
+	stale := r.cached == nil || age >= r.refresh
+	unknown := kid != "" && !hasKeyID(r.cached, kid) && age >= jwksMissRefetch
+	if !stale && !unknown {
+		defer r.mu.Unlock()
+		return r.cached, nil
+	}
+	if r.cached == nil && r.err != nil && now.Sub(r.failedAt) < jwksMissRefetch {
+		defer r.mu.Unlock()
+		return nil, r.err // The endpoint was down moments ago; don't queue up behind it again
+	}
+
+	if call := r.inflight; call != nil {
+		r.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("fetch JWKS %s: %w", r.url, ctx.Err())
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.result()
+	}
+	call := &jwksFetch{done: make(chan struct{})}
+	r.inflight = call
+	r.mu.Unlock()
+
+	keys, err := r.fetch(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inflight = nil
+	defer close(call.done)
+	switch {
+	case err == nil:
+		r.cached, r.err = keys, nil
+		r.fetched = r.now()
+	case r.cached != nil:
+		r.fetched = r.now() // Keep the cached keys, and throttle retries
+	default:
+		r.err, r.failedAt = err, r.now()
+	}
+	return r.result()
+}
+
+// result returns the cached keys or, if there are none, why fetching them failed.
+// r.mu must be held.
+func (r *remoteJWKS) result() ([]verificationKey, error) {
+	if r.cached == nil {
+		return nil, r.err
+	}
+	return r.cached, nil
+}
+
+func (r *remoteJWKS) fetch(ctx context.Context) ([]verificationKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS %s: %w", r.url, err)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS %s: %w", r.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS %s: unexpected status %s", r.url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS %s: %w", r.url, err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse JWKS %s: %w", r.url, err)
+	}
+	return keys, nil
+}
+
+func hasKeyID(keys []verificationKey, kid string) bool {
+	for _, k := range keys {
+		if k.kid == kid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,297 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"acacia/core/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":   "player-42",
+		"iss":   "https://id.example.com",
+		"aud":   "acacia",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"roles": []string{"player"},
+	}
+}
+
+func TestJWTPrincipalFactory_HS256(t *testing.T) {
+	secret := []byte("super-secret-signing-key")
+	f, err := auth.NewJWTPrincipalFactory(auth.JWTConfig{
+		SecretFile: writeFile(t, "secret", append(secret, '\n')),
+		Issuer:     "https://id.example.com",
+		Audience:   []string{"acacia"},
+	})
+	if err != nil {
+		t.Fatalf("NewJWTPrincipalFactory: %v", err)
+	}
+	ctx := context.Background()
+
+	p, err := f.NewPrincipal(ctx, sign(t, jwt.SigningMethodHS256, secret, "", validClaims()))
+	if err != nil {
+		t.Fatalf("NewPrincipal: %v", err)
+	}
+	if p.ID() != "player-42" || p.Type() != "user" || !reflect.DeepEqual(p.Roles(), []string{"player"}) {
+		t.Errorf("principal = %s/%s/%v", p.ID(), p.Type(), p.Roles())
+	}
+
+	tests := map[string]func(jwt.MapClaims){
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"missing expiry": func(c jwt.MapClaims) { delete(c, "exp") },
+		"not yet valid":  func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"missing sub":    func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			mutate(claims)
+			if _, err := f.NewPrincipal(ctx, sign(t, jwt.SigningMethodHS256, secret, "", claims)); !errors.Is(err, auth.ErrInvalidToken) {
+				t.Errorf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	if _, err := f.NewPrincipal(ctx, sign(t, jwt.SigningMethodHS256, []byte("other-key"), "", validClaims())); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("token signed with another key: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWTPrincipalFactory_RS256FromJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "rsa-1",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+
+	f, err := auth.NewJWTPrincipalFactory(auth.JWTConfig{
+		JWKSFile:   writeFile(t, "jwks.json", jwks),
+		Algorithms: []string{auth.AlgRS256},
+		Claims: auth.ClaimMapping{
+			ID:    "preferred_username",
+			Type:  "principal_type",
+			Roles: "realm_access.roles",
+			Attributes: map[string]string{
+				"tenant": "org.tenant",
+				"shard":  "shard",
+				"absent": "no_such_claim",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewJWTPrincipalFactory: %v", err)
+	}
+
+	claims := validClaims()
+	claims["preferred_username"] = "alice"
+	claims["principal_type"] = "admin"
+	claims["realm_access"] = map[string]interface{}{"roles": []string{"moderator", "player"}}
+	claims["org"] = map[string]interface{}{"tenant": "acme"}
+	claims["shard"] = "eu-1"
+	p, err := f.NewPrincipal(context.Background(), sign(t, jwt.SigningMethodRS256, key, "rsa-1", claims))
+	if err != nil {
+		t.Fatalf("NewPrincipal: %v", err)
+	}
+	if p.ID() != "alice" || p.Type() != "admin" || !reflect.DeepEqual(p.Roles(), []string{"moderator", "player"}) {
+		t.Errorf("principal = %s/%s/%v", p.ID(), p.Type(), p.Roles())
+	}
+	if attrs := auth.PrincipalAttributes(p); !reflect.DeepEqual(attrs, map[string]interface{}{"tenant": "acme", "shard": "eu-1"}) {
+		t.Errorf("attributes = %v", attrs)
+	}
+
+	// HS256 is not in the allowed algorithms, even if someone signs with the public modulus.
+	hsToken := sign(t, jwt.SigningMethodHS256, key.N.Bytes(), "rsa-1", claims)
+	if _, err := f.NewPrincipal(context.Background(), hsToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("HS256 token: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWTPrincipalFactory_EdDSAFromPEM(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	pemFile := writeFile(t, "ed25519.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	f, err := auth.NewJWTPrincipalFactory(auth.JWTConfig{
+		PublicKeyFiles: []string{pemFile},
+		Claims:         auth.ClaimMapping{Roles: "scope"},
+	})
+	if err != nil {
+		t.Fatalf("NewJWTPrincipalFactory: %v", err)
+	}
+
+	claims := validClaims()
+	claims["scope"] = "profile.read profile.write"
+	p, err := f.NewPrincipal(context.Background(), sign(t, jwt.SigningMethodEdDSA, priv, "", claims))
+	if err != nil {
+		t.Fatalf("NewPrincipal: %v", err)
+	}
+	if !reflect.DeepEqual(p.Roles(), []string{"profile.read", "profile.write"}) {
+		t.Errorf("Roles() = %v", p.Roles())
+	}
+}
+
+func TestJWTPrincipalFactory_RemoteJWKS(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{"kty": "OKP", "crv": "Ed25519", "kid": "ed-1", "x": base64.RawURLEncoding.EncodeToString(pub)}},
+		})
+	}))
+	defer srv.Close()
+
+	f, err := auth.NewJWTPrincipalFactory(auth.JWTConfig{JWKSURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewJWTPrincipalFactory: %v", err)
+	}
+	token := sign(t, jwt.SigningMethodEdDSA, priv, "ed-1", validClaims())
+	for i := 0; i < 3; i++ {
+		if _, err := f.NewPrincipal(context.Background(), token); err != nil {
+			t.Fatalf("NewPrincipal: %v", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("JWKS fetched %d times, want 1 (cached)", fetches)
+	}
+}
+
+func TestJWTPrincipalFactory_RemoteJWKSDown(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	f, err := auth.NewJWTPrincipalFactory(auth.JWTConfig{JWKSURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewJWTPrincipalFactory: %v", err)
+	}
+	token := sign(t, jwt.SigningMethodEdDSA, priv, "ed-1", validClaims())
+
+	// Concurrent verifications share one fetch, and later ones get the cached failure
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := f.NewPrincipal(context.Background(), token); err == nil {
+				t.Error("token accepted without verification keys")
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 3; i++ {
+		if _, err := f.NewPrincipal(context.Background(), token); err == nil || !strings.Contains(err.Error(), "503") {
+			t.Errorf("NewPrincipal = %v, want the cached fetch error", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times while down, want 1", n)
+	}
+}
+
+func TestBearerMiddleware(t *testing.T) {
+	secret := []byte("middleware-secret")
+	f, err := auth.NewJWTPrincipalFactory(auth.JWTConfig{SecretFile: writeFile(t, "secret", secret)})
+	if err != nil {
+		t.Fatalf("NewJWTPrincipalFactory: %v", err)
+	}
+	handler := auth.BearerMiddleware(f)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.PrincipalFromContext(r.Context()).ID()))
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		body   string
+	}{
+		{"valid token", "Bearer " + sign(t, jwt.SigningMethodHS256, secret, "", validClaims()), http.StatusOK, "player-42"},
+		{"lowercase scheme", "bearer " + sign(t, jwt.SigningMethodHS256, secret, "", validClaims()), http.StatusOK, "player-42"},
+		{"no header", "", http.StatusUnauthorized, ""},
+		{"basic auth", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, ""},
+		{"garbage token", "Bearer not.a.jwt", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Permission patterns are dot-separated and matched segment by segment:
+//
+//	service.chat.room.access   exact match
+//	service.*.room.access      "*" in the middle matches exactly one segment
+//	service.chat.call.Get*     glob characters (*, ?, [...]) match within a single segment
+//	service.**.access          "**" matches zero or more segments
+//	service.chat.*             a trailing "*" matches one or more segments
+//	!service.chat.call.Ban     a leading "!" denies instead of allowing
+//
+// A matching deny rule always overrides allow rules, whichever role they come from.
+
+// denyPrefix marks a permission pattern as an explicit deny rule.
+const denyPrefix = "!"
+
+// permissionRule is a single compiled allow or deny pattern.
+type permissionRule struct {
+	pattern Permission // Original pattern, including any deny prefix
+	deny    bool
+	role    string // Role the rule belongs to, empty for permissions held directly by a principal
+}
+
+// permissionTrie indexes permission patterns by segment so that a check only visits the
+// branches that can match, instead of testing every pattern of every role.
+type permissionTrie struct {
+	literal map[string]*permissionTrie // Exact segments
+	globs   []globEdge                 // Segments with glob characters, including a mid-pattern "*"
+	any     *permissionTrie            // "**"
+	rest    []permissionRule           // Rules ending in a trailing "*", matching one or more further segments
+	rules   []permissionRule           // Rules ending at this node
+}
+
+type globEdge struct {
+	pattern string
+	next    *permissionTrie
+}
+
+// compilePermissions builds a trie from patterns. Invalid patterns are reported but the valid
+// ones are still compiled, so one typo does not lock everyone out.
+func compilePermissions(role string, patterns []Permission) (*permissionTrie, error) {
+	t := &permissionTrie{}
+	var errs []string
+	for _, p := range patterns {
+		if err := t.insert(role, p); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return t, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return t, nil
+}
+
+// ValidatePermission reports whether p is a well-formed permission pattern.
+func ValidatePermission(p Permission) error {
+	return (&permissionTrie{}).insert("", p)
+}
+
+func (t *permissionTrie) insert(role string, p Permission) error {
+	rule := permissionRule{pattern: p, role: role}
+	body := string(p)
+	if strings.HasPrefix(body, denyPrefix) {
+		rule.deny = true
+		body = body[len(denyPrefix):]
+	}
+	if body == "" {
+		return fmt.Errorf("invalid permission %q: empty pattern", p)
+	}
+	segments := strings.Split(body, ".")
+	for _, seg := range segments {
+		if seg == "" {
+			return fmt.Errorf("invalid permission %q: empty segment", p)
+		}
+		if strings.Contains(seg, "**") && seg != "**" {
+			return fmt.Errorf("invalid permission %q: \"**\" must be a whole segment", p)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid permission %q: bad glob %q", p, seg)
+		}
+	}
+
+	node := t
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch {
+		case seg == "*" && last:
+			node.rest = append(node.rest, rule)
+			return nil
+		case seg == "**":
+			if node.any == nil {
+				node.any = &permissionTrie{}
+			}
+			node = node.any
+		case strings.ContainsAny(seg, "*?["):
+			node = node.glob(seg)
+		default:
+			if node.literal == nil {
+				node.literal = make(map[string]*permissionTrie)
+			}
+			next, ok := node.literal[seg]
+			if !ok {
+				next = &permissionTrie{}
+				node.literal[seg] = next
+			}
+			node = next
+		}
+	}
+	node.rules = append(node.rules, rule)
+	return nil
+}
+
+func (t *permissionTrie) glob(pattern string) *permissionTrie {
+	for _, g := range t.globs {
+		if g.pattern == pattern {
+			return g.next
+		}
+	}
+	next := &permissionTrie{}
+	t.globs = append(t.globs, globEdge{pattern: pattern, next: next})
+	return next
+}
+
+// match calls visit for every rule matching segs[i:].
+func (t *permissionTrie) match(segs []string, i int, visit func(permissionRule)) {
+	if t == nil {
+		return
+	}
+	if t.any != nil {
+		for j := i; j <= len(segs); j++ {
+			t.any.match(segs, j, visit)
+		}
+	}
+	if i == len(segs) {
+		for _, r := range t.rules {
+			visit(r)
+		}
+		return
+	}
+	for _, r := range t.rest {
+		visit(r)
+	}
+	if next, ok := t.literal[segs[i]]; ok {
+		next.match(segs, i+1, visit)
+	}
+	for _, g := range t.globs {
+		if ok, _ := path.Match(g.pattern, segs[i]); ok {
+			g.next.match(segs, i+1, visit)
+		}
+	}
+}
+
+// Explanation describes why a permission check was granted or denied.
+type Explanation struct {
+	Principal  string
+	Permission Permission
+	Allowed    bool
+	// Rule is the pattern that decided the check, empty if nothing matched.
+	Rule Permission
+	// Role holds Rule; empty when the principal holds the permission directly.
+	Role string
+	// Via lists the roles Role was reached through, starting with one of the principal's own
+	// roles, when it was inherited.
+	Via    []string
+	Reason string
+}
+
+// String renders the explanation on a single line for logs and CLI output.
+func (e Explanation) String() string {
+	verdict := "denied"
+	if e.Allowed {
+		verdict = "allowed"
+	}
+	return fmt.Sprintf("%s %s for %q: %s", e.Permission, verdict, e.Principal, e.Reason)
+}
+
+// PermissionExplainer is implemented by access controllers that can explain their decisions.
+type PermissionExplainer interface {
+	Explain(p Principal, perm Permission) Explanation
+}
+
+// Explain asks ac why p does or does not hold perm. Controllers that cannot explain their
+// decisions still report the outcome.
+func Explain(ac AccessController, p Principal, perm Permission) Explanation {
+	if e, ok := ac.(PermissionExplainer); ok {
+		return e.Explain(p, perm)
+	}
+	e := Explanation{Permission: perm, Allowed: ac.HasPermission(p, perm), Reason: "access controller does not provide explanations"}
+	if p != nil {
+		e.Principal = p.ID()
+	}
+	return e
+}
+
+// PermissionHolder is implemented by principals that are granted permissions directly rather
+// than through roles, such as kernel components.
+type PermissionHolder interface {
+	Permissions() []Permission
+}
+
+// compiledRole caches the trie of a role so that it is only rebuilt when the provider returns
+// a different role value.
+type compiledRole struct {
+	role *Role
+	trie *permissionTrie
+}
+
+// roleCache holds compiled roles by name.
+type roleCache struct {
+	mu    sync.RWMutex
+	roles map[string]compiledRole
+}
+
+func (c *roleCache) get(role *Role) *permissionTrie {
+	c.mu.RLock()
+	cached, ok := c.roles[role.Name]
+	c.mu.RUnlock()
+	if ok && cached.role == role {
+		return cached.trie
+	}
+
+	trie, _ := compilePermissions(role.Name, role.Permissions) // Invalid patterns never match
+	c.mu.Lock()
+	if c.roles == nil {
+		c.roles = make(map[string]compiledRole)
+	}
+	c.roles[role.Name] = compiledRole{role: role, trie: trie}
+	c.mu.Unlock()
+	return trie
+}
+
+// evaluate checks perm against the principal's direct permissions and every role it holds,
+// including inherited roles, and explains the outcome.
+func (d *DefaultAccessController) evaluate(p Principal, perm Permission) Explanation {
+	e := Explanation{Permission: perm}
+	if p == nil {
+		e.Reason = "no principal"
+		return e
+	}
+	e.Principal = p.ID()
+	segs := strings.Split(string(perm), ".")
+
+	var allow, deny *permissionRule
+	var allowVia, denyVia []string
+	check := func(trie *permissionTrie, via []string) {
+		trie.match(segs, 0, func(r permissionRule) {
+			if r.deny && deny == nil {
+				deny, denyVia = &r, via
+			} else if !r.deny && allow == nil {
+				allow, allowVia = &r, via
+			}
+		})
+	}
+
+	if holder, ok := p.(PermissionHolder); ok {
+		if trie, _ := compilePermissions("", holder.Permissions()); trie != nil {
+			check(trie, nil)
+		}
+	}
+
+	// Walk the principal's roles breadth-first, following Inherits. Each role is visited once,
+	// so inheritance cycles are harmless.
+	type pending struct {
+		name string
+		via  []string
+	}
+	queue := make([]pending, 0, len(p.Roles()))
+	for _, name := range p.Roles() {
+		queue = append(queue, pending{name: name})
+	}
+	seen := make(map[string]bool)
+	for len(queue) > 0 && deny == nil {
+		next := queue[0]
+		queue = queue[1:]
+		if seen[next.name] || d.rbacProvider == nil {
+			continue
+		}
+		seen[next.name] = true
+		role, ok := d.rbacProvider.GetRole(next.name)
+		if !ok {
+			continue
+		}
+		check(d.compiled.get(role), next.via)
+		for _, parent := range role.Inherits {
+			via := append(append([]string(nil), next.via...), next.name)
+			queue = append(queue, pending{name: parent, via: via})
+		}
+	}
+
+	switch {
+	case deny != nil:
+		e.Rule, e.Role, e.Via = deny.pattern, deny.role, denyVia
+		e.Reason = fmt.Sprintf("denied by %s", describeRule(*deny, denyVia))
+	case allow != nil:
+		e.Allowed = true
+		e.Rule, e.Role, e.Via = allow.pattern, allow.role, allowVia
+		e.Reason = fmt.Sprintf("granted by %s", describeRule(*allow, allowVia))
+	default:
+		e.Reason = fmt.Sprintf("no rule matches (roles: %s)", strings.Join(p.Roles(), ", "))
+	}
+	return e
+}
+
+func describeRule(r permissionRule, via []string) string {
+	if r.role == "" {
+		return fmt.Sprintf("%q held directly", r.pattern)
+	}
+	if len(via) == 0 {
+		return fmt.Sprintf("%q in role %q", r.pattern, r.role)
+	}
+	return fmt.Sprintf("%q in role %q (inherited via %s)", r.pattern, r.role, strings.Join(via, " -> "))
+}
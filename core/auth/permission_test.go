@@ -0,0 +1,138 @@
+package auth_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"acacia/core/auth"
+)
+
+func TestDefaultAccessController_PatternMatching(t *testing.T) {
+	tests := []struct {
+		pattern auth.Permission
+		perm    auth.Permission
+		want    bool
+	}{
+		{"service.chat.room.access", "service.chat.room.access", true},
+		{"service.chat.room.access", "service.chat.room", false},
+		{"service.*.room.access", "service.chat.room.access", true},
+		{"service.*.room.access", "service.chat.lobby.room.access", false},
+		{"service.chat.*", "service.chat.room.access", true},
+		{"service.chat.*", "service.chat", false},
+		{"service.chat.*", "service.chatter.room", false},
+		{"service.**.access", "service.access", true},
+		{"service.**.access", "service.chat.room.access", true},
+		{"service.**.access", "service.chat.room.call.Get", false},
+		{"service.chat.call.Get*", "service.chat.call.GetHistory", true},
+		{"service.chat.call.Get*", "service.chat.call.Post", false},
+		{"service.chat.call.Get?", "service.chat.call.GetX", true},
+		{"*", "anything.at.all", true},
+		{"**", "anything.at.all", true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.pattern)+" vs "+string(tt.perm), func(t *testing.T) {
+			ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+				{Name: "r", Permissions: []auth.Permission{tt.pattern}},
+			}))
+			if got := ac.HasPermission(auth.NewDefaultPrincipal("p", "user", []string{"r"}), tt.perm); got != tt.want {
+				t.Errorf("HasPermission(%q) with %q = %v, want %v", tt.perm, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultAccessController_DenyAndInheritance(t *testing.T) {
+	ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+		{Name: "player", Permissions: []auth.Permission{"service.chat.*", "service.profile.*"}},
+		{Name: "muted", Permissions: []auth.Permission{"!service.chat.*.call.Send"}},
+		{Name: "moderator", Inherits: []string{"player"}, Permissions: []auth.Permission{"service.moderation.*"}},
+		{Name: "senior-moderator", Inherits: []string{"moderator"}},
+		{Name: "loop-a", Inherits: []string{"loop-b"}, Permissions: []auth.Permission{"a.x"}},
+		{Name: "loop-b", Inherits: []string{"loop-a"}, Permissions: []auth.Permission{"b.x"}},
+	}))
+
+	senior := auth.NewDefaultPrincipal("s", "user", []string{"senior-moderator"})
+	if !ac.HasPermission(senior, "service.chat.room.call.Send") {
+		t.Error("senior-moderator should inherit chat access through moderator -> player")
+	}
+	if !ac.HasPermission(senior, "service.moderation.call.Ban") {
+		t.Error("senior-moderator should inherit moderation access")
+	}
+
+	mutedMod := auth.NewDefaultPrincipal("m", "user", []string{"moderator", "muted"})
+	if ac.HasPermission(mutedMod, "service.chat.room.call.Send") {
+		t.Error("deny rule should override inherited allow")
+	}
+	if !ac.HasPermission(mutedMod, "service.chat.room.call.History") {
+		t.Error("deny rule should only cover matching permissions")
+	}
+
+	loop := auth.NewDefaultPrincipal("l", "user", []string{"loop-a"})
+	if !ac.HasPermission(loop, "b.x") || ac.HasPermission(loop, "c.x") {
+		t.Error("inheritance cycles should terminate and still grant reachable permissions")
+	}
+
+	// Role names are no longer permissions in their own right.
+	if ac.HasPermission(auth.NewDefaultPrincipal("x", "user", []string{"service.chat.*"}), "service.chat.room.access") {
+		t.Error("an undefined role named like a permission must not grant it")
+	}
+}
+
+func TestDefaultAccessController_DirectPermissions(t *testing.T) {
+	ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider(nil))
+	p := auth.NewDefaultPrincipal("plugin-loader", "system", nil).WithPermissions("kernel.module.*", "!kernel.module.remove")
+
+	if !ac.HasPermission(p, "kernel.module.add") {
+		t.Error("direct permission should be granted")
+	}
+	if ac.HasPermission(p, "kernel.module.remove") {
+		t.Error("direct deny should override direct allow")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+		{Name: "player", Permissions: []auth.Permission{"service.chat.*"}},
+		{Name: "moderator", Inherits: []string{"player"}},
+		{Name: "muted", Permissions: []auth.Permission{"!service.chat.room.call.Send"}},
+	}))
+	mod := auth.NewDefaultPrincipal("m", "user", []string{"moderator"})
+
+	e := auth.Explain(ac, mod, "service.chat.room.access")
+	if !e.Allowed || e.Rule != "service.chat.*" || e.Role != "player" || !reflect.DeepEqual(e.Via, []string{"moderator"}) {
+		t.Errorf("Explain() = %+v", e)
+	}
+	if !strings.Contains(e.String(), `inherited via moderator`) {
+		t.Errorf("String() = %q", e.String())
+	}
+
+	e = auth.Explain(ac, auth.NewDefaultPrincipal("u", "user", []string{"player", "muted"}), "service.chat.room.call.Send")
+	if e.Allowed || e.Rule != "!service.chat.room.call.Send" || e.Role != "muted" {
+		t.Errorf("Explain() = %+v", e)
+	}
+
+	e = auth.Explain(ac, mod, "service.profile.access")
+	if e.Allowed || e.Rule != "" || !strings.Contains(e.Reason, "no rule matches") {
+		t.Errorf("Explain() = %+v", e)
+	}
+
+	if e := auth.Explain(auth.NewDefaultAccessController(nil), mod, "anything"); !e.Allowed {
+		t.Errorf("allow-all Explain() = %+v", e)
+	}
+}
+
+func TestValidatePermission(t *testing.T) {
+	valid := []auth.Permission{"a.b.c", "a.*", "!a.b", "a.**.c", "a.Get*", "a.[a-z]"}
+	for _, p := range valid {
+		if err := auth.ValidatePermission(p); err != nil {
+			t.Errorf("ValidatePermission(%q) = %v", p, err)
+		}
+	}
+	invalid := []auth.Permission{"", "!", "a..b", "a.b.", "a.**x", "a.[b"}
+	for _, p := range invalid {
+		if err := auth.ValidatePermission(p); err == nil {
+			t.Errorf("ValidatePermission(%q) should fail", p)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Policy effects.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Resource identifies what an action is performed on, e.g. a player profile.
+// Attributes are available to policy conditions as resource.<name>.
+type Resource struct {
+	Type       string
+	ID         string
+	Attributes map[string]interface{}
+}
+
+// Policy is an attribute-based access rule. It applies to a request when the action and the
+// resource type match one of its patterns and its condition holds.
+//
+// Patterns use the same segment-aware syntax as RBAC permissions (see permission.go), without
+// the deny prefix. Empty Actions or Resources match everything.
+type Policy struct {
+	Name        string   `mapstructure:"name"`
+	Description string   `mapstructure:"description"`
+	Effect      string   `mapstructure:"effect"`    // "allow" or "deny", defaults to "allow"
+	Actions     []string `mapstructure:"actions"`   // Action patterns, e.g. "profile.update"
+	Resources   []string `mapstructure:"resources"` // Resource type patterns, e.g. "profile"
+	Condition   string   `mapstructure:"condition"` // e.g. `principal.id == resource.owner`; empty always holds
+}
+
+// Decision is the outcome of evaluating policies against a request.
+type Decision struct {
+	// Applicable is false when no policy matched; the caller should fall back to RBAC.
+	Applicable bool
+	Allowed    bool
+	// Policy names the policy that decided, empty if none applied.
+	Policy string
+}
+
+// PolicyEngine evaluates a set of compiled ABAC policies. Deny policies take precedence
+// over allow policies. It is safe for concurrent use.
+type PolicyEngine struct {
+	policies []compiledPolicy
+}
+
+type compiledPolicy struct {
+	Policy
+	actions   *permissionTrie // nil matches any action
+	resources *permissionTrie // nil matches any resource type
+	condition conditionNode
+}
+
+// NewPolicyEngine validates and compiles policies.
+func NewPolicyEngine(policies []Policy) (*PolicyEngine, error) {
+	e := &PolicyEngine{policies: make([]compiledPolicy, 0, len(policies))}
+	for i, p := range policies {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		switch strings.ToLower(p.Effect) {
+		case "", EffectAllow:
+			p.Effect = EffectAllow
+		case EffectDeny:
+			p.Effect = EffectDeny
+		default:
+			return nil, fmt.Errorf("policy %s: unknown effect %q", name, p.Effect)
+		}
+		cond, err := compileCondition(p.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: invalid condition: %w", name, err)
+		}
+		actions, err := compilePatterns(p.Actions)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: actions: %w", name, err)
+		}
+		resources, err := compilePatterns(p.Resources)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: resources: %w", name, err)
+		}
+		p.Name = name
+		e.policies = append(e.policies, compiledPolicy{Policy: p, actions: actions, resources: resources, condition: cond})
+	}
+	return e, nil
+}
+
+// Evaluate decides whether p may perform action on resource. A matching deny policy wins over
+// any allow policy; if no policy applies, the returned decision is not Applicable.
+func (e *PolicyEngine) Evaluate(ctx context.Context, p Principal, action string, resource Resource) Decision {
+	env := &policyEnv{principal: p, action: action, resource: resource}
+	decision := Decision{}
+	for _, policy := range e.policies {
+		if !matchesAny(policy.actions, action) || !matchesAny(policy.resources, resource.Type) {
+			continue
+		}
+		if !truthy(policy.condition.eval(env)) {
+			continue
+		}
+		if policy.Effect == EffectDeny {
+			return Decision{Applicable: true, Allowed: false, Policy: policy.Name}
+		}
+		if !decision.Applicable {
+			decision = Decision{Applicable: true, Allowed: true, Policy: policy.Name}
+		}
+	}
+	return decision
+}
+
+// compilePatterns compiles policy action or resource patterns. No patterns yields nil.
+func compilePatterns(patterns []string) (*permissionTrie, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	perms := make([]Permission, len(patterns))
+	for i, p := range patterns {
+		if strings.HasPrefix(p, denyPrefix) {
+			return nil, fmt.Errorf("pattern %q: deny prefix is not allowed, use a deny policy", p)
+		}
+		perms[i] = Permission(p)
+	}
+	return compilePermissions("", perms)
+}
+
+// matchesAny reports whether value matches one of the compiled patterns. A nil trie matches everything.
+func matchesAny(patterns *permissionTrie, value string) bool {
+	if patterns == nil {
+		return true
+	}
+	matched := false
+	patterns.match(strings.Split(value, "."), 0, func(permissionRule) { matched = true })
+	return matched
+}
+
+// policyEnv resolves condition paths for a single request.
+type policyEnv struct {
+	principal Principal
+	action    string
+	resource  Resource
+}
+
+// lookup resolves a path such as principal.tenant or resource.owner.
+// principal.id, principal.type and principal.roles as well as resource.type and resource.id
+// are built in; any other name refers to an attribute.
+func (env *policyEnv) lookup(parts []string) interface{} {
+	var current interface{}
+	rest := parts[1:]
+	switch parts[0] {
+	case "action":
+		if len(rest) > 0 {
+			return missing
+		}
+		return env.action
+	case "principal":
+		if env.principal == nil {
+			return missing
+		}
+		if len(rest) == 0 {
+			return missing
+		}
+		switch rest[0] {
+		case "id":
+			return valueOrMissing(env.principal.ID(), rest[1:])
+		case "type":
+			return valueOrMissing(env.principal.Type(), rest[1:])
+		case "roles":
+			return valueOrMissing(stringsToValues(env.principal.Roles()), rest[1:])
+		}
+		current = PrincipalAttributes(env.principal)
+	case "resource":
+		if len(rest) == 0 {
+			return missing
+		}
+		switch rest[0] {
+		case "type":
+			return valueOrMissing(env.resource.Type, rest[1:])
+		case "id":
+			return valueOrMissing(env.resource.ID, rest[1:])
+		}
+		current = env.resource.Attributes
+	default:
+		return missing
+	}
+
+	for _, part := range rest {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return missing
+		}
+		if current, ok = m[part]; !ok {
+			return missing
+		}
+	}
+	if s, ok := current.([]string); ok {
+		return stringsToValues(s)
+	}
+	return current
+}
+
+func valueOrMissing(v interface{}, rest []string) interface{} {
+	if len(rest) > 0 {
+		return missing
+	}
+	return v
+}
+
+// PolicyAuthorizer is implemented by access controllers that can make attribute-based
+// decisions about an action on a specific resource.
+type PolicyAuthorizer interface {
+	CanPerform(ctx context.Context, p Principal, action string, resource Resource) bool
+}
+
+// CanPerform reports whether p may perform action on resource. If ac evaluates policies it
+// decides; otherwise the action is checked as an RBAC permission.
+func CanPerform(ctx context.Context, ac AccessController, p Principal, action string, resource Resource) bool {
+	if pa, ok := ac.(PolicyAuthorizer); ok {
+		return pa.CanPerform(ctx, p, action, resource)
+	}
+	return ac.HasPermission(p, Permission(action))
+}
+
+// policyAccessController layers ABAC policies over another AccessController.
+type policyAccessController struct {
+	AccessController
+	policies *PolicyEngine
+}
+
+// WithPolicies returns an AccessController that consults policies for CanPerform requests.
+// A matching deny policy rejects the request, a matching allow policy grants it, and requests
+// no policy applies to fall back to ac.HasPermission with the action as the permission.
+// All other checks are delegated to ac unchanged.
+func WithPolicies(ac AccessController, policies *PolicyEngine) AccessController {
+	if policies == nil {
+		return ac
+	}
+	return &policyAccessController{AccessController: ac, policies: policies}
+}
+
+func (c *policyAccessController) CanPerform(ctx context.Context, p Principal, action string, resource Resource) bool {
+	if p == nil {
+		return false
+	}
+	if d := c.policies.Evaluate(ctx, p, action, resource); d.Applicable {
+		return d.Allowed
+	}
+	return c.AccessController.HasPermission(p, Permission(action))
+}
+
+// Explain delegates to the wrapped controller; policies only apply to CanPerform.
+func (c *policyAccessController) Explain(p Principal, perm Permission) Explanation {
+	return Explain(c.AccessController, p, perm)
+}
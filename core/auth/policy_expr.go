@@ -0,0 +1,454 @@
+package auth
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file implements the small condition language used by ABAC policies:
+//
+//	principal.id == resource.owner && action != "profile.delete"
+//	"moderator" in principal.roles || principal.shard in ["eu-1", "eu-2"]
+//	!(resource.locked == true) && principal.level >= 10
+//
+// Paths start with principal, resource or action. Literals are strings (single or double
+// quoted), numbers, true, false, null and lists. Comparisons with a missing attribute are
+// false, except against null: `resource.owner == null` tests for absence.
+
+// conditionNode is a compiled condition expression.
+type conditionNode interface {
+	eval(env *policyEnv) interface{}
+}
+
+// missing is the value of a path that does not resolve.
+type missingValue struct{}
+
+var missing = missingValue{}
+
+type literalNode struct {
+	value  interface{}
+	isNull bool
+}
+
+func (n literalNode) eval(*policyEnv) interface{} { return n.value }
+
+type pathNode struct{ parts []string }
+
+func (n pathNode) eval(env *policyEnv) interface{} { return env.lookup(n.parts) }
+
+type listNode struct{ items []conditionNode }
+
+func (n listNode) eval(env *policyEnv) interface{} {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.eval(env)
+	}
+	return values
+}
+
+type notNode struct{ operand conditionNode }
+
+func (n notNode) eval(env *policyEnv) interface{} { return !truthy(n.operand.eval(env)) }
+
+type logicalNode struct {
+	and         bool
+	left, right conditionNode
+}
+
+func (n logicalNode) eval(env *policyEnv) interface{} {
+	left := truthy(n.left.eval(env))
+	if n.and {
+		return left && truthy(n.right.eval(env))
+	}
+	return left || truthy(n.right.eval(env))
+}
+
+type compareNode struct {
+	op          string
+	left, right conditionNode
+}
+
+func (n compareNode) eval(env *policyEnv) interface{} {
+	left, right := n.left.eval(env), n.right.eval(env)
+
+	// Explicit null checks test whether an attribute is absent.
+	if isNullLiteral(n.left) || isNullLiteral(n.right) {
+		other := left
+		if isNullLiteral(n.left) {
+			other = right
+		}
+		absent := other == missing || other == nil
+		switch n.op {
+		case "==":
+			return absent
+		case "!=":
+			return !absent
+		default:
+			return false
+		}
+	}
+	if left == missing || right == missing {
+		return false
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right)
+	case "!=":
+		return !valuesEqual(left, right)
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok {
+			if s, ok := right.(string); ok {
+				sub, ok := left.(string)
+				return ok && strings.Contains(s, sub)
+			}
+			return false
+		}
+		for _, item := range list {
+			if valuesEqual(left, item) {
+				return true
+			}
+		}
+		return false
+	default: // <, <=, >, >=
+		if l, ok := toNumber(left); ok {
+			if r, ok := toNumber(right); ok {
+				return compareOrdered(n.op, l, r)
+			}
+			return false
+		}
+		ls, lok := left.(string)
+		rs, rok := right.(string)
+		if lok && rok {
+			return compareOrdered(n.op, strings.Compare(ls, rs), 0)
+		}
+		return false
+	}
+}
+
+func isNullLiteral(n conditionNode) bool {
+	lit, ok := n.(literalNode)
+	return ok && lit.isNull
+}
+
+func compareOrdered[T int | float64](op string, l, r T) bool {
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+func truthy(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if an, ok := toNumber(a); ok {
+		bn, ok := toNumber(b)
+		return ok && an == bn
+	}
+	switch av := a.(type) {
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !valuesEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case []string:
+		return valuesEqual(stringsToValues(av), b)
+	}
+	if bv, ok := b.([]string); ok {
+		return valuesEqual(a, stringsToValues(bv))
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func stringsToValues(s []string) []interface{} {
+	values := make([]interface{}, len(s))
+	for i, v := range s {
+		values[i] = v
+	}
+	return values
+}
+
+// compileCondition parses a condition expression. An empty expression always holds.
+func compileCondition(src string) (conditionNode, error) {
+	if strings.TrimSpace(src) == "" {
+		return literalNode{value: true}, nil
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &conditionParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.peek().text, p.peek().pos)
+	}
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokString
+	tokNumber
+	tokOp
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			var sb strings.Builder
+			for ; end < len(src) && rune(src[end]) != c; end++ {
+				if src[end] == '\\' && end+1 < len(src) {
+					end++
+				}
+				sb.WriteByte(src[end])
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, token{tokString, sb.String(), i})
+			i = end + 1
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			end := i + 1
+			for end < len(src) && (unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokNumber, src[i:end], i})
+			i = end
+		case unicode.IsLetter(c) || c == '_':
+			end := i + 1
+			for end < len(src) && (unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end])) || src[end] == '_' || src[end] == '-') {
+				end++
+			}
+			tokens = append(tokens, token{tokIdent, src[i:end], i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(src)}), nil
+}
+
+type conditionParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *conditionParser) peek() token { return p.tokens[p.pos] }
+func (p *conditionParser) done() bool  { return p.peek().kind == tokEOF }
+
+func (p *conditionParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *conditionParser) accept(kind tokenKind, text string) bool {
+	if t := p.peek(); t.kind == kind && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) expect(text string) error {
+	if !p.accept(tokOp, text) {
+		t := p.peek()
+		return fmt.Errorf("expected %q at offset %d, got %q", text, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokOp, "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokOp, "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseNot() (conditionNode, error) {
+	if p.accept(tokOp, "!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (conditionNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	isCompare := t.kind == tokOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">=")
+	if !isCompare && !(t.kind == tokIdent && t.text == "in") {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: t.text, left: left, right: right}, nil
+}
+
+func (p *conditionParser) parseOperand() (conditionNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literalNode{value: t.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return literalNode{value: n}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil, isNull: true}, nil
+		case "principal", "resource", "action":
+		default:
+			return nil, fmt.Errorf("unknown identifier %q at offset %d (paths start with principal, resource or action)", t.text, t.pos)
+		}
+		parts := []string{t.text}
+		for p.accept(tokOp, ".") {
+			part := p.next()
+			if part.kind != tokIdent {
+				return nil, fmt.Errorf("expected attribute name at offset %d", part.pos)
+			}
+			parts = append(parts, part.text)
+		}
+		return pathNode{parts}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			var items []conditionNode
+			for !p.accept(tokOp, "]") {
+				if len(items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				item, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return listNode{items}, nil
+		}
+	}
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
@@ -0,0 +1,165 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"acacia/core/auth"
+)
+
+func TestPolicyEngine_Evaluate(t *testing.T) {
+	engine, err := auth.NewPolicyEngine([]auth.Policy{
+		{
+			Name:      "own-profile",
+			Actions:   []string{"profile.update", "profile.read"},
+			Resources: []string{"profile"},
+			Condition: `principal.id == resource.owner`,
+		},
+		{
+			Name:      "moderators-read",
+			Actions:   []string{"profile.read"},
+			Condition: `"moderator" in principal.roles && principal.shard == resource.shard`,
+		},
+		{
+			Name:      "banned",
+			Effect:    auth.EffectDeny,
+			Actions:   []string{"profile.*"},
+			Condition: `principal.banned == true`,
+		},
+		{
+			Name:      "untagged-resources",
+			Effect:    auth.EffectDeny,
+			Resources: []string{"profile"},
+			Condition: `resource.owner == null`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyEngine: %v", err)
+	}
+
+	alice := auth.NewPrincipalWithAttributes("alice", "user", []string{"player"}, map[string]interface{}{"shard": "eu-1"})
+	mod := auth.NewPrincipalWithAttributes("bob", "user", []string{"moderator"}, map[string]interface{}{"shard": "eu-1"})
+	banned := auth.NewPrincipalWithAttributes("carol", "user", nil, map[string]interface{}{"banned": true})
+	aliceProfile := auth.Resource{Type: "profile", ID: "p1", Attributes: map[string]interface{}{"owner": "alice", "shard": "eu-1"}}
+	carolProfile := auth.Resource{Type: "profile", ID: "p3", Attributes: map[string]interface{}{"owner": "carol", "shard": "us-1"}}
+
+	tests := []struct {
+		name       string
+		principal  auth.Principal
+		action     string
+		resource   auth.Resource
+		applicable bool
+		allowed    bool
+		policy     string
+	}{
+		{"owner updates own profile", alice, "profile.update", aliceProfile, true, true, "own-profile"},
+		{"other player cannot update", mod, "profile.update", aliceProfile, false, false, ""},
+		{"moderator reads on same shard", mod, "profile.read", aliceProfile, true, true, "moderators-read"},
+		{"moderator on other shard", mod, "profile.read", carolProfile, false, false, ""},
+		{"deny overrides allow", banned, "profile.update", carolProfile, true, false, "banned"},
+		{"missing owner denied", alice, "profile.read", auth.Resource{Type: "profile"}, true, false, "untagged-resources"},
+		{"unrelated action", alice, "match.join", aliceProfile, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := engine.Evaluate(context.Background(), tt.principal, tt.action, tt.resource)
+			if d.Applicable != tt.applicable || d.Allowed != tt.allowed || d.Policy != tt.policy {
+				t.Errorf("Evaluate() = %+v, want applicable=%v allowed=%v policy=%q", d, tt.applicable, tt.allowed, tt.policy)
+			}
+		})
+	}
+}
+
+func TestPolicyConditions(t *testing.T) {
+	p := auth.NewPrincipalWithAttributes("u1", "user", []string{"player", "vip"}, map[string]interface{}{
+		"level":  12,
+		"region": "eu",
+		"org":    map[string]interface{}{"tenant": "acme"},
+	})
+	res := auth.Resource{Type: "match", ID: "m1", Attributes: map[string]interface{}{"min_level": 10.0, "regions": []string{"eu", "us"}}}
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{`principal.level >= resource.min_level`, true},
+		{`principal.level < 10`, false},
+		{`principal.region in resource.regions`, true},
+		{`principal.region in ["us", "asia"]`, false},
+		{`principal.org.tenant == 'acme' && principal.type == "user"`, true},
+		{`!(principal.org.tenant == "acme") || action == "match.join"`, true},
+		{`principal.missing == "x"`, false},
+		{`principal.missing != "x"`, false},
+		{`principal.missing == null`, true},
+		{`resource.id == "m1" && resource.type == "match"`, true},
+		{`"vip" in principal.roles`, true},
+		{``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			engine, err := auth.NewPolicyEngine([]auth.Policy{{Name: "p", Condition: tt.condition}})
+			if err != nil {
+				t.Fatalf("NewPolicyEngine: %v", err)
+			}
+			if got := engine.Evaluate(context.Background(), p, "match.join", res).Allowed; got != tt.want {
+				t.Errorf("condition %q = %v, want %v", tt.condition, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPolicyEngine_Invalid(t *testing.T) {
+	tests := map[string]auth.Policy{
+		"unknown effect":      {Effect: "maybe"},
+		"unknown identifier":  {Condition: `user.id == "x"`},
+		"unterminated string": {Condition: `principal.id == "x`},
+		"dangling operator":   {Condition: `principal.id ==`},
+		"unbalanced parens":   {Condition: `(principal.id == "x"`},
+	}
+	for name, policy := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := auth.NewPolicyEngine([]auth.Policy{policy}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestWithPolicies_FallsBackToRBAC(t *testing.T) {
+	engine, err := auth.NewPolicyEngine([]auth.Policy{
+		{Name: "own-profile", Actions: []string{"profile.update"}, Condition: `principal.id == resource.owner`},
+		{Name: "frozen", Effect: auth.EffectDeny, Condition: `resource.frozen == true`},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyEngine: %v", err)
+	}
+	rbac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+		{Name: "admin", Permissions: []auth.Permission{"profile.*"}},
+	}))
+	ac := auth.WithPolicies(rbac, engine)
+	ctx := context.Background()
+
+	player := auth.NewDefaultPrincipal("alice", "user", []string{"player"})
+	admin := auth.NewDefaultPrincipal("root", "user", []string{"admin"})
+	profile := auth.Resource{Type: "profile", Attributes: map[string]interface{}{"owner": "alice"}}
+	frozen := auth.Resource{Type: "profile", Attributes: map[string]interface{}{"owner": "alice", "frozen": true}}
+
+	if !auth.CanPerform(ctx, ac, player, "profile.update", profile) {
+		t.Error("owner should be allowed by policy")
+	}
+	if auth.CanPerform(ctx, ac, player, "profile.delete", profile) {
+		t.Error("player without permission should fall back to RBAC and be denied")
+	}
+	if !auth.CanPerform(ctx, ac, admin, "profile.delete", profile) {
+		t.Error("admin should fall back to RBAC and be allowed")
+	}
+	if auth.CanPerform(ctx, ac, admin, "profile.delete", frozen) {
+		t.Error("deny policy should override RBAC")
+	}
+	if !auth.CanPerform(ctx, rbac, admin, "profile.delete", frozen) {
+		t.Error("controller without policies should use RBAC only")
+	}
+	if !ac.HasPermission(admin, "profile.read") {
+		t.Error("other checks should delegate to the wrapped controller")
+	}
+}
@@ -0,0 +1,178 @@
+package auth_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"acacia/core/auth"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestValidateRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []auth.Role
+		wantErr string
+	}{
+		{"valid", []auth.Role{{Name: "a", Permissions: []auth.Permission{"x.*"}}, {Name: "b", Inherits: []string{"a"}}}, ""},
+		{"missing name", []auth.Role{{Permissions: []auth.Permission{"x"}}}, "missing name"},
+		{"duplicate", []auth.Role{{Name: "a"}, {Name: "a"}}, "defined more than once"},
+		{"bad pattern", []auth.Role{{Name: "a", Permissions: []auth.Permission{"x..y"}}}, "empty segment"},
+		{"unknown parent", []auth.Role{{Name: "a", Inherits: []string{"ghost"}}}, `inherits unknown role "ghost"`},
+		{"cycle", []auth.Role{{Name: "a", Inherits: []string{"b"}}, {Name: "b", Inherits: []string{"a"}}}, "inheritance cycle: a -> b -> a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := auth.ValidateRoles(tt.roles)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateRoles() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateRoles() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigRBACProvider_SetRoles(t *testing.T) {
+	provider := auth.NewConfigRBACProvider([]auth.Role{{Name: "player", Permissions: []auth.Permission{"service.chat.*"}}})
+	ac := auth.NewDefaultAccessController(provider)
+	p := auth.NewDefaultPrincipal("u", "user", []string{"player"})
+
+	if !ac.HasPermission(p, "service.chat.room.access") {
+		t.Fatal("initial roles should apply")
+	}
+
+	if err := provider.SetRoles([]auth.Role{{Name: "player", Permissions: []auth.Permission{"service.profile.*"}}}); err != nil {
+		t.Fatalf("SetRoles: %v", err)
+	}
+	if ac.HasPermission(p, "service.chat.room.access") || !ac.HasPermission(p, "service.profile.access") {
+		t.Error("new roles should replace the old ones")
+	}
+
+	if err := provider.SetRoles([]auth.Role{{Name: "player", Inherits: []string{"ghost"}}}); err == nil {
+		t.Fatal("SetRoles should reject invalid roles")
+	}
+	if !ac.HasPermission(p, "service.profile.access") {
+		t.Error("rejected roles must not replace the current ones")
+	}
+}
+
+func TestFileRBACProvider_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roles.yaml")
+	write := func(content string) {
+		t.Helper()
+		// Replace the file atomically, as deploy tools do.
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("roles:\n  - name: player\n    permissions: [\"service.chat.*\"]\n")
+
+	provider, err := auth.NewFileRBACProvider(path)
+	if err != nil {
+		t.Fatalf("NewFileRBACProvider: %v", err)
+	}
+	reloads := make(chan error, 10)
+	if err := provider.Watch(func(err error) { reloads <- err }); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer provider.Close()
+
+	ac := auth.NewDefaultAccessController(provider)
+	mod := auth.NewDefaultPrincipal("m", "user", []string{"moderator"})
+	if ac.HasPermission(mod, "service.chat.room.access") {
+		t.Fatal("moderator is not defined yet")
+	}
+
+	write("roles:\n  - name: player\n    permissions: [\"service.chat.*\"]\n  - name: moderator\n    inherits: [player]\n")
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !ac.HasPermission(mod, "service.chat.room.access") {
+		t.Error("moderator should be picked up after the file changed")
+	}
+
+	write("roles:\n  - name: moderator\n    inherits: [player]\n")
+	if err := waitReload(t, reloads); err == nil {
+		t.Fatal("reload of an invalid file should fail")
+	}
+	if !ac.HasPermission(mod, "service.chat.room.access") {
+		t.Error("previous roles should be kept after an invalid change")
+	}
+}
+
+func waitReload(t *testing.T, reloads <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-reloads:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+		return nil
+	}
+}
+
+func TestSQLRBACProvider(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "rbac.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	if err := auth.CreateSQLRBACSchema(ctx, db, ""); err != nil {
+		t.Fatalf("CreateSQLRBACSchema: %v", err)
+	}
+	exec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	exec(`INSERT INTO acacia_roles (name) VALUES ('player'), ('moderator'), ('guest')`)
+	exec(`INSERT INTO acacia_role_permissions (role, permission) VALUES ('player', 'service.chat.*'), ('moderator', 'service.moderation.*')`)
+	exec(`INSERT INTO acacia_role_inherits (role, parent) VALUES ('moderator', 'player')`)
+
+	provider, err := auth.NewSQLRBACProvider(ctx, db, auth.SQLRBACOptions{})
+	if err != nil {
+		t.Fatalf("NewSQLRBACProvider: %v", err)
+	}
+	if _, ok := provider.GetRole("guest"); !ok {
+		t.Error("roles without permissions should be loaded")
+	}
+	ac := auth.NewDefaultAccessController(provider)
+	mod := auth.NewDefaultPrincipal("m", "user", []string{"moderator"})
+	if !ac.HasPermission(mod, "service.chat.room.access") || !ac.HasPermission(mod, "service.moderation.call.Ban") {
+		t.Fatal("moderator should hold its own and inherited permissions")
+	}
+
+	exec(`INSERT INTO acacia_role_permissions (role, permission) VALUES ('moderator', '!service.moderation.call.Ban')`)
+	if err := provider.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if ac.HasPermission(mod, "service.moderation.call.Ban") {
+		t.Error("deny rule added to the database should apply after reload")
+	}
+
+	exec(`INSERT INTO acacia_role_inherits (role, parent) VALUES ('player', 'moderator')`)
+	if err := provider.Reload(ctx); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("Reload with a cycle = %v, want cycle error", err)
+	}
+	if !ac.HasPermission(mod, "service.moderation.call.Mute") {
+		t.Error("previous roles should be kept after an invalid change")
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SQLRBACOptions configures an SQLRBACProvider.
+type SQLRBACOptions struct {
+	// TablePrefix is prepended to the table names, defaults to "acacia_".
+	TablePrefix string
+	// RefreshInterval is how often Watch reloads the roles, defaults to 30 seconds.
+	RefreshInterval time.Duration
+}
+
+// SQLRBACProvider serves roles stored in an SQL database. It reads three tables, shown here with
+// the default prefix:
+//
+//	acacia_roles(name)                        every role, including roles without permissions
+//	acacia_role_permissions(role, permission) one row per permission pattern
+//	acacia_role_inherits(role, parent)        one row per inherited role
+//
+// Roles are loaded in a single transaction and validated before they replace the current set,
+// so a half-applied or broken edit in the database never takes effect.
+type SQLRBACProvider struct {
+	roleStore
+	db   *sql.DB
+	opts SQLRBACOptions
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSQLRBACProvider loads the roles from db. The database driver must be registered by the caller.
+func NewSQLRBACProvider(ctx context.Context, db *sql.DB, opts SQLRBACOptions) (*SQLRBACProvider, error) {
+	if opts.TablePrefix == "" {
+		opts.TablePrefix = "acacia_"
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = 30 * time.Second
+	}
+	p := &SQLRBACProvider{db: db, opts: opts}
+	if err := p.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CreateSQLRBACSchema creates the role tables if they do not exist yet. The statements use
+// portable column types and work with SQLite, PostgreSQL and MySQL.
+func CreateSQLRBACSchema(ctx context.Context, db *sql.DB, tablePrefix string) error {
+	if tablePrefix == "" {
+		tablePrefix = "acacia_"
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %sroles (name VARCHAR(255) PRIMARY KEY)`, tablePrefix),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %srole_permissions (role VARCHAR(255) NOT NULL, permission VARCHAR(255) NOT NULL)`, tablePrefix),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %srole_inherits (role VARCHAR(255) NOT NULL, parent VARCHAR(255) NOT NULL)`, tablePrefix),
+	}
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("create RBAC schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Reload reads all roles from the database and applies them if they are valid.
+func (p *SQLRBACProvider) Reload(ctx context.Context) error {
+	roles, err := p.load(ctx)
+	if err != nil {
+		return fmt.Errorf("load roles from database: %w", err)
+	}
+	return p.swap(roles)
+}
+
+func (p *SQLRBACProvider) load(ctx context.Context) ([]Role, error) {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Read-only, nothing to commit
+
+	byName := make(map[string]*Role)
+	err = queryPairs(ctx, tx, fmt.Sprintf(`SELECT name, '' FROM %sroles`, p.opts.TablePrefix), func(name, _ string) {
+		byName[name] = &Role{Name: name}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Permissions and inherits rows for roles missing from the roles table create the role,
+	// so that such rows are not silently ignored.
+	role := func(name string) *Role {
+		r, ok := byName[name]
+		if !ok {
+			r = &Role{Name: name}
+			byName[name] = r
+		}
+		return r
+	}
+	err = queryPairs(ctx, tx, fmt.Sprintf(`SELECT role, permission FROM %srole_permissions ORDER BY role, permission`, p.opts.TablePrefix), func(name, perm string) {
+		r := role(name)
+		r.Permissions = append(r.Permissions, Permission(perm))
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryPairs(ctx, tx, fmt.Sprintf(`SELECT role, parent FROM %srole_inherits ORDER BY role, parent`, p.opts.TablePrefix), func(name, parent string) {
+		r := role(name)
+		r.Inherits = append(r.Inherits, parent)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]Role, 0, len(byName))
+	for _, r := range byName {
+		roles = append(roles, *r)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+// queryPairs runs a query returning two string columns and calls fn for every row.
+func queryPairs(ctx context.Context, tx *sql.Tx, query string, fn func(a, b string)) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a, b string
+		if err := rows.Scan(&a, &b); err != nil {
+			return err
+		}
+		fn(a, b)
+	}
+	return rows.Err()
+}
+
+// Watch reloads the roles every RefreshInterval until Close is called. onReload, if not nil,
+// is called after every reload attempt with its result.
+func (p *SQLRBACProvider) Watch(onReload func(error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return fmt.Errorf("SQL RBAC provider is already being watched")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(p.opts.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := p.Reload(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				if onReload != nil {
+					onReload(err)
+				}
+			}
+		}
+	}(p.done)
+	return nil
+}
+
+// Close stops periodic reloads. The last loaded roles remain available; the database is not closed.
+func (p *SQLRBACProvider) Close() error {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return nil
+}
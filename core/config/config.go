@@ -2,96 +2,236 @@ package config
 
 import (
 	"acacia/core/auth"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/fsnotify/fsnotify"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
 // AuthConfig holds the RBAC rules.
 type AuthConfig struct {
-	Roles []auth.Role `mapstructure:"roles"`
+	Roles     []auth.Role     `mapstructure:"roles"`
+	RolesFile string          `mapstructure:"roles_file"` // Watched YAML/JSON role file used instead of Roles, if set
+	JWT       *auth.JWTConfig `mapstructure:"jwt"`        // Bearer token verification for gateways, nil if disabled
+	Policies  []auth.Policy   `mapstructure:"policies"`   // Attribute-based access policies, evaluated before RBAC
+
+	APIKeysFile string `mapstructure:"api_keys_file"` // FileKeyStore holding service account API keys, empty if unused
+
+	DecisionCacheSize int          `mapstructure:"decision_cache_size"` // Cached permission decisions; 0 for the default, negative to disable
+	Audit             *AuditConfig `mapstructure:"audit"`               // Authorization audit trail, nil if disabled
+}
+
+// AuditConfig configures the authorization audit trail.
+type AuditConfig struct {
+	File    string   `mapstructure:"file"`    // JSON lines file the decisions are appended to
+	Allowed bool     `mapstructure:"allowed"` // Also record allowed decisions, not just denied ones
+	Skip    []string `mapstructure:"skip"`    // Permission patterns never recorded, e.g. "core.log"
 }
 
 // Config holds the application's configuration settings.
 type Config struct {
-	Environment    string                            `mapstructure:"environment"`
+	Version        int                               `mapstructure:"version" validate:"min=1"` // Format version of the config files, see CurrentVersion
+	Environment    string                            `mapstructure:"environment" validate:"oneof=development staging production"`
 	Auth           AuthConfig                        `mapstructure:"auth"`
 	Modules        map[string]map[string]interface{} `mapstructure:"modules"`        // Generic configuration for modules
 	Gateways       map[string]map[string]interface{} `mapstructure:"gateways"`       // Generic configuration for gateways
 	Infrastructure map[string]map[string]interface{} `mapstructure:"infrastructure"` // Generic configuration for infrastructure components
 	Timeouts       TimeoutsConfig                    `mapstructure:"timeouts"`       // Timeout configurations
+	Secrets        SecretsConfig                     `mapstructure:"secrets"`        // Where ${vault:...} references are resolved
+
+	source   string                 // Base config file, empty if there is none
+	tree     *layeredTree           // Merged layers, used to position validation errors and report origins
+	env      bool                   // Whether environment variables were read
+	settings map[string]interface{} // Effective settings, before secret references are resolved
+	secrets  map[string][]string    // Values expanded from secret references, and their secrets, redacted when printed
 }
 
-// TimeoutsConfig holds timeout settings for various operations.
-type TimeoutsConfig struct {
-	ConfigChange     int `mapstructure:"config_change_seconds"`
-	ModuleOperation  int `mapstructure:"module_operation_seconds"`
-	GatewayOperation int `mapstructure:"gateway_operation_seconds"`
+// SecretsConfig configures the local encrypted vault. Its settings may themselves be ${file:...}
+// or ${env:...} references, but not vault references.
+type SecretsConfig struct {
+	VaultFile string `mapstructure:"vault_file"` // Vault resolving ${vault:name}, empty if unused
+	VaultKey  string `mapstructure:"vault_key"`  // Base64 key of the vault, normally a reference such as ${env:ACACIA_VAULT_KEY}
 }
 
-// LoadConfig loads the application configuration from a specified source (e.g., file, environment variables).
-func LoadConfig() (*Config, error) {
-	v := viper.New()
+// TimeoutsConfig holds timeout settings for various operations. The three global settings are
+// the defaults of the lifecycle phases; Modules and Gateways override them per component.
+type TimeoutsConfig struct {
+	ConfigChange     int `mapstructure:"config_change_seconds" default:"5" validate:"min=1"`      // Default for PhaseOnConfigChanged
+	ModuleOperation  int `mapstructure:"module_operation_seconds" default:"10" validate:"min=1"`  // Default for the other module phases
+	GatewayOperation int `mapstructure:"gateway_operation_seconds" default:"10" validate:"min=1"` // Default for the other gateway phases
 
-	// Set configuration file name and type
-	v.SetConfigName("config") // name of config file (without extension)
-	v.SetConfigType("yaml")   // or "json", "toml", etc.
+	Modules  map[string]PhaseTimeouts `mapstructure:"modules"`  // Overrides by module name
+	Gateways map[string]PhaseTimeouts `mapstructure:"gateways"` // Overrides by gateway name
+}
 
-	// Add paths to search for the config file
-	v.AddConfigPath(".")           // current directory
-	v.AddConfigPath("./configs")   // a "configs" directory
-	v.AddConfigPath("/etc/acacia") // system-wide config
+// Phase is a lifecycle call of a module or gateway that the kernel bounds with a timeout.
+type Phase string
+
+// Lifecycle phases. Gateways only go through Configure, Start, Stop and OnConfigChanged, the
+// latter being their Configure call when their section changes.
+const (
+	PhaseOnLoad           Phase = "on_load"
+	PhaseConfigure        Phase = "configure"
+	PhaseStart            Phase = "start"
+	PhaseRegisterServices Phase = "register_services"
+	PhaseOnReady          Phase = "on_ready"
+	PhaseStop             Phase = "stop"
+	PhaseOnConfigChanged  Phase = "on_config_changed"
+)
 
-	// Read environment variables
-	v.AutomaticEnv()         // read in environment variables that match
-	v.SetEnvPrefix("ACACIA") // prefix for environment variables (e.g., ACACIA_SERVER_PORT)
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+// PhaseTimeouts overrides the timeouts of a component's lifecycle phases, written as Go
+// durations, e.g. "30s" or "2m". Zero keeps the default.
+type PhaseTimeouts struct {
+	OnLoad           time.Duration `mapstructure:"on_load" validate:"min=0s"`
+	Configure        time.Duration `mapstructure:"configure" validate:"min=0s"`
+	Start            time.Duration `mapstructure:"start" validate:"min=0s"`
+	RegisterServices time.Duration `mapstructure:"register_services" validate:"min=0s"`
+	OnReady          time.Duration `mapstructure:"on_ready" validate:"min=0s"`
+	Stop             time.Duration `mapstructure:"stop" validate:"min=0s"` // Takes precedence over the component's ShutdownTimeout
+	OnConfigChanged  time.Duration `mapstructure:"on_config_changed" validate:"min=0s"`
+}
 
-	// Set default values
-	v.SetDefault("server_port", 8080)
-	v.SetDefault("environment", "development")
-	v.SetDefault("timeouts.config_change_seconds", 5)
-	v.SetDefault("timeouts.module_operation_seconds", 10)
-	v.SetDefault("timeouts.gateway_operation_seconds", 10)
+// Get returns the timeout of phase, zero if it is not overridden.
+func (p PhaseTimeouts) Get(phase Phase) time.Duration {
+	switch phase {
+	case PhaseOnLoad:
+		return p.OnLoad
+	case PhaseConfigure:
+		return p.Configure
+	case PhaseStart:
+		return p.Start
+	case PhaseRegisterServices:
+		return p.RegisterServices
+	case PhaseOnReady:
+		return p.OnReady
+	case PhaseStop:
+		return p.Stop
+	case PhaseOnConfigChanged:
+		return p.OnConfigChanged
+	}
+	return 0
+}
 
-	// Attempt to read the config file
-	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			// Config file not found; ignore error and proceed with defaults and environment variables
-			fmt.Fprintln(os.Stderr, "Config file not found, using defaults and environment variables.")
-		} else {
-			// Config file found but another error occurred
-			return nil, fmt.Errorf("failed to read config file: %w", err)
-		}
+// Override returns the timeout of phase configured for the component called name in section
+// (SectionModules or SectionGateways), and whether there is one.
+func (t TimeoutsConfig) Override(section, name string, phase Phase) (time.Duration, bool) {
+	overrides := t.Modules
+	if section == SectionGateways {
+		overrides = t.Gateways
 	}
+	timeout := overrides[name].Get(phase)
+	return timeout, timeout > 0
+}
 
-	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+// Default returns the timeout of phase for the components of section that do not override it.
+func (t TimeoutsConfig) Default(section string, phase Phase) time.Duration {
+	switch {
+	case phase == PhaseOnConfigChanged:
+		return time.Duration(t.ConfigChange) * time.Second
+	case section == SectionGateways:
+		return time.Duration(t.GatewayOperation) * time.Second
 	}
+	return time.Duration(t.ModuleOperation) * time.Second
+}
+
+// Timeout returns the timeout of phase for the component called name in section: its override
+// if it has one, otherwise the default.
+func (t TimeoutsConfig) Timeout(section, name string, phase Phase) time.Duration {
+	if timeout, ok := t.Override(section, name, phase); ok {
+		return timeout
+	}
+	return t.Default(section, phase)
+}
+
+// LoadOptions selects the layers Load merges into the configuration.
+type LoadOptions struct {
+	File      string           // Base config file; if empty, config.yaml is searched for in ".", "./configs" and "/etc/acacia"
+	Overrides []string         // "path=value" settings applied over every other layer, as given with --set
+	Providers []SecretProvider // Secret providers added to, or replacing, the built-in ones
+	Sources   []Source         // Remote layers merged over the files, in order, e.g. from config/remote
+	SourceKey string           // Base64 Ed25519 public key; if set, documents from Sources must be signed with its private key
+	Watch     bool             // For NewManager: reload when a layer file or a watchable source changes
+}
+
+// LoadConfig loads the application configuration from a specified source (e.g., file, environment variables).
+// Use NewManager to also pick up changes. Secret references are resolved with the file, env and vault providers,
+// plus the given providers, which replace a built-in provider of the same scheme. They are resolved again
+// on every reload.
+func LoadConfig(providers ...SecretProvider) (*Config, error) {
+	return Load(LoadOptions{Providers: providers})
+}
+
+// Load loads the layered configuration. In increasing precedence, the layers are:
+//   - the defaults,
+//   - the base config file,
+//   - the fragments in the conf.d directory next to it, in lexical order,
+//   - the overlay for the environment, e.g. config.production.yaml next to config.yaml,
+//   - opts.Sources, in order,
+//   - ACACIA_* environment variables,
+//   - opts.Overrides.
+//
+// See layeredTree for how the layers are merged. opts.Watch is ignored; see NewManager.
+func Load(opts LoadOptions) (*Config, error) {
+	return load(opts, true)
+}
 
-	// Store the viper instance to allow registering change hooks later
-	currentViper = v
+// ReadFile loads the configuration from the file at path alone, without fragments, overlays,
+// environment variables, module defaults or watching for changes. Secret references are resolved
+// and the result is validated like LoadConfig's.
+func ReadFile(path string, providers ...SecretProvider) (*Config, error) {
+	tree := newLayeredTree()
+	if err := tree.addFile(path); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	cfg, err := newConfig(path, tree, nil, false, providers)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
 
-	v.WatchConfig()
-	v.OnConfigChange(func(e fsnotify.Event) {
-		fmt.Println("Config file changed:", e.Name)
-		if err := v.Unmarshal(&cfg); err != nil {
-			fmt.Println(fmt.Errorf("failed to re-unmarshal config: %w", err))
+// load loads the layers selected by opts. verbose reports a missing config file.
+func load(opts LoadOptions, verbose bool) (*Config, error) {
+	overrides, err := parseOverrides(opts.Overrides)
+	if err != nil {
+		return nil, err
+	}
+	base := opts.File
+	if base == "" {
+		base = findConfigFile()
+		if base == "" && len(opts.Sources) == 0 && verbose {
+			// Config file not found; proceed with defaults and environment variables
+			fmt.Fprintln(os.Stderr, "Config file not found, using defaults and environment variables.")
 		}
-		// Notify all registered hooks
-		for _, hook := range configChangeHooks {
-			hook(&cfg)
+	}
+	tree := newLayeredTree()
+	if base != "" {
+		if err := tree.addLayers(base, overrides, true); err != nil {
+			return nil, err
 		}
-	})
+	}
+	if err := tree.addSources(opts.Sources, opts.SourceKey); err != nil {
+		return nil, err
+	}
+	cfg, err := newConfig(base, tree, overrides, true, opts.Providers)
+	if err != nil {
+		return nil, err
+	}
 
 	// Load module defaults
-	if err := LoadModuleDefaults(&cfg, "modules"); err != nil {
+	if err := LoadModuleDefaults(cfg, "modules"); err != nil {
 		fmt.Printf("Warning: failed to load module defaults: %v\n", err)
 		// Continue with main config even if module defaults fail
 	}
@@ -99,17 +239,96 @@ func LoadConfig() (*Config, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+	return cfg, nil
+}
 
-	return &cfg, nil
+// newConfig decodes the merged tree, with the overrides applied over it and, if useEnv is set,
+// ACACIA_* environment variables.
+func newConfig(source string, tree *layeredTree, overrides []override, useEnv bool, providers []SecretProvider) (*Config, error) {
+	for _, o := range overrides {
+		tree.addOverride(o)
+	}
+	v, err := tree.viper(overrides, useEnv)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{source: source, tree: tree, env: useEnv, settings: v.AllSettings()}
+	if err := unmarshal(v, providers, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
-// configChangeHooks stores functions to be called when the config changes.
-var configChangeHooks []func(*Config)
-var currentViper *viper.Viper
+// unmarshal decodes the settings of v into cfg like viper's Unmarshal, after resolving their
+// secret references. The vault settings are resolved first, to open the vault the other settings
+// may refer to. Unresolvable references are reported together, positioned in cfg's source file.
+func unmarshal(v *viper.Viper, providers []SecretProvider, cfg *Config) error {
+	settings := v.AllSettings()
+	resolver := newSecretResolver(providers)
+
+	var errs ValidationErrors
+	if section, ok := settings["secrets"]; ok {
+		settings["secrets"] = resolver.resolveAll(section, "secrets", &errs)
+		if len(errs) > 0 {
+			return cfg.locate("", errs)
+		}
+		var sc SecretsConfig
+		if err := decodeSettings(settings["secrets"], &sc); err != nil {
+			return fmt.Errorf("failed to unmarshal config: secrets: %w", err)
+		}
+		if sc.VaultFile != "" {
+			vault, err := OpenVault(sc.VaultFile, sc.VaultKey)
+			if err != nil {
+				return fmt.Errorf("secrets: %w", err)
+			}
+			if _, custom := resolver.providers[vault.Scheme()]; !custom {
+				resolver.register(vault)
+			}
+		}
+	}
+	for key, value := range settings {
+		if key != "secrets" {
+			settings[key] = resolver.resolveAll(value, key, &errs)
+		}
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+		return cfg.locate("", errs)
+	}
+
+	if err := decodeSettings(settings, cfg); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	cfg.secrets = resolver.resolved
+	return nil
+}
+
+// decodeSettings decodes viper settings with the options viper's Unmarshal uses.
+func decodeSettings(settings interface{}, out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           out,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(settings)
+}
 
-// AddConfigChangeHook registers a function to be called when the configuration changes.
-func (c *Config) AddConfigChangeHook(hook func(*Config)) {
-	configChangeHooks = append(configChangeHooks, hook)
+// setDefaults sets the default values of the settings that have one.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server_port", 8080)
+	v.SetDefault("version", CurrentVersion) // Older files are migrated as they are read
+	v.SetDefault("environment", "development")
+	v.SetDefault("timeouts.config_change_seconds", 5)
+	v.SetDefault("timeouts.module_operation_seconds", 10)
+	v.SetDefault("timeouts.gateway_operation_seconds", 10)
+	v.SetDefault("secrets.vault_file", "") // Known keys can be set with ACACIA_SECRETS_VAULT_FILE
+	v.SetDefault("secrets.vault_key", "")
 }
 
 // LoadModuleDefaults loads default configurations from all modules
@@ -118,6 +337,10 @@ func LoadModuleDefaults(cfg *Config, modulesDir string) error {
 		cfg.Modules = make(map[string]map[string]interface{})
 	}
 
+	if _, err := os.Stat(modulesDir); errors.Is(err, fs.ErrNotExist) {
+		return nil // Not in a source tree, so there are no module defaults
+	}
+
 	// Walk through modules directory to find default configs
 	return filepath.WalkDir(modulesDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -174,6 +397,7 @@ func mergeModuleConfig(existing, defaults map[string]interface{}) {
 // GenerateFromModules generates a complete config from all module defaults
 func GenerateFromModules(modulesDir string) (*Config, error) {
 	cfg := &Config{
+		Version:     CurrentVersion,
 		Environment: "development",
 		Modules:     make(map[string]map[string]interface{}),
 		Gateways:    make(map[string]map[string]interface{}),
@@ -218,6 +442,7 @@ func UpdateModuleDefaults(modulesDir string) error {
 // GenerateMinimalConfig creates a minimal config with essential settings
 func GenerateMinimalConfig() *Config {
 	return &Config{
+		Version:     CurrentVersion,
 		Environment: "development",
 		Modules:     make(map[string]map[string]interface{}),
 		Gateways: map[string]map[string]interface{}{
@@ -286,13 +511,16 @@ modules:
 	return os.WriteFile(configPath, []byte(defaultConfig), 0644)
 }
 
-// Validate checks the configuration for required fields and valid values.
+// Validate checks the configuration against the validate tags of its fields, reporting all
+// violations as ValidationErrors, and checks the role definitions.
 func (c *Config) Validate() error {
-	switch c.Environment {
-	case "development", "staging", "production":
-		// valid
-	default:
-		return fmt.Errorf("invalid environment: %q", c.Environment)
+	var errs ValidationErrors
+	validateStruct(reflect.ValueOf(c).Elem(), "", &errs)
+	if len(errs) > 0 {
+		return c.locate("", errs)
+	}
+	if err := auth.ValidateRoles(c.Auth.Roles); err != nil {
+		return fmt.Errorf("auth: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind tells how a setting changed between two snapshots.
+type ChangeKind int
+
+const (
+	ChangeAdded    ChangeKind = iota + 1 // The setting is new
+	ChangeRemoved                        // The setting is gone
+	ChangeModified                       // The setting has a different value
+)
+
+// String returns "added", "removed" or "modified".
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// MarshalText encodes the kind as its name, so that change sets read well in JSON.
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a kind encoded by MarshalText.
+func (k *ChangeKind) UnmarshalText(text []byte) error {
+	for _, kind := range []ChangeKind{ChangeAdded, ChangeRemoved, ChangeModified} {
+		if kind.String() == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown change kind %q", text)
+}
+
+// Change is one setting that differs between two snapshots. Maps are compared key by key, so
+// a change names the deepest setting that changed; lists, like scalars, change as a whole.
+type Change struct {
+	Path string      `json:"path"`          // Dotted path of the setting, e.g. "modules.chat.max_users"
+	Kind ChangeKind  `json:"kind"`          // How it changed
+	Old  interface{} `json:"old,omitempty"` // Previous value, nil if added. Values read from secrets are redacted.
+	New  interface{} `json:"new,omitempty"` // New value, nil if removed. Values read from secrets are redacted.
+}
+
+// String describes the change, e.g. "modules.chat.max_users modified: 10 -> 20".
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("%s added: %v", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("%s removed: %v", c.Path, c.Old)
+	}
+	return fmt.Sprintf("%s %s: %v -> %v", c.Path, c.Kind, c.Old, c.New)
+}
+
+// ChangeSet lists the changes between two snapshots, sorted by path.
+type ChangeSet []Change
+
+// Under returns the changes at or below prefix, with paths relative to it. A change of prefix
+// itself, e.g. a whole section being added, keeps an empty path.
+func (cs ChangeSet) Under(prefix string) ChangeSet {
+	var under ChangeSet
+	for _, c := range cs {
+		switch {
+		case c.Path == prefix:
+			c.Path = ""
+		case strings.HasPrefix(c.Path, prefix+"."):
+			c.Path = c.Path[len(prefix)+1:]
+		default:
+			continue
+		}
+		under = append(under, c)
+	}
+	return under
+}
+
+// Changed reports whether the setting at path, or any setting below it, changed.
+func (cs ChangeSet) Changed(path string) bool {
+	for _, c := range cs {
+		if c.Path == path || strings.HasPrefix(c.Path, path+".") || strings.HasPrefix(path, c.Path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Component returns the changes to the section of the component called name, with paths
+// relative to the section, or nil if it did not change.
+func (cs ChangeSet) Component(section, name string) ChangeSet {
+	return cs.Under(section + "." + name)
+}
+
+// ComponentChange is what a module or gateway is given when its section of the configuration
+// changed: the new section and what changed in it.
+type ComponentChange struct {
+	Section string      // SectionModules or SectionGateways
+	Name    string      // Name of the component
+	Config  interface{} // New section: the decoded typed config for a TypedConfig component, else a map[string]interface{}
+	Changes ChangeSet   // Changes within the section, with paths relative to it; never empty
+}
+
+// Diff compares two snapshots structurally and returns what changed from old to new. Either may
+// be nil, standing for an empty configuration.
+func Diff(old, new *Config) ChangeSet {
+	if old == nil {
+		old = &Config{}
+	}
+	if new == nil {
+		new = &Config{}
+	}
+	var changes ChangeSet
+	diffValues("", reflect.ValueOf(*old), reflect.ValueOf(*new), &changes)
+	for i := range changes {
+		changes[i].Old = old.redact(changes[i].Old)
+		changes[i].New = new.redact(changes[i].New)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// diffValues appends the differences between old and new, found at path, to changes. Structs
+// are compared field by field and maps key by key, also when the other side is missing, so that
+// changes name leaf settings; anything else is compared with DeepEqual.
+func diffValues(path string, old, new reflect.Value, changes *ChangeSet) {
+	old, new = indirect(old), indirect(new)
+	switch {
+	case !old.IsValid() && !new.IsValid():
+		return
+	case isStruct(old, new):
+		structFields(old, func(f reflect.StructField, fv reflect.Value, name string) {
+			diffValues(joinPath(path, name), fv, new.FieldByIndex(f.Index), changes)
+		})
+		return
+	case isMap(old) && (isMap(new) || !new.IsValid()) || !old.IsValid() && isMap(new):
+		for _, key := range mapKeys(old, new) {
+			diffValues(joinPath(path, key), mapIndex(old, key), mapIndex(new, key), changes)
+		}
+		return
+	case !old.IsValid():
+		*changes = append(*changes, Change{Path: path, Kind: ChangeAdded, New: new.Interface()})
+	case !new.IsValid():
+		*changes = append(*changes, Change{Path: path, Kind: ChangeRemoved, Old: old.Interface()})
+	case !reflect.DeepEqual(old.Interface(), new.Interface()):
+		*changes = append(*changes, Change{Path: path, Kind: ChangeModified, Old: old.Interface(), New: new.Interface()})
+	}
+}
+
+func isStruct(old, new reflect.Value) bool {
+	return old.Kind() == reflect.Struct && new.IsValid() && new.Type() == old.Type()
+}
+
+func isMap(v reflect.Value) bool {
+	return v.IsValid() && v.Kind() == reflect.Map
+}
+
+// indirect unwraps interfaces and pointers. Nil values and nil or empty maps become the invalid
+// Value, so that an empty section and a missing one compare equal.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	if v.IsValid() && v.Kind() == reflect.Map && v.Len() == 0 {
+		return reflect.Value{}
+	}
+	return v
+}
+
+// mapKeys returns the keys of the maps as strings, sorted and without duplicates. Invalid
+// values stand for missing maps.
+func mapKeys(maps ...reflect.Value) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		if !m.IsValid() {
+			continue
+		}
+		for _, key := range m.MapKeys() {
+			name := fmt.Sprint(key.Interface())
+			if !seen[name] {
+				seen[name] = true
+				keys = append(keys, name)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mapIndex returns the value of m for the key whose string form is key.
+func mapIndex(m reflect.Value, key string) reflect.Value {
+	if !m.IsValid() {
+		return reflect.Value{}
+	}
+	if m.Type().Key().Kind() == reflect.String {
+		return m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key()))
+	}
+	for _, k := range m.MapKeys() {
+		if fmt.Sprint(k.Interface()) == key {
+			return m.MapIndex(k)
+		}
+	}
+	return reflect.Value{}
+}
+
+// redact hides the secrets of c in value, which may be shared with c, so it works on a copy.
+func (c *Config) redact(value interface{}) interface{} {
+	if len(c.secrets) == 0 || value == nil {
+		return value
+	}
+	return redactValue(copyValue(value), c.secrets)
+}
+
+// copyValue copies the maps and slices of a tree of settings.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,54 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+
+	"acacia/core/config"
+)
+
+func TestDiff(t *testing.T) {
+	old := &config.Config{
+		Environment: "development",
+		Modules: map[string]map[string]interface{}{
+			"chat":  {"workers": 4, "rooms": []interface{}{"lobby"}, "listener": map[string]interface{}{"address": ":9000"}},
+			"stats": {"interval": "1m"},
+		},
+		Timeouts: config.TimeoutsConfig{ConfigChange: 5},
+	}
+	new := &config.Config{
+		Environment: "development",
+		Modules: map[string]map[string]interface{}{
+			"chat":  {"workers": 8, "rooms": []interface{}{"lobby", "help"}, "listener": map[string]interface{}{"address": ":9000", "backlog": 64}},
+			"stats": {"interval": "1m"},
+			"echo":  {"prefix": "> "},
+		},
+		Gateways: map[string]map[string]interface{}{}, // Empty, as good as missing
+		Timeouts: config.TimeoutsConfig{ConfigChange: 5},
+	}
+
+	changes := config.Diff(old, new)
+	want := config.ChangeSet{
+		{Path: "modules.chat.listener.backlog", Kind: config.ChangeAdded, New: 64},
+		{Path: "modules.chat.rooms", Kind: config.ChangeModified, Old: []interface{}{"lobby"}, New: []interface{}{"lobby", "help"}},
+		{Path: "modules.chat.workers", Kind: config.ChangeModified, Old: 4, New: 8},
+		{Path: "modules.echo.prefix", Kind: config.ChangeAdded, New: "> "},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("Diff =\n%v\nwant\n%v", changes, want)
+	}
+
+	chat := changes.Component(config.SectionModules, "chat")
+	if len(chat) != 3 || chat[0].Path != "listener.backlog" || !chat.Changed("listener") {
+		t.Errorf("chat changes = %v", chat)
+	}
+	if stats := changes.Component(config.SectionModules, "stats"); stats != nil {
+		t.Errorf("unchanged stats section has changes %v", stats)
+	}
+	if changes := config.Diff(new, new); len(changes) != 0 {
+		t.Errorf("Diff of a snapshot with itself = %v", changes)
+	}
+	if removed := config.Diff(new, old).Component(config.SectionModules, "echo"); len(removed) != 1 || removed[0].Kind != config.ChangeRemoved {
+		t.Errorf("removed section changes = %v", removed)
+	}
+}
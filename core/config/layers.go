@@ -0,0 +1,485 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// OverrideSource names settings given on the command line with --set, wherever a file name
+// would be reported.
+const OverrideSource = "--set"
+
+// fragmentDir is the directory, next to the base config file, whose files are merged into it.
+const fragmentDir = "conf.d"
+
+// Kinds of Origin.
+const (
+	OriginFile     = "file"
+	OriginEnv      = "env"
+	OriginOverride = "override"
+	OriginDefault  = "default"
+)
+
+// Origin is where the effective value of a setting comes from.
+type Origin struct {
+	Kind string // OriginFile, OriginEnv, OriginOverride or OriginDefault
+	File string // Config file, for OriginFile
+	Line int    // Line in File, for OriginFile
+	Env  string // Environment variable, for OriginEnv
+}
+
+func (o Origin) String() string {
+	switch o.Kind {
+	case OriginFile:
+		return fmt.Sprintf("%s:%d", o.File, o.Line)
+	case OriginEnv:
+		return "env " + o.Env
+	case OriginOverride:
+		return OverrideSource
+	}
+	return o.Kind
+}
+
+// layeredTree merges the configuration files and overrides into one YAML tree. Nodes keep
+// their position, and the file each came from is recorded, so that errors and values can be
+// traced back to the layer that set them.
+//
+// Merge rules, applied to each layer in turn:
+//   - Maps are merged key by key, recursively. Keys match case-insensitively, as in viper.
+//   - Scalars and lists replace the previous value. A key written as "name+" appends its list
+//     to the previous one instead.
+//   - A null value removes the key, restoring its default.
+type layeredTree struct {
+	root   *yaml.Node
+	files  map[*yaml.Node]string // Layer each node was read from: a file, a remote Source's name, or OverrideSource
+	layers []string              // Files and sources merged so far, in order, then OverrideSource if any
+}
+
+func newLayeredTree() *layeredTree {
+	return &layeredTree{
+		root:  &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+		files: make(map[*yaml.Node]string),
+	}
+}
+
+// addFile merges the YAML (or JSON) file at path. An empty file is an empty layer.
+func (t *layeredTree) addFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return t.addDocument(path, data)
+}
+
+// addDocument merges a YAML (or JSON) document read from source, a file or a remote Source.
+func (t *layeredTree) addDocument(source string, data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse %s: %w", source, err)
+	}
+	t.layers = append(t.layers, source)
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: top level must be a mapping", source)
+	}
+	applied, err := migrateRoot(doc.Content[0], source)
+	if err != nil {
+		return err
+	}
+	if len(applied) > 0 {
+		fmt.Printf("Warning: %s uses an older config version and was migrated in memory; update it with `acacia config migrate`:\n  %s\n",
+			source, strings.Join(applied, "\n  "))
+	}
+	t.add(source, doc.Content[0])
+	return nil
+}
+
+// addOverride merges a value given as path=value on the command line.
+func (t *layeredTree) addOverride(o override) {
+	node := o.value
+	segments := strings.Split(o.path, ".")
+	for i := len(segments) - 1; i >= 0; i-- {
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: segments[i]}, node,
+		}}
+	}
+	if len(t.layers) == 0 || t.layers[len(t.layers)-1] != OverrideSource {
+		t.layers = append(t.layers, OverrideSource)
+	}
+	t.add(OverrideSource, node)
+}
+
+func (t *layeredTree) add(source string, node *yaml.Node) {
+	var record func(n *yaml.Node)
+	record = func(n *yaml.Node) {
+		t.files[n] = source
+		for _, child := range n.Content {
+			record(child)
+		}
+	}
+	record(node)
+	t.merge(t.root, node)
+}
+
+// merge merges the mapping node src into dst, following the rules of layeredTree.
+func (t *layeredTree) merge(dst, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		name := key.Value
+		appendList := strings.HasSuffix(name, "+")
+		if appendList {
+			name = strings.TrimSuffix(name, "+")
+			trimmed := *key
+			trimmed.Value = name
+			t.files[&trimmed] = t.files[key]
+			key = &trimmed
+		}
+
+		idx := -1
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if strings.EqualFold(dst.Content[j].Value, name) {
+				idx = j
+				break
+			}
+		}
+		switch {
+		case value.Tag == "!!null":
+			if idx >= 0 {
+				dst.Content = append(dst.Content[:idx], dst.Content[idx+2:]...)
+			}
+		case idx < 0:
+			dst.Content = append(dst.Content, key, value)
+		case dst.Content[idx+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			t.merge(dst.Content[idx+1], value)
+		case appendList && dst.Content[idx+1].Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			merged := *value
+			merged.Content = append(append([]*yaml.Node(nil), dst.Content[idx+1].Content...), value.Content...)
+			t.files[&merged] = t.files[value]
+			dst.Content[idx], dst.Content[idx+1] = key, &merged // The list is now attributed to the layer that extended it
+		default:
+			dst.Content[idx], dst.Content[idx+1] = key, value
+		}
+	}
+}
+
+// lookup follows path through the tree. It returns the key node of path, or of its deepest
+// ancestor in the tree, the value node of path if it is there, and whether it is. The key of a
+// list item is the item itself.
+func (t *layeredTree) lookup(path []string) (key, value *yaml.Node, found bool) {
+	node := t.root
+	for _, seg := range path {
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if strings.EqualFold(node.Content[i].Value, seg) {
+					key, next = node.Content[i], node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(seg); err == nil && i >= 0 && i < len(node.Content) {
+				key, next = node.Content[i], node.Content[i]
+			}
+		}
+		if next == nil {
+			return key, nil, false
+		}
+		if next.Kind == yaml.AliasNode {
+			next = next.Alias
+		}
+		node = next
+	}
+	return key, node, true
+}
+
+// position returns the file and line of path, or of its deepest ancestor that is in the tree.
+func (t *layeredTree) position(path string) (file string, line int) {
+	key, _, _ := t.lookup(splitPath(path))
+	if key == nil {
+		return "", 0
+	}
+	return t.files[key], key.Line
+}
+
+// settings decodes the merged tree into the nested maps viper works with.
+func (t *layeredTree) settings() (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	if err := t.root.Decode(&settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// value decodes the merged value at path, nil if it is not in the tree.
+func (t *layeredTree) value(path string) interface{} {
+	_, node, found := t.lookup(splitPath(path))
+	if !found {
+		return nil
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// override is a setting given on the command line.
+type override struct {
+	path  string // Dotted path; a trailing "+" appends to a list
+	value *yaml.Node
+}
+
+// parseOverrides parses "path=value" settings. Values are YAML, so "5" is a number,
+// "[a, b]" a list and "null" removes the setting.
+func parseOverrides(settings []string) ([]override, error) {
+	overrides := make([]override, 0, len(settings))
+	for _, setting := range settings {
+		path, raw, ok := strings.Cut(setting, "=")
+		path = strings.TrimSpace(path)
+		if !ok || path == "" || strings.Contains(path, "..") || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+			return nil, fmt.Errorf("invalid setting %q, want path=value", setting)
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(raw), &doc); err != nil {
+			return nil, fmt.Errorf("invalid value in %q: %w", setting, err)
+		}
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: ""}
+		if len(doc.Content) > 0 {
+			value = doc.Content[0]
+		}
+		overrides = append(overrides, override{path: path, value: value})
+	}
+	return overrides, nil
+}
+
+// configSearchPaths are searched, in order, for config.yaml when no file is given.
+var configSearchPaths = []string{".", "./configs", "/etc/acacia"}
+
+// findConfigFile returns the first config file in the search paths, or "" if there is none.
+func findConfigFile() string {
+	for _, dir := range configSearchPaths {
+		for _, ext := range []string{".yaml", ".yml", ".json"} {
+			path := filepath.Join(dir, "config"+ext)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// overlayFile returns the environment overlay of base, e.g. config.production.yaml for config.yaml.
+func overlayFile(base, environment string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + environment + ext
+}
+
+// fragmentFiles returns the fragments in the conf.d directory next to base, in lexical order.
+func fragmentFiles(base string) []string {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, _ := filepath.Glob(filepath.Join(filepath.Dir(base), fragmentDir, pattern))
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// selectedEnvironment returns the environment whose overlay applies: an override wins, then
+// ACACIA_ENVIRONMENT, then the files merged so far, then the default.
+func selectedEnvironment(t *layeredTree, overrides []override, useEnv bool) string {
+	environment := "development"
+	if v, ok := t.value("environment").(string); ok && v != "" {
+		environment = v
+	}
+	if useEnv {
+		if v, ok := os.LookupEnv(envVar("environment")); ok && v != "" {
+			environment = v
+		}
+	}
+	for _, o := range overrides {
+		if strings.EqualFold(o.path, "environment") && o.value.Kind == yaml.ScalarNode && o.value.Value != "" {
+			environment = o.value.Value
+		}
+	}
+	return environment
+}
+
+// envVar returns the environment variable viper reads the setting at path from.
+func envVar(path string) string {
+	return "ACACIA_" + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// origin returns where the effective value at path comes from, following viper's precedence:
+// overrides, then environment variables, then files, then defaults.
+func (t *layeredTree) origin(path string, useEnv bool) Origin {
+	key, _, found := t.lookup(splitPath(path))
+	if found && key != nil && t.files[key] == OverrideSource {
+		return Origin{Kind: OriginOverride}
+	}
+	if useEnv {
+		if _, ok := os.LookupEnv(envVar(path)); ok {
+			return Origin{Kind: OriginEnv, Env: envVar(path)}
+		}
+	}
+	if found && key != nil {
+		return Origin{Kind: OriginFile, File: t.files[key], Line: key.Line}
+	}
+	return Origin{Kind: OriginDefault}
+}
+
+// addLayers merges the base config file, its fragments and its environment overlay.
+func (t *layeredTree) addLayers(base string, overrides []override, useEnv bool) error {
+	if err := t.addFile(base); err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	for _, fragment := range fragmentFiles(base) {
+		if err := t.addFile(fragment); err != nil {
+			return fmt.Errorf("failed to read config fragment: %w", err)
+		}
+	}
+	overlay := overlayFile(base, selectedEnvironment(t, overrides, useEnv))
+	if _, err := os.Stat(overlay); err == nil {
+		if err := t.addFile(overlay); err != nil {
+			return fmt.Errorf("failed to read environment overlay: %w", err)
+		}
+	}
+	return nil
+}
+
+// viper loads the merged tree into a new viper instance, with the defaults below it and, if
+// useEnv is set, ACACIA_* environment variables above it. The overrides, already merged into
+// the tree, are set again so that they also take precedence over environment variables, like
+// command line flags.
+func (t *layeredTree) viper(overrides []override, useEnv bool) (*viper.Viper, error) {
+	v := viper.New()
+	setDefaults(v)
+	if useEnv {
+		v.AutomaticEnv()         // read in environment variables that match
+		v.SetEnvPrefix("ACACIA") // prefix for environment variables (e.g., ACACIA_SERVER_PORT)
+		v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	}
+	settings, err := t.settings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge config layers: %w", err)
+	}
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, fmt.Errorf("failed to merge config layers: %w", err)
+	}
+	for _, o := range overrides {
+		path := strings.TrimSuffix(o.path, "+")
+		if value := t.value(path); value != nil {
+			v.Set(path, value)
+		}
+	}
+	return v, nil
+}
+
+// watchDebounce is how long the layer files must be left alone before a change is reported.
+// Editors often save a file with several writes, or by writing a temporary file and renaming it.
+var watchDebounce = 250 * time.Millisecond
+
+// watchLayers calls onChange with the names of the changed files whenever the base config file,
+// one of its environment overlays or a fragment is written, created, removed or renamed. It
+// watches directories, not files, so that editors that replace files are handled. Bursts of
+// events are reported once, when no event came for watchDebounce. The returned function stops
+// watching.
+func watchLayers(base string, onChange func(names []string)) (stop func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Printf("Warning: config changes will not be picked up: %v\n", err)
+		return func() {}
+	}
+	dir, fragments := filepath.Dir(base), filepath.Join(filepath.Dir(base), fragmentDir)
+	if err := watcher.Add(dir); err != nil {
+		fmt.Printf("Warning: config changes will not be picked up: %v\n", err)
+		watcher.Close()
+		return func() {}
+	}
+	_ = watcher.Add(fragments) // Optional
+
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(filepath.Base(base), ext)
+	relevant := func(name string) bool {
+		switch filepath.Dir(name) {
+		case fragments:
+			switch filepath.Ext(name) {
+			case ".yaml", ".yml", ".json":
+				return true
+			}
+		case dir:
+			file := filepath.Base(name)
+			return file == filepath.Base(base) || (strings.HasPrefix(file, stem+".") && strings.HasSuffix(file, ext))
+		}
+		return false
+	}
+	go func() {
+		var changed []string
+		quiet := time.NewTimer(watchDebounce)
+		quiet.Stop()
+		defer quiet.Stop()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 && relevant(filepath.Clean(event.Name)) {
+					if !slices.Contains(changed, event.Name) {
+						changed = append(changed, event.Name)
+					}
+					quiet.Reset(watchDebounce)
+				}
+			case <-quiet.C:
+				onChange(changed)
+				changed = nil
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Println("Config watcher error:", err)
+			}
+		}
+	}()
+	return func() { watcher.Close() }
+}
+
+// Layers returns the files merged into the configuration, in increasing precedence, followed by
+// OverrideSource if settings were given on the command line.
+func (c *Config) Layers() []string {
+	if c.tree == nil {
+		return nil
+	}
+	return append([]string(nil), c.tree.layers...)
+}
+
+// Effective returns the merged settings before secret references are resolved, as nested maps
+// keyed by lower-case setting names. Use Origin to find where each value comes from.
+func (c *Config) Effective() map[string]interface{} {
+	return c.settings
+}
+
+// Origin returns where the effective value of the setting at path, e.g. "modules.chat.workers",
+// comes from.
+func (c *Config) Origin(path string) Origin {
+	if c.tree == nil {
+		return Origin{Kind: OriginDefault}
+	}
+	return c.tree.origin(path, c.env)
+}
@@ -0,0 +1,133 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"acacia/core/config"
+)
+
+// writeFiles writes files, keyed by path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": `environment: staging
+gateways:
+  httpapi:
+    address: ":8080"
+    debug: true
+    allowed_origins: [http://localhost:3000]
+    tags: [a, b]
+`,
+		"conf.d/10-chat.yaml": `modules:
+  chat:
+    workers: 2
+    mode: fast
+`,
+		"conf.d/20-chat.yaml": `modules:
+  chat:
+    workers: 4
+`,
+		"config.staging.yaml": `gateways:
+  httpapi:
+    debug: null
+    allowed_origins+: [https://staging.example.com]
+    tags: [c]
+`,
+		"config.production.yaml": `gateways:
+  httpapi:
+    address: ":443"
+`,
+	})
+	t.Setenv("ACACIA_TIMEOUTS_MODULE_OPERATION_SECONDS", "30")
+
+	base := filepath.Join(dir, "config.yaml")
+	cfg, err := config.Load(config.LoadOptions{
+		File:      base,
+		Overrides: []string{"modules.chat.mode=safe", "timeouts.module_operation_seconds=20"},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	wantLayers := []string{base, filepath.Join(dir, "conf.d/10-chat.yaml"), filepath.Join(dir, "conf.d/20-chat.yaml"),
+		filepath.Join(dir, "config.staging.yaml"), config.OverrideSource}
+	if got := cfg.Layers(); !reflect.DeepEqual(got, wantLayers) {
+		t.Errorf("layers = %v, want %v", got, wantLayers)
+	}
+
+	httpapi := cfg.Gateways["httpapi"]
+	if httpapi["address"] != ":8080" {
+		t.Errorf("address = %v, the production overlay should not apply", httpapi["address"])
+	}
+	if _, ok := httpapi["debug"]; ok {
+		t.Errorf("debug = %v, null should remove it", httpapi["debug"])
+	}
+	if got := httpapi["allowed_origins"]; !reflect.DeepEqual(got, []interface{}{"http://localhost:3000", "https://staging.example.com"}) {
+		t.Errorf("allowed_origins = %v, want the appended list", got)
+	}
+	if got := httpapi["tags"]; !reflect.DeepEqual(got, []interface{}{"c"}) {
+		t.Errorf("tags = %v, want the replaced list", got)
+	}
+	chat := cfg.Modules["chat"]
+	if chat["workers"] != 4 || chat["mode"] != "safe" {
+		t.Errorf("chat = %v", chat)
+	}
+	if cfg.Timeouts.ModuleOperation != 20 {
+		t.Errorf("module operation timeout = %d, overrides should beat environment variables", cfg.Timeouts.ModuleOperation)
+	}
+
+	origins := map[string]string{
+		"modules.chat.workers":              filepath.Join(dir, "conf.d/20-chat.yaml") + ":3",
+		"modules.chat.mode":                 config.OverrideSource,
+		"gateways.httpapi.allowed_origins":  filepath.Join(dir, "config.staging.yaml") + ":4",
+		"gateways.httpapi.address":          base + ":4",
+		"timeouts.config_change_seconds":    "default",
+		"timeouts.module_operation_seconds": config.OverrideSource,
+	}
+	for path, want := range origins {
+		if got := cfg.Origin(path).String(); got != want {
+			t.Errorf("Origin(%s) = %s, want %s", path, got, want)
+		}
+	}
+	t.Setenv("ACACIA_TIMEOUTS_GATEWAY_OPERATION_SECONDS", "15")
+	if got := cfg.Origin("timeouts.gateway_operation_seconds").String(); got != "env ACACIA_TIMEOUTS_GATEWAY_OPERATION_SECONDS" {
+		t.Errorf("origin of an environment variable = %s", got)
+	}
+}
+
+func TestLoad_ErrorsPointToLayer(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml":             "environment: development\n",
+		"config.development.yaml": "timeouts:\n  config_change_seconds: 0\n",
+	})
+	_, err := config.Load(config.LoadOptions{File: filepath.Join(dir, "config.yaml")})
+	var errs config.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("Load = %v, want one ValidationError", err)
+	}
+	if want := filepath.Join(dir, "config.development.yaml"); errs[0].File != want || errs[0].Line != 2 {
+		t.Errorf("error at %s:%d, want %s:2", errs[0].File, errs[0].Line, want)
+	}
+
+	if _, err := config.Load(config.LoadOptions{File: filepath.Join(dir, "config.yaml"), Overrides: []string{"novalue"}}); err == nil {
+		t.Error("an override without a value should be rejected")
+	}
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNotReloadable is returned by Manager.Reload for a configuration that was not loaded from files.
+var ErrNotReloadable = errors.New("configuration was not loaded from files")
+
+// ComponentResult tells how a component took a configuration change.
+type ComponentResult struct {
+	Component string `json:"component"`       // Section of the component, e.g. "modules.chat" or "auth.roles"
+	Error     string `json:"error,omitempty"` // Why the component rejected the change, empty if it accepted it
+}
+
+// ReloadReport tells what a new snapshot changed and how the components took it.
+type ReloadReport struct {
+	Changes    ChangeSet         `json:"changes"`    // Changes from the previous snapshot, empty if there were none
+	Components []ComponentResult `json:"components"` // Results of the components that were notified, sorted by component
+}
+
+// Rejected returns the results of the components that rejected the change.
+func (r *ReloadReport) Rejected() []ComponentResult {
+	var rejected []ComponentResult
+	for _, c := range r.Components {
+		if c.Error != "" {
+			rejected = append(rejected, c)
+		}
+	}
+	return rejected
+}
+
+// String summarizes the report, e.g. "2 change(s), accepted by modules.chat, rejected by
+// gateways.httpapi (port in use)".
+func (r *ReloadReport) String() string {
+	if len(r.Changes) == 0 {
+		return "no changes"
+	}
+	summary := fmt.Sprintf("%d change(s)", len(r.Changes))
+	var accepted, rejected []string
+	for _, c := range r.Components {
+		if c.Error == "" {
+			accepted = append(accepted, c.Component)
+		} else {
+			rejected = append(rejected, fmt.Sprintf("%s (%s)", c.Component, c.Error))
+		}
+	}
+	if len(accepted) > 0 {
+		summary += ", accepted by " + strings.Join(accepted, ", ")
+	}
+	if len(rejected) > 0 {
+		summary += ", rejected by " + strings.Join(rejected, ", ")
+	}
+	return summary
+}
+
+// Manager owns a loaded configuration. It hands out snapshots, replaces them atomically when the
+// configuration is reloaded and notifies subscribers of what changed. A snapshot is never
+// modified once published, so readers may keep and use it without locking; callers must not
+// modify it either.
+//
+// Managers are independent of each other: a process, or a test, may have several.
+type Manager struct {
+	opts    LoadOptions
+	static  bool // Created by NewStaticManager, so there is nothing to reload
+	current atomic.Pointer[Config]
+
+	updateMu sync.Mutex // Serializes reloads and updates, so that subscribers see snapshots in order
+
+	mu          sync.Mutex // Protects subscribers, validators and stopWatch
+	subscribers []subscriber
+	validators  []validator
+	nextID      uint64
+	stopWatch   func() // Stops watching the layer files and sources, nil if not watching
+}
+
+type subscriber struct {
+	id uint64
+	fn func(*Config, ChangeSet) []ComponentResult
+}
+
+type validator struct {
+	id uint64
+	fn func(*Config) error
+}
+
+// NewManager loads the configuration selected by opts. If opts.Watch is set, the manager reloads
+// it whenever one of its files or watchable sources changes, until Close is called.
+func NewManager(opts LoadOptions) (*Manager, error) {
+	cfg, err := Load(opts)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{opts: opts}
+	m.opts.File = cfg.source // Reload the same base file even if it was searched for
+	m.current.Store(cfg)
+	if opts.Watch {
+		stopFiles := func() {}
+		if cfg.source != "" {
+			stopFiles = watchLayers(cfg.source, func(names []string) {
+				m.reloadChanged(strings.Join(names, ", "))
+			})
+		}
+		stopSources := watchSources(opts.Sources, m.reloadChanged)
+		m.stopWatch = func() {
+			stopFiles()
+			stopSources()
+		}
+	}
+	return m, nil
+}
+
+// reloadChanged reloads the configuration after the named layers changed, and prints the outcome.
+func (m *Manager) reloadChanged(names string) {
+	fmt.Println("Config changed:", names)
+	report, err := m.Reload()
+	if err != nil {
+		fmt.Println(fmt.Errorf("failed to reload config, keeping the previous one: %w", err))
+		return
+	}
+	fmt.Println("Config reloaded:", report)
+}
+
+// NewStaticManager returns a manager for a configuration built in code, e.g. in tests. It can
+// only change through Update.
+func NewStaticManager(cfg *Config) *Manager {
+	m := &Manager{static: true}
+	m.current.Store(cfg)
+	return m
+}
+
+// Current returns the current snapshot.
+func (m *Manager) Current() *Config {
+	return m.current.Load()
+}
+
+// Subscribe registers fn to be called with every new snapshot that differs from the previous
+// one, after it has become current, along with the changes from the previous one. fn returns
+// how the components it manages took the change, for the reload report; it may return nil.
+// Calls are made one at a time, in order. The returned function unsubscribes fn; it is safe to
+// call more than once.
+func (m *Manager) Subscribe(fn func(cfg *Config, changes ChangeSet) []ComponentResult) (unsubscribe func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	id := m.nextID
+	m.subscribers = append(m.subscribers, subscriber{id: id, fn: fn})
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.subscribers = slices.DeleteFunc(m.subscribers, func(s subscriber) bool { return s.id == id })
+	}
+}
+
+// AddValidator registers fn to check every new snapshot before it becomes current. If fn
+// returns an error, the snapshot is rejected and the current one kept. The returned function
+// removes fn; it is safe to call more than once.
+func (m *Manager) AddValidator(fn func(*Config) error) (remove func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	id := m.nextID
+	m.validators = append(m.validators, validator{id: id, fn: fn})
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.validators = slices.DeleteFunc(m.validators, func(v validator) bool { return v.id == id })
+	}
+}
+
+// Reload loads the configuration again from its layers. If it is valid, it becomes current and
+// subscribers are notified; otherwise the current snapshot is kept and the error returned.
+func (m *Manager) Reload() (*ReloadReport, error) {
+	if m.static {
+		return nil, ErrNotReloadable
+	}
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+	next, err := load(m.opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return m.publish(next)
+}
+
+// Update makes cfg the current snapshot and notifies subscribers, unless a validator rejects it.
+// cfg must not be modified afterwards.
+func (m *Manager) Update(cfg *Config) (*ReloadReport, error) {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+	return m.publish(cfg)
+}
+
+// publish validates next, swaps it in and, if it differs from the previous snapshot, notifies
+// the subscribers. The caller holds updateMu.
+func (m *Manager) publish(next *Config) (*ReloadReport, error) {
+	m.mu.Lock()
+	validators := append([]validator(nil), m.validators...)
+	m.mu.Unlock()
+	for _, v := range validators {
+		if err := v.fn(next); err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+
+	report := &ReloadReport{Changes: Diff(m.current.Swap(next), next)}
+	if len(report.Changes) == 0 {
+		return report, nil
+	}
+	m.mu.Lock()
+	subscribers := append([]subscriber(nil), m.subscribers...)
+	m.mu.Unlock()
+	for _, s := range subscribers { // Outside mu, so that subscribers may unsubscribe
+		report.Components = append(report.Components, s.fn(next, report.Changes)...)
+	}
+	sort.SliceStable(report.Components, func(i, j int) bool {
+		return report.Components[i].Component < report.Components[j].Component
+	})
+	return report, nil
+}
+
+// Close stops watching the configuration files. Subscribers are kept, but will not be notified
+// of file changes any more.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	stop := m.stopWatch
+	m.stopWatch = nil
+	m.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package config_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"acacia/core/config"
+)
+
+func TestManager_ReloadAndSubscribe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("environment: development\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := config.NewManager(config.LoadOptions{File: path})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer m.Close()
+	first := m.Current()
+
+	var seen []string
+	unsubscribe := m.Subscribe(func(c *config.Config, changes config.ChangeSet) []config.ComponentResult {
+		if !changes.Changed("environment") {
+			t.Errorf("changes = %v, want environment", changes)
+		}
+		seen = append(seen, c.Environment)
+		return []config.ComponentResult{{Component: "environment"}}
+	})
+
+	if err := os.WriteFile(path, []byte("environment: staging\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	report, err := m.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(report.Changes) != 1 || len(report.Components) != 1 || len(report.Rejected()) != 0 {
+		t.Errorf("report = %s", report)
+	}
+	if m.Current().Environment != "staging" || first.Environment != "development" {
+		t.Errorf("current = %s, first = %s: snapshots must not change", m.Current().Environment, first.Environment)
+	}
+
+	// Reloading an unchanged configuration notifies nobody
+	if report, err := m.Reload(); err != nil || len(report.Changes) != 0 {
+		t.Fatalf("Reload = %v, %v", report, err)
+	}
+
+	// An invalid file is rejected and the current snapshot kept
+	if err := os.WriteFile(path, []byte("environment: prod\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Reload(); err == nil || m.Current().Environment != "staging" {
+		t.Errorf("Reload = %v, current = %s", err, m.Current().Environment)
+	}
+
+	unsubscribe()
+	unsubscribe() // Safe to repeat
+	m.Update(&config.Config{Environment: "production"})
+	if len(seen) != 1 || seen[0] != "staging" {
+		t.Errorf("notified of %v, want [staging]", seen)
+	}
+
+	if _, err := config.NewStaticManager(&config.Config{}).Reload(); !errors.Is(err, config.ErrNotReloadable) {
+		t.Errorf("static Reload = %v", err)
+	}
+}
+
+func TestManager_Watch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("environment: development\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := config.NewManager(config.LoadOptions{File: path, Watch: true})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer m.Close()
+	changed := make(chan int, 10)
+	m.Subscribe(func(c *config.Config, _ config.ChangeSet) []config.ComponentResult {
+		changed <- c.Timeouts.ConfigChange
+		return nil
+	})
+
+	// A new overlay for the current environment is picked up. Its burst of writes, as an editor
+	// would make, is loaded once.
+	overlay := filepath.Join(dir, "config.development.yaml")
+	for _, seconds := range []string{"6", "7"} {
+		if err := os.WriteFile(overlay, []byte("timeouts:\n  config_change_seconds: "+seconds+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case seconds := <-changed:
+		if seconds != 7 {
+			t.Errorf("loaded config_change_seconds = %d, want the final 7", seconds)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the overlay to be loaded")
+	}
+	select {
+	case seconds := <-changed:
+		t.Errorf("burst of writes loaded again (config_change_seconds = %d)", seconds)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestManager_ValidatorsAndReport(t *testing.T) {
+	m := config.NewStaticManager(&config.Config{Environment: "development"})
+	remove := m.AddValidator(func(c *config.Config) error {
+		if c.Environment == "production" {
+			return errors.New("not in production")
+		}
+		return nil
+	})
+	m.Subscribe(func(c *config.Config, changes config.ChangeSet) []config.ComponentResult {
+		return []config.ComponentResult{{Component: "modules.b"}, {Component: "modules.a", Error: "busy"}}
+	})
+
+	if _, err := m.Update(&config.Config{Environment: "production"}); err == nil || m.Current().Environment != "development" {
+		t.Fatalf("Update = %v, current = %s: the validator should reject it", err, m.Current().Environment)
+	}
+
+	report, err := m.Update(&config.Config{Environment: "staging"})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if rejected := report.Rejected(); len(rejected) != 1 || rejected[0].Component != "modules.a" {
+		t.Errorf("rejected = %v", rejected)
+	}
+	if want := "1 change(s), accepted by modules.b, rejected by modules.a (busy)"; report.String() != want {
+		t.Errorf("report = %q, want %q", report, want)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded config.ReloadReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	if decoded.Changes[0].Kind != config.ChangeModified || decoded.String() != report.String() {
+		t.Errorf("decoded report = %s from %s", &decoded, data)
+	}
+
+	remove()
+	if _, err := m.Update(&config.Config{Environment: "production"}); err != nil {
+		t.Errorf("Update after removing the validator: %v", err)
+	}
+}
@@ -0,0 +1,377 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the version of the config file format this build expects. Documents declare
+// theirs in the top-level version key; one without it is at version 1. Older documents are
+// upgraded by coreMigrations when loaded, in memory, and on disk by `acacia config migrate`.
+const CurrentVersion = 1
+
+// Keys holding the version of a document and of a component's section.
+const (
+	VersionKey          = "version"
+	ComponentVersionKey = "config_version"
+)
+
+// ErrNewerVersion is returned for a document, or a component's section, written for a newer
+// format than this build knows.
+var ErrNewerVersion = errors.New("config version is newer than supported")
+
+// Migration is a step upgrading a config document, or a component's section, to Version from
+// the version before it.
+type Migration struct {
+	Version     int                    // Version the step upgrades to, from 2 up
+	Description string                 // What the step changes, e.g. "rename listen to listener.address"
+	Apply       func(s *Section) error // Upgrades s; it must leave settings that are already upgraded alone
+}
+
+// MigratingConfig is implemented by modules and gateways whose configuration keys changed over
+// time. ConfigMigrations returns the steps that upgrade older sections of the component, e.g.
+// after renaming a key. A section records its version in its config_version key; one without it
+// is at version 1. Sections are upgraded when the component is configured, and on disk by
+// `acacia config migrate`.
+type MigratingConfig interface {
+	ConfigMigrations() []Migration
+}
+
+// coreMigrations upgrade config documents to CurrentVersion.
+var coreMigrations []Migration
+
+// Section is the part of a config document a Migration upgrades: the whole document for the
+// core migrations, or a component's section. Paths are dotted and relative to the section;
+// keys match case-insensitively, as when loading.
+type Section struct {
+	node *yaml.Node // Mapping node of the section
+}
+
+// Get decodes the value at path. ok is false if path is not set.
+func (s *Section) Get(path string) (value interface{}, ok bool) {
+	parent, i := s.find(path)
+	if i < 0 {
+		return nil, false
+	}
+	if err := parent.Content[i+1].Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Set sets the value at path, creating the maps leading to it.
+func (s *Section) Set(path string, value interface{}) error {
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return fmt.Errorf("set %s: %w", path, err)
+	}
+	return s.setNode(path, nil, node)
+}
+
+// Delete removes path, and reports whether it was set.
+func (s *Section) Delete(path string) bool {
+	parent, i := s.find(path)
+	if i < 0 {
+		return false
+	}
+	parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+	return true
+}
+
+// Rename moves the value at from to to, replacing any value there, such as a default merged in
+// for the new key. A key renamed within the same map keeps its place and comments. Rename
+// reports whether from was set.
+func (s *Section) Rename(from, to string) bool {
+	parent, i := s.find(from)
+	if i < 0 {
+		return false
+	}
+	key, value := parent.Content[i], parent.Content[i+1]
+	fromDir, _ := splitLast(from)
+	toDir, toName := splitLast(to)
+	if strings.EqualFold(fromDir, toDir) {
+		if j := indexOf(parent, toName); j >= 0 && j != i {
+			parent.Content = append(parent.Content[:j], parent.Content[j+2:]...)
+		}
+		key.Value = toName
+		return true
+	}
+	parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+	_ = s.setNode(to, key, value) // Cannot fail: value is already a node
+	return true
+}
+
+// find returns the mapping holding path and the index of its key there, or -1.
+func (s *Section) find(path string) (parent *yaml.Node, i int) {
+	node := s.node
+	segments := strings.Split(path, ".")
+	for _, seg := range segments[:len(segments)-1] {
+		j := indexOf(node, seg)
+		if j < 0 {
+			return nil, -1
+		}
+		node = node.Content[j+1]
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+	}
+	return node, indexOf(node, segments[len(segments)-1])
+}
+
+// setNode sets value at path, creating the maps leading to it. key, if not nil, is the key node
+// to use, so that its comments are kept; it is renamed to the last segment of path.
+func (s *Section) setNode(path string, key, value *yaml.Node) error {
+	node := s.node
+	segments := strings.Split(path, ".")
+	for n, seg := range segments {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("set %s: %s is not a map", path, strings.Join(segments[:n], "."))
+		}
+		j := indexOf(node, seg)
+		if n == len(segments)-1 {
+			if key == nil {
+				key = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+			}
+			key.Value = seg
+			if j >= 0 {
+				node.Content[j], node.Content[j+1] = key, value
+			} else {
+				node.Content = append(node.Content, key, value)
+			}
+			return nil
+		}
+		if j < 0 {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: seg},
+				&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+			j = len(node.Content) - 2
+		}
+		node = node.Content[j+1]
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+	}
+	return nil
+}
+
+// indexOf returns the index of the key called name in the mapping node, or -1.
+func indexOf(node *yaml.Node, name string) int {
+	if node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitLast splits "a.b.c" into "a.b" and "c".
+func splitLast(path string) (dir, name string) {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+// latestVersion returns the version migrations upgrade to, 1 if there are none.
+func latestVersion(migrations []Migration) int {
+	latest := 1
+	for _, m := range migrations {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+	return latest
+}
+
+// migrate applies to s the migrations above version, in order of version, and records the
+// version reached under versionKey. It returns the descriptions of the steps applied.
+func migrate(s *Section, migrations []Migration, versionKey, name string) ([]string, error) {
+	version := 1
+	if i := indexOf(s.node, versionKey); i >= 0 {
+		v, err := strconv.Atoi(s.node.Content[i+1].Value)
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("%s: %s must be a positive integer, got %q", name, versionKey, s.node.Content[i+1].Value)
+		}
+		version = v
+	}
+	latest := latestVersion(migrations)
+	if version > latest {
+		return nil, fmt.Errorf("%s: %w: %d, this build supports up to %d", name, ErrNewerVersion, version, latest)
+	}
+	if version == latest {
+		return nil, nil
+	}
+
+	steps := append([]Migration(nil), migrations...)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Version < steps[j].Version })
+	var applied []string
+	for _, step := range steps {
+		if step.Version <= version {
+			continue
+		}
+		if err := step.Apply(s); err != nil {
+			return nil, fmt.Errorf("%s: migrate to version %d: %w", name, step.Version, err)
+		}
+		applied = append(applied, fmt.Sprintf("%s: version %d: %s", name, step.Version, step.Description))
+	}
+	if err := s.Set(versionKey, latest); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// migrateRoot upgrades the root mapping of a document with the core migrations.
+func migrateRoot(root *yaml.Node, name string) ([]string, error) {
+	return migrate(&Section{node: root}, coreMigrations, VersionKey, name)
+}
+
+// MigrateSection returns raw, the section of component in the configuration, e.g.
+// "modules.chat", upgraded with the component's migrations if it implements MigratingConfig, and
+// without its config_version key. raw is not modified.
+func MigrateSection(name string, component interface{}, raw map[string]interface{}) (map[string]interface{}, error) {
+	var migrations []Migration
+	if m, ok := component.(MigratingConfig); ok {
+		migrations = m.ConfigMigrations()
+	}
+	version := 1
+	if v, ok := raw[ComponentVersionKey]; ok {
+		n, err := strconv.Atoi(fmt.Sprint(v))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%s: %s must be a positive integer, got %v", name, ComponentVersionKey, v)
+		}
+		version = n
+	}
+	if version == latestVersion(migrations) {
+		if _, ok := raw[ComponentVersionKey]; !ok {
+			return raw, nil
+		}
+		section := make(map[string]interface{}, len(raw))
+		for k, v := range raw {
+			if k != ComponentVersionKey {
+				section[k] = v
+			}
+		}
+		return section, nil
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(raw); err != nil {
+		return nil, err
+	}
+	if _, err := migrate(&Section{node: node}, migrations, ComponentVersionKey, name); err != nil {
+		return nil, err
+	}
+	section := make(map[string]interface{})
+	if err := node.Decode(&section); err != nil {
+		return nil, err
+	}
+	delete(section, ComponentVersionKey)
+	return section, nil
+}
+
+// MigrationReport tells what MigrateDocument changed.
+type MigrationReport struct {
+	From    int      // Version the document declared, 1 if it had none
+	To      int      // CurrentVersion
+	Applied []string // Steps applied, e.g. "modules.chat: version 2: rename listen to listener.address"
+	Changed bool     // Whether the document changed, if only to record its version
+}
+
+// MigrateDocument upgrades the config document data, read from name: the core migrations bring
+// it to CurrentVersion, and the migrations of the given modules and gateways, keyed by name,
+// upgrade their sections. YAML documents keep their comments and key order; JSON documents,
+// recognized by the .json extension of name, are written back as indented JSON.
+func MigrateDocument(name string, data []byte, modules, gateways map[string]MigratingConfig) ([]byte, *MigrationReport, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	report := &MigrationReport{From: 1, To: CurrentVersion}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s: top level must be a mapping", name)
+	}
+	if i := indexOf(root, VersionKey); i >= 0 {
+		report.From, _ = strconv.Atoi(root.Content[i+1].Value)
+	}
+
+	applied, err := migrateRoot(root, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Applied = applied
+	report.Changed = len(applied) > 0
+	if indexOf(root, VersionKey) < 0 {
+		// Record the version at the top of the document, where readers look for it, below the
+		// comment heading the document
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: VersionKey}
+		if len(root.Content) > 0 {
+			key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+		}
+		root.Content = append([]*yaml.Node{
+			key, {Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(CurrentVersion)},
+		}, root.Content...)
+		report.Changed = true
+	}
+	coreSteps := len(report.Applied)
+	for _, section := range []struct {
+		name       string
+		components map[string]MigratingConfig
+	}{{SectionModules, modules}, {SectionGateways, gateways}} {
+		parent := &Section{node: root}
+		for component, spec := range section.components {
+			p, i := parent.find(section.name + "." + component)
+			if i < 0 || p.Content[i+1].Kind != yaml.MappingNode {
+				continue // Not configured in this document
+			}
+			applied, err := migrate(&Section{node: p.Content[i+1]}, spec.ConfigMigrations(), ComponentVersionKey, section.name+"."+component)
+			if err != nil {
+				return nil, nil, err
+			}
+			report.Applied = append(report.Applied, applied...)
+			report.Changed = report.Changed || len(applied) > 0
+		}
+	}
+	sort.Strings(report.Applied[coreSteps:]) // Components in a stable order, after the core steps
+	if !report.Changed {
+		return data, report, nil // Not even reformatted
+	}
+
+	var out []byte
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		var value interface{}
+		if err := doc.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if out, err = json.MarshalIndent(value, "", "  "); err != nil {
+			return nil, nil, err
+		}
+		out = append(out, '\n')
+	} else {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return nil, nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, nil, err
+		}
+		out = buf.Bytes()
+	}
+	return out, report, nil
+}
@@ -0,0 +1,158 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"acacia/core/config"
+)
+
+// migratingChatSpec is chatSpec after two renames: listen became listener.address in version 2,
+// and threads became workers in version 3.
+type migratingChatSpec struct{ chatSpec }
+
+func (migratingChatSpec) ConfigMigrations() []config.Migration {
+	return []config.Migration{
+		{Version: 3, Description: "rename threads to workers", Apply: func(s *config.Section) error {
+			s.Rename("threads", "workers")
+			return nil
+		}},
+		{Version: 2, Description: "rename listen to listener.address", Apply: func(s *config.Section) error {
+			s.Rename("listen", "listener.address")
+			return nil
+		}},
+	}
+}
+
+var chatMigrations = map[string]config.MigratingConfig{"chat": migratingChatSpec{}}
+
+func TestMigrateDocument(t *testing.T) {
+	old := `# Chat server
+environment: staging
+modules:
+  chat:
+    listen: ":9000" # Public address
+    threads: 8 # One per core
+`
+	migrated, report, err := config.MigrateDocument("config.yaml", []byte(old), chatMigrations, nil)
+	if err != nil {
+		t.Fatalf("MigrateDocument: %v", err)
+	}
+	if !report.Changed || report.From != 1 || report.To != config.CurrentVersion || len(report.Applied) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Applied[0] != "modules.chat: version 2: rename listen to listener.address" {
+		t.Errorf("applied = %q", report.Applied)
+	}
+	want := `# Chat server
+version: 1
+environment: staging
+modules:
+  chat:
+    workers: 8 # One per core
+    listener:
+      address: ":9000" # Public address
+    config_version: 3
+`
+	if string(migrated) != want {
+		t.Errorf("migrated =\n%s\nwant\n%s", migrated, want)
+	}
+
+	// Migrating again changes nothing, not even the formatting
+	again, report, err := config.MigrateDocument("config.yaml", migrated, chatMigrations, nil)
+	if err != nil || report.Changed || string(again) != want {
+		t.Errorf("second migration: changed = %v, err = %v\n%s", report.Changed, err, again)
+	}
+}
+
+func TestMigrateDocument_JSON(t *testing.T) {
+	migrated, report, err := config.MigrateDocument("config.json", []byte(`{"modules": {"chat": {"listen": ":9000"}}}`), chatMigrations, nil)
+	if err != nil || !report.Changed {
+		t.Fatalf("MigrateDocument = %+v, %v", report, err)
+	}
+	want := `{
+  "modules": {
+    "chat": {
+      "config_version": 3,
+      "listener": {
+        "address": ":9000"
+      }
+    }
+  },
+  "version": 1
+}
+`
+	if string(migrated) != want {
+		t.Errorf("migrated =\n%s", migrated)
+	}
+}
+
+func TestMigrateDocument_NewerVersion(t *testing.T) {
+	for name, doc := range map[string]string{
+		"core":      "version: 99\n",
+		"component": "modules:\n  chat:\n    config_version: 4\n",
+	} {
+		if _, _, err := config.MigrateDocument("config.yaml", []byte(doc), chatMigrations, nil); !errors.Is(err, config.ErrNewerVersion) {
+			t.Errorf("%s: MigrateDocument = %v, want ErrNewerVersion", name, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("version: 99\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(config.LoadOptions{File: path}); !errors.Is(err, config.ErrNewerVersion) {
+		t.Errorf("Load = %v, want ErrNewerVersion", err)
+	}
+}
+
+func TestConfig_DecodeComponent_Migrates(t *testing.T) {
+	// The new key may already be there, e.g. from module defaults: the old setting wins
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "modules:\n  chat:\n    listen: \":9000\"\n    threads: 8\n    listener:\n      address: \":8000\"\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(config.LoadOptions{File: path})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Version != config.CurrentVersion {
+		t.Errorf("version = %d", cfg.Version)
+	}
+	typed, err := cfg.DecodeComponent(config.SectionModules, "chat", migratingChatSpec{})
+	if err != nil {
+		t.Fatalf("DecodeComponent: %v", err)
+	}
+	if chat := typed.(*chatConfig); chat.Listener.Address != ":9000" || chat.Workers != 8 {
+		t.Errorf("decoded = %+v", chat)
+	}
+	if _, ok := cfg.Modules["chat"]["listen"]; !ok {
+		t.Error("the snapshot was modified")
+	}
+
+	// Without migrations, the old keys are unknown
+	if _, err := cfg.DecodeComponent(config.SectionModules, "chat", chatSpec{}); err == nil || !strings.Contains(err.Error(), "listen") {
+		t.Errorf("DecodeComponent without migrations = %v", err)
+	}
+}
+
+func TestMigrateSection(t *testing.T) {
+	raw := map[string]interface{}{"config_version": 3, "workers": 8}
+	section, err := config.MigrateSection("modules.chat", migratingChatSpec{}, raw)
+	if err != nil {
+		t.Fatalf("MigrateSection: %v", err)
+	}
+	if _, ok := section["config_version"]; ok || section["workers"] != 8 {
+		t.Errorf("section = %v", section)
+	}
+	if _, ok := raw["config_version"]; !ok {
+		t.Error("raw was modified")
+	}
+	if _, err := config.MigrateSection("modules.chat", chatSpec{}, raw); !errors.Is(err, config.ErrNewerVersion) {
+		t.Errorf("MigrateSection without migrations = %v, want ErrNewerVersion", err)
+	}
+}
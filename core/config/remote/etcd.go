@@ -0,0 +1,207 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etcdRetry is how long EtcdKV waits before reopening a watch stream that ended.
+const etcdRetry = time.Second
+
+// EtcdKV is a KV backed by etcd, through the JSON gateway of its v3 API (/v3/kv/range and
+// /v3/watch), so that no etcd client library is needed.
+type EtcdKV struct {
+	Endpoint string       // Base URL of an etcd member, e.g. "http://127.0.0.1:2379"
+	Header   http.Header  // Added to every request, e.g. Authorization
+	Client   *http.Client // Zero means http.DefaultClient; it must not time out watch streams
+}
+
+// NewEtcdKV creates a KV for the etcd member at endpoint.
+func NewEtcdKV(endpoint string) *EtcdKV {
+	return &EtcdKV{Endpoint: strings.TrimSuffix(endpoint, "/")}
+}
+
+// etcdKeyValue is a key/value pair in the gateway's JSON, with bytes in base64, which is how
+// encoding/json encodes []byte, and 64-bit integers as strings.
+type etcdKeyValue struct {
+	Key         []byte `json:"key"`
+	Value       []byte `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+// Get returns the value of key.
+func (kv *EtcdKV) Get(ctx context.Context, key string) ([]byte, int64, error) {
+	var resp struct {
+		Kvs []etcdKeyValue `json:"kvs"`
+	}
+	if err := kv.call(ctx, "/v3/kv/range", map[string]interface{}{"key": []byte(key)}, &resp); err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, ErrKeyNotFound
+	}
+	revision, _ := strconv.ParseInt(resp.Kvs[0].ModRevision, 10, 64)
+	return resp.Kvs[0].Value, revision, nil
+}
+
+// Watch opens a watch stream on the keys starting with prefix. It reopens the stream if it
+// ends, calling onChange then in case changes were missed, until ctx is done.
+func (kv *EtcdKV) Watch(ctx context.Context, prefix string, onChange func()) error {
+	stream, err := kv.watch(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			kv.readWatch(stream, onChange)
+			stream.Close()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(etcdRetry):
+				}
+				if stream, err = kv.watch(ctx, prefix); err == nil {
+					break
+				}
+				fmt.Printf("Warning: failed to watch %s in %s: %v\n", prefix, kv.Endpoint, err)
+			}
+			onChange()
+		}
+	}()
+	return nil
+}
+
+// watch opens a watch stream and waits for etcd to confirm it.
+func (kv *EtcdKV) watch(ctx context.Context, prefix string) (*etcdWatchStream, error) {
+	body := map[string]interface{}{"create_request": map[string]interface{}{
+		"key":       []byte(prefix),
+		"range_end": prefixEnd([]byte(prefix)),
+	}}
+	resp, err := kv.post(ctx, "/v3/watch", body)
+	if err != nil {
+		return nil, err
+	}
+	stream := &etcdWatchStream{body: resp.Body, dec: json.NewDecoder(resp.Body)}
+	created, err := stream.next()
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("watch %s: %w", prefix, err)
+	}
+	if !created.Created {
+		resp.Body.Close()
+		return nil, fmt.Errorf("watch %s: not created", prefix)
+	}
+	return stream, nil
+}
+
+// readWatch calls onChange for every batch of events until the stream ends.
+func (kv *EtcdKV) readWatch(stream *etcdWatchStream, onChange func()) {
+	for {
+		result, err := stream.next()
+		if err != nil {
+			return
+		}
+		if result.Canceled {
+			return
+		}
+		if len(result.Events) > 0 {
+			onChange()
+		}
+	}
+}
+
+// etcdWatchStream decodes the results of a watch stream, one JSON object per message.
+type etcdWatchStream struct {
+	body interface{ Close() error }
+	dec  *json.Decoder
+}
+
+type etcdWatchResult struct {
+	Created  bool              `json:"created"`
+	Canceled bool              `json:"canceled"`
+	Events   []json.RawMessage `json:"events"`
+}
+
+func (s *etcdWatchStream) next() (etcdWatchResult, error) {
+	var msg struct {
+		Result *etcdWatchResult `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := s.dec.Decode(&msg); err != nil {
+		return etcdWatchResult{}, err
+	}
+	if msg.Error != nil {
+		return etcdWatchResult{}, fmt.Errorf("etcd: %s", msg.Error.Message)
+	}
+	if msg.Result == nil {
+		return etcdWatchResult{}, nil
+	}
+	return *msg.Result, nil
+}
+
+func (s *etcdWatchStream) Close() error {
+	return s.body.Close()
+}
+
+// call posts body to the gateway at path and decodes the response into result.
+func (kv *EtcdKV) call(ctx context.Context, path string, body, result interface{}) error {
+	resp, err := kv.post(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+func (kv *EtcdKV) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, kv.Endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range kv.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := kv.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("POST %s%s: %s", kv.Endpoint, path, resp.Status)
+	}
+	return resp, nil
+}
+
+// prefixEnd returns the end of the range of keys starting with prefix, as etcd expects it: the
+// prefix with its last byte below 0xff incremented, or "\x00" for every key.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
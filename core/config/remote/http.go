@@ -0,0 +1,180 @@
+// Package remote provides configuration sources kept outside the local file system: an HTTP
+// endpoint polled with ETags, and key/value stores such as etcd. Pass them to the config package
+// in LoadOptions.Sources; with LoadOptions.Watch, a config.Manager reloads when they change.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"acacia/core/config"
+)
+
+const (
+	// DefaultPollInterval is how often an HTTPSource checks its URL for changes when watched.
+	DefaultPollInterval = 30 * time.Second
+	// SignatureHeader carries the base64 signature of a document served over HTTP.
+	SignatureHeader = "X-Acacia-Signature"
+	// maxDocumentSize bounds the size of a fetched document.
+	maxDocumentSize = 10 << 20
+	// pollTimeout bounds each poll made by Watch, so a stalled server cannot stall later polls.
+	pollTimeout = 10 * time.Second
+)
+
+// HTTPSource fetches a configuration document from a URL. Requests are conditional on the ETag of
+// the last response, so an unchanged document costs a 304. The signature of the document, if
+// any, is read from the SignatureHeader response header or else from SignatureURL.
+type HTTPSource struct {
+	URL          string        // Address of the YAML or JSON document
+	SignatureURL string        // Where the signature is served if not in a header; defaults to URL + ".sig"
+	Header       http.Header   // Added to every request, e.g. Authorization
+	Interval     time.Duration // Polling interval when watched; zero means DefaultPollInterval
+	Client       *http.Client  // Zero means http.DefaultClient; requests are bounded by their context
+
+	mu   sync.Mutex
+	etag string
+	last config.Document
+	have bool // Whether last holds a document
+}
+
+// NewHTTPSource creates a source for the document at url.
+func NewHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{URL: url}
+}
+
+// Name returns the URL.
+func (s *HTTPSource) Name() string {
+	return s.URL
+}
+
+// Fetch returns the current document, from the cache if the server reports it unchanged.
+func (s *HTTPSource) Fetch(ctx context.Context) (config.Document, error) {
+	doc, _, err := s.fetch(ctx)
+	return doc, err
+}
+
+// Watch polls the URL every Interval and calls onChange when the document changed.
+func (s *HTTPSource) Watch(ctx context.Context, onChange func()) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pollCtx, cancel := context.WithTimeout(ctx, pollTimeout)
+				_, changed, err := s.fetch(pollCtx)
+				cancel()
+				if err != nil {
+					fmt.Printf("Warning: failed to poll %s: %v\n", s.URL, err)
+				} else if changed {
+					onChange()
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// fetch requests the document, conditionally on the last ETag, and reports whether it differs
+// from the previous one. s.mu is only held to read and store the cached state, never across a
+// request, so one slow request does not hold up other fetches.
+func (s *HTTPSource) fetch(ctx context.Context) (doc config.Document, changed bool, err error) {
+	s.mu.Lock()
+	etag, last, have := s.etag, s.last, s.have
+	s.mu.Unlock()
+
+	resp, err := s.get(ctx, s.URL, etag)
+	if err != nil {
+		return config.Document{}, false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && have:
+		return last, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return config.Document{}, false, fmt.Errorf("GET %s: %s", s.URL, resp.Status)
+	}
+	data, err := readDocument(resp.Body)
+	if err != nil {
+		return config.Document{}, false, fmt.Errorf("GET %s: %w", s.URL, err)
+	}
+	doc = config.Document{Data: data, Signature: resp.Header.Get(SignatureHeader), Revision: resp.Header.Get("ETag")}
+	if doc.Signature == "" {
+		if doc.Signature, err = s.signature(ctx); err != nil {
+			return config.Document{}, false, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed = !s.have || !bytes.Equal(doc.Data, s.last.Data) || doc.Signature != s.last.Signature
+	s.etag, s.last, s.have = resp.Header.Get("ETag"), doc, true
+	return doc, changed, nil
+}
+
+// signature fetches the detached signature of the document; a missing one is not an error, so
+// that unsigned documents can be used when no key is configured.
+func (s *HTTPSource) signature(ctx context.Context) (string, error) {
+	url := s.SignatureURL
+	if url == "" {
+		url = s.URL + ".sig"
+	}
+	resp, err := s.get(ctx, url, "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := readDocument(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("GET %s: %w", url, err)
+		}
+		return string(bytes.TrimSpace(data)), nil
+	case http.StatusNotFound:
+		return "", nil
+	}
+	return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+}
+
+func (s *HTTPSource) get(ctx context.Context, url, etag string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range s.Header {
+		req.Header[key] = values
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// readDocument reads a response body of at most maxDocumentSize bytes.
+func readDocument(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDocumentSize {
+		return nil, errors.New("document too large")
+	}
+	return data, nil
+}
@@ -0,0 +1,162 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"acacia/core/config"
+)
+
+// SignatureSuffix is appended to the key of a document to get the key of its signature.
+const SignatureSuffix = ".sig"
+
+// ErrKeyNotFound is returned by KV.Get for a missing key.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KV is the pluggable key/value store a KVSource reads from, such as etcd.
+// Implementations must be safe for concurrent use.
+type KV interface {
+	// Get returns the value of key and the revision it was last modified at, or ErrKeyNotFound.
+	Get(ctx context.Context, key string) (value []byte, revision int64, err error)
+	// Watch calls onChange whenever one of the keys starting with prefix is put or deleted,
+	// until ctx is done. It returns once watching has started.
+	Watch(ctx context.Context, prefix string, onChange func()) error
+}
+
+// KVSource reads a configuration document from a key of a KV store, and its signature, if any,
+// from the key followed by SignatureSuffix.
+type KVSource struct {
+	kv   KV
+	key  string
+	name string
+}
+
+// NewKVSource creates a source for the document at key in kv. name identifies the store in
+// origins and errors, e.g. "etcd://config:2379"; the key is appended to it.
+func NewKVSource(kv KV, name, key string) *KVSource {
+	return &KVSource{kv: kv, key: key, name: name + "/" + key}
+}
+
+// Name returns the store name followed by the key.
+func (s *KVSource) Name() string {
+	return s.name
+}
+
+// Fetch reads the document and its signature.
+func (s *KVSource) Fetch(ctx context.Context) (config.Document, error) {
+	data, revision, err := s.kv.Get(ctx, s.key)
+	if err != nil {
+		return config.Document{}, fmt.Errorf("get %s: %w", s.key, err)
+	}
+	doc := config.Document{Data: data, Revision: strconv.FormatInt(revision, 10)}
+	sig, _, err := s.kv.Get(ctx, s.key+SignatureSuffix)
+	switch {
+	case err == nil:
+		doc.Signature = string(sig)
+	case !errors.Is(err, ErrKeyNotFound):
+		return config.Document{}, fmt.Errorf("get %s: %w", s.key+SignatureSuffix, err)
+	}
+	return doc, nil
+}
+
+// Watch calls onChange when the document or its signature changes. Both share the document's
+// key as prefix.
+func (s *KVSource) Watch(ctx context.Context, onChange func()) error {
+	return s.kv.Watch(ctx, s.key, onChange)
+}
+
+// MemoryKV is an in-process KV. It is a convenient stand-in for a real store in tests, and lets
+// a process that embeds the configuration push changes to it.
+type MemoryKV struct {
+	mu       sync.Mutex
+	values   map[string]memoryValue
+	revision int64
+	watchers map[int]memoryWatcher
+	nextID   int
+}
+
+type memoryValue struct {
+	data     []byte
+	revision int64
+}
+
+type memoryWatcher struct {
+	prefix   string
+	onChange func()
+}
+
+// NewMemoryKV creates an empty MemoryKV.
+func NewMemoryKV() *MemoryKV {
+	return &MemoryKV{values: make(map[string]memoryValue), watchers: make(map[int]memoryWatcher)}
+}
+
+// Get returns the value of key.
+func (kv *MemoryKV) Get(ctx context.Context, key string) ([]byte, int64, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	v, ok := kv.values[key]
+	if !ok {
+		return nil, 0, ErrKeyNotFound
+	}
+	return append([]byte(nil), v.data...), v.revision, nil
+}
+
+// Put sets the value of key and notifies the watchers of its prefixes.
+func (kv *MemoryKV) Put(key string, value []byte) {
+	kv.mu.Lock()
+	kv.revision++
+	kv.values[key] = memoryValue{data: append([]byte(nil), value...), revision: kv.revision}
+	watchers := kv.watching(key)
+	kv.mu.Unlock()
+	for _, fn := range watchers {
+		fn()
+	}
+}
+
+// Delete removes key and notifies the watchers of its prefixes. Deleting a missing key is not
+// an error.
+func (kv *MemoryKV) Delete(key string) {
+	kv.mu.Lock()
+	_, ok := kv.values[key]
+	delete(kv.values, key)
+	kv.revision++
+	var watchers []func()
+	if ok {
+		watchers = kv.watching(key)
+	}
+	kv.mu.Unlock()
+	for _, fn := range watchers {
+		fn()
+	}
+}
+
+// Watch calls onChange on every change to a key starting with prefix, until ctx is done.
+func (kv *MemoryKV) Watch(ctx context.Context, prefix string, onChange func()) error {
+	kv.mu.Lock()
+	id := kv.nextID
+	kv.nextID++
+	kv.watchers[id] = memoryWatcher{prefix: prefix, onChange: onChange}
+	kv.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		kv.mu.Lock()
+		delete(kv.watchers, id)
+		kv.mu.Unlock()
+	}()
+	return nil
+}
+
+// watching returns the callbacks of the watchers of key. The caller holds mu.
+func (kv *MemoryKV) watching(key string) []func() {
+	var fns []func()
+	for _, w := range kv.watchers {
+		if strings.HasPrefix(key, w.prefix) {
+			fns = append(fns, w.onChange)
+		}
+	}
+	return fns
+}
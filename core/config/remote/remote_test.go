@@ -0,0 +1,315 @@
+package remote_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"acacia/core/config"
+	"acacia/core/config/remote"
+)
+
+// baseFile writes a local config file for the remote layers to be merged over.
+func baseFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("environment: development\ntimeouts:\n  config_change_seconds: 3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// documentServer serves a document with an ETag and, if signed, its signature in a header.
+type documentServer struct {
+	mu          sync.Mutex
+	doc         string
+	sig         string
+	requests    int
+	notModified int
+}
+
+func (s *documentServer) set(doc, sig string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.doc, s.sig = doc, sig
+}
+
+func (s *documentServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasSuffix(r.URL.Path, ".sig") {
+		http.NotFound(w, r)
+		return
+	}
+	s.requests++
+	etag := fmt.Sprintf("%q", base64.RawURLEncoding.EncodeToString([]byte(s.doc+s.sig)))
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if s.sig != "" {
+		w.Header().Set(remote.SignatureHeader, s.sig)
+	}
+	fmt.Fprint(w, s.doc)
+}
+
+func TestHTTPSource(t *testing.T) {
+	public, private, err := config.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(doc string) string {
+		sig, err := config.SignDocument(private, []byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	doc := "timeouts:\n  config_change_seconds: 7\nmodules:\n  chat:\n    workers: 4\n"
+	server := &documentServer{doc: doc}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	src := remote.NewHTTPSource(ts.URL + "/acacia.yaml")
+
+	// Remote layers are merged over the local files
+	opts := config.LoadOptions{File: baseFile(t), Sources: []config.Source{src}}
+	cfg, err := config.Load(opts)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Timeouts.ConfigChange != 7 || cfg.Environment != "development" {
+		t.Errorf("config_change_seconds = %d, environment = %s", cfg.Timeouts.ConfigChange, cfg.Environment)
+	}
+	if origin := cfg.Origin("modules.chat.workers"); origin.File != src.Name() || origin.Line != 5 {
+		t.Errorf("origin = %s", origin)
+	}
+
+	// An unchanged document is revalidated with its ETag
+	if _, err := config.Load(opts); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if server.notModified != 1 {
+		t.Errorf("%d of %d requests answered with 304", server.notModified, server.requests)
+	}
+
+	// With a key, documents must be signed with it
+	opts.SourceKey = public
+	if _, err := config.Load(opts); !errors.Is(err, config.ErrBadSignature) {
+		t.Errorf("unsigned document: Load = %v", err)
+	}
+	server.set(doc, sign("environment: production\n"))
+	if _, err := config.Load(opts); !errors.Is(err, config.ErrBadSignature) {
+		t.Errorf("signature of another document: Load = %v", err)
+	}
+	server.set(doc, sign(doc))
+	if _, err := config.Load(opts); err != nil {
+		t.Errorf("signed document: Load = %v", err)
+	}
+}
+
+func TestHTTPSource_Watch(t *testing.T) {
+	server := &documentServer{doc: "modules:\n  chat:\n    workers: 4\n"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	src := remote.NewHTTPSource(ts.URL + "/acacia.yaml")
+	src.Interval = 10 * time.Millisecond
+
+	m, err := config.NewManager(config.LoadOptions{File: baseFile(t), Sources: []config.Source{src}, Watch: true})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer m.Close()
+	changes := make(chan config.ChangeSet, 10)
+	m.Subscribe(func(cfg *config.Config, cs config.ChangeSet) []config.ComponentResult {
+		changes <- cs
+		return nil
+	})
+
+	server.set("modules:\n  chat:\n    workers: 8\n", "")
+	select {
+	case cs := <-changes:
+		if len(cs) != 1 || cs[0].Path != "modules.chat.workers" {
+			t.Errorf("changes = %v", cs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the remote change")
+	}
+}
+
+func TestHTTPSource_StalledPollDoesNotBlockFetch(t *testing.T) {
+	server := &documentServer{doc: "modules:\n  chat:\n    workers: 4\n"}
+	stalled, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first := false
+		once.Do(func() { first = true })
+		if first {
+			close(stalled)
+			<-release // The first poll hangs until the test ends
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	defer close(release) // Before closing the server, which waits for the stalled poll
+	src := remote.NewHTTPSource(ts.URL + "/acacia.yaml")
+	src.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := src.Watch(ctx, func() {}); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	select {
+	case <-stalled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch never polled")
+	}
+
+	fetchCtx, fetchCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer fetchCancel()
+	doc, err := src.Fetch(fetchCtx)
+	if err != nil {
+		t.Fatalf("Fetch while a poll is stalled: %v", err)
+	}
+	if !strings.Contains(string(doc.Data), "workers: 4") {
+		t.Errorf("Fetch = %q", doc.Data)
+	}
+}
+
+func TestKVSource(t *testing.T) {
+	public, private, err := config.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv := remote.NewMemoryKV()
+	put := func(doc string) {
+		sig, err := config.SignDocument(private, []byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		kv.Put("acacia/config", []byte(doc))
+		kv.Put("acacia/config"+remote.SignatureSuffix, []byte(sig))
+	}
+	put("modules:\n  chat:\n    workers: 4\n")
+	src := remote.NewKVSource(kv, "memory", "acacia/config")
+
+	m, err := config.NewManager(config.LoadOptions{File: baseFile(t), Sources: []config.Source{src}, SourceKey: public, Watch: true})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	defer m.Close()
+	if workers := m.Current().Modules["chat"]["workers"]; workers != 4 {
+		t.Fatalf("workers = %v", workers)
+	}
+
+	// MemoryKV notifies synchronously: the document is reloaded once it is signed again
+	put("modules:\n  chat:\n    workers: 8\n")
+	if workers := m.Current().Modules["chat"]["workers"]; workers != 8 {
+		t.Errorf("workers = %v after the change", workers)
+	}
+
+	// A document whose signature does not match is rejected, keeping the current one
+	kv.Put("acacia/config", []byte("modules:\n  chat:\n    workers: 16\n"))
+	if workers := m.Current().Modules["chat"]["workers"]; workers != 8 {
+		t.Errorf("workers = %v after an unsigned change", workers)
+	}
+
+	kv.Delete("acacia/config")
+	if _, err := m.Reload(); !errors.Is(err, remote.ErrKeyNotFound) {
+		t.Errorf("Reload of a deleted key = %v", err)
+	}
+}
+
+// etcdGateway emulates the parts of etcd's v3 JSON gateway that EtcdKV uses.
+type etcdGateway struct {
+	kv      *remote.MemoryKV
+	watches chan string // Receives the key of every watch stream opened
+}
+
+func (g *etcdGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key           []byte `json:"key"`
+		CreateRequest *struct {
+			Key      []byte `json:"key"`
+			RangeEnd []byte `json:"range_end"`
+		} `json:"create_request"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		value, revision, err := g.kv.Get(r.Context(), string(req.Key))
+		resp := map[string]interface{}{"header": map[string]string{"revision": "1"}}
+		if err == nil {
+			resp["kvs"] = []map[string]interface{}{{"key": req.Key, "value": value, "mod_revision": fmt.Sprint(revision)}}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	case "/v3/watch":
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		events := make(chan struct{}, 10)
+		_ = g.kv.Watch(ctx, string(req.CreateRequest.Key), func() { events <- struct{}{} })
+		enc := json.NewEncoder(w)
+		_ = enc.Encode(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+		w.(http.Flusher).Flush()
+		g.watches <- string(req.CreateRequest.Key)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-events:
+				_ = enc.Encode(map[string]interface{}{"result": map[string]interface{}{"events": []map[string]string{{"type": "PUT"}}}})
+				w.(http.Flusher).Flush()
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestEtcdKV(t *testing.T) {
+	store := remote.NewMemoryKV()
+	gateway := &etcdGateway{kv: store, watches: make(chan string, 1)}
+	ts := httptest.NewServer(gateway)
+	defer ts.Close()
+	kv := remote.NewEtcdKV(ts.URL)
+
+	if _, _, err := kv.Get(context.Background(), "acacia/config"); !errors.Is(err, remote.ErrKeyNotFound) {
+		t.Errorf("Get of a missing key = %v", err)
+	}
+	store.Put("acacia/config", []byte("environment: staging\n"))
+	value, revision, err := kv.Get(context.Background(), "acacia/config")
+	if err != nil || string(value) != "environment: staging\n" || revision != 1 {
+		t.Errorf("Get = %q, %d, %v", value, revision, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 10)
+	if err := kv.Watch(ctx, "acacia/config", func() { changed <- struct{}{} }); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if key := <-gateway.watches; key != "acacia/config" {
+		t.Errorf("watching %q", key)
+	}
+	store.Put("acacia/config"+remote.SignatureSuffix, []byte("signature"))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watch event")
+	}
+}
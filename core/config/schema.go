@@ -0,0 +1,377 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaDialect is the JSON Schema version of generated schemas.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches the durations time.ParseDuration accepts, e.g. "1h30m" or "250ms".
+const durationPattern = `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+// Schema is a JSON Schema, limited to the keywords GenerateSchema emits.
+type Schema struct {
+	Dialect     string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // false or a *Schema for the values
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	Enum      []interface{} `json:"enum,omitempty"`
+	Default   interface{}   `json:"default,omitempty"`
+	Pattern   string        `json:"pattern,omitempty"`
+	Minimum   *float64      `json:"minimum,omitempty"`
+	Maximum   *float64      `json:"maximum,omitempty"`
+	MinLength *int          `json:"minLength,omitempty"`
+	MaxLength *int          `json:"maxLength,omitempty"`
+	MinItems  *int          `json:"minItems,omitempty"`
+	MaxItems  *int          `json:"maxItems,omitempty"`
+}
+
+// GenerateSchema returns the JSON Schema of Config. The typed configs of the given modules and
+// gateways, keyed by component name, describe their sections; other components may hold any
+// object. Field names, defaults and constraints come from the same mapstructure, default and
+// validate tags the loader uses. Unknown keys are only allowed at the top level, where viper
+// settings such as server_port live.
+func GenerateSchema(modules, gateways map[string]TypedConfig) *Schema {
+	s := schemaOf(reflect.TypeOf(Config{}), true)
+	s.AdditionalProperties = nil
+	s.Dialect = SchemaDialect
+	s.Title = "Acacia configuration"
+	s.Properties[SectionModules] = sectionSchema(modules)
+	s.Properties[SectionGateways] = sectionSchema(gateways)
+	return s
+}
+
+// ComponentSchema returns the JSON Schema of a component's typed config. Unknown keys are not
+// allowed, as when decoding.
+func ComponentSchema(spec TypedConfig) *Schema {
+	return schemaOf(reflect.TypeOf(spec.ConfigSpec()), true)
+}
+
+func sectionSchema(specs map[string]TypedConfig) *Schema {
+	s := &Schema{Type: "object", AdditionalProperties: &Schema{Type: "object"}}
+	if len(specs) > 0 {
+		s.Properties = make(map[string]*Schema, len(specs))
+		one := 1.0
+		for name, spec := range specs {
+			component := ComponentSchema(spec)
+			if component.Properties == nil {
+				component.Properties = make(map[string]*Schema)
+			}
+			component.Properties[ComponentVersionKey] = &Schema{Type: "integer", Minimum: &one, Description: "Version of the section's format, see MigratingConfig"}
+			s.Properties[name] = component
+		}
+	}
+	return s
+}
+
+// schemaOf returns the schema of values of type t. strict disallows unknown keys in structs.
+func schemaOf(t reflect.Type, strict bool) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return &Schema{Type: "string", Pattern: durationPattern}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem(), strict)}
+	case reflect.Map:
+		s := &Schema{Type: "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			s.AdditionalProperties = schemaOf(t.Elem(), strict)
+		}
+		return s
+	case reflect.Struct:
+		return structSchema(t, strict)
+	}
+	return &Schema{} // Anything, e.g. interface{} values
+}
+
+func structSchema(t reflect.Type, strict bool) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	if strict {
+		s.AdditionalProperties = false
+	}
+	structFields(reflect.New(t).Elem(), func(f reflect.StructField, fv reflect.Value, name string) {
+		key := strings.ToLower(name) // Viper lowercases keys, so that is what the file is matched against
+		prop := schemaOf(f.Type, strict)
+		prop.Description = f.Tag.Get("description")
+		def, hasDefault := f.Tag.Lookup("default")
+		if hasDefault {
+			prop.Default = typedValue(f.Type, def)
+		}
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			switch name {
+			case "required":
+				if !hasDefault {
+					s.Required = append(s.Required, key)
+				}
+			case "min", "max":
+				applyBound(prop, f.Type, name, arg)
+			case "oneof":
+				for _, option := range strings.Fields(arg) {
+					prop.Enum = append(prop.Enum, typedValue(f.Type, option))
+				}
+			}
+		}
+		s.Properties[key] = prop
+	})
+	sort.Strings(s.Required)
+	return s
+}
+
+// typedValue converts a tag value to the JSON value of a field of type t, e.g. "4" to 4 for
+// ints. Durations stay strings, as they are written in the file.
+func typedValue(t reflect.Type, s string) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return s
+	}
+	v := reflect.New(t).Elem()
+	if err := setFromString(v, s); err != nil {
+		return s
+	}
+	return v.Interface()
+}
+
+// applyBound maps a min or max rule to the matching keyword for the field's type. Duration
+// bounds cannot be expressed in JSON Schema and are only checked when decoding.
+func applyBound(s *Schema, t reflect.Type, name, arg string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == durationType {
+		return
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return
+		}
+		switch {
+		case t.Kind() == reflect.String && name == "min":
+			s.MinLength = &n
+		case t.Kind() == reflect.String:
+			s.MaxLength = &n
+		case name == "min":
+			s.MinItems = &n
+		default:
+			s.MaxItems = &n
+		}
+	default:
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return
+		}
+		if name == "min" {
+			s.Minimum = &f
+		} else {
+			s.Maximum = &f
+		}
+	}
+}
+
+// ValidateSchema checks the file the configuration was loaded from against s, reporting every
+// violation with its position. It is stricter than loading: a quoted number, for instance,
+// is a string to the schema even though the loader would convert it.
+func (c *Config) ValidateSchema(s *Schema) error {
+	if c.tree == nil {
+		return nil // No files, so nothing but defaults and environment variables
+	}
+	v := schemaValidator{files: c.tree.files}
+	v.validate(s, c.tree.root, "", nil)
+	if len(v.errs) == 0 {
+		return nil
+	}
+	// Group by layer, in the order the layers were merged
+	order := make(map[string]int)
+	for i, layer := range c.tree.layers {
+		order[layer] = i
+	}
+	errs := v.errs
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].File != errs[j].File {
+			return order[errs[i].File] < order[errs[j].File]
+		}
+		return errs[i].Line < errs[j].Line
+	})
+	return errs
+}
+
+// schemaValidator collects the violations of a merged configuration tree, attributing each to
+// the layer its node came from.
+type schemaValidator struct {
+	files map[*yaml.Node]string
+	errs  ValidationErrors
+}
+
+// validate checks node against s. key is the key holding node, where missing required keys
+// are reported; nil for the root.
+func (v *schemaValidator) validate(s *Schema, node *yaml.Node, path string, key *yaml.Node) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	fail := func(at *yaml.Node, msg string) {
+		v.errs = append(v.errs, FieldError{File: v.files[at], Line: at.Line, Path: path, Message: msg})
+	}
+	if node.Tag == "!!null" {
+		return // Same as leaving the key out
+	}
+	if node.Kind == yaml.ScalarNode && IsSecretRef(node.Value) {
+		return // Checked once resolved, when the config is decoded
+	}
+	if s.Type != "" && !nodeHasType(node, s.Type) {
+		fail(node, "must be "+article(s.Type)+" "+s.Type)
+		return
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name := strings.ToLower(key.Value)
+			present[name] = true
+			prop, known := s.Properties[name]
+			if !known {
+				switch extra := s.AdditionalProperties.(type) {
+				case bool:
+					if !extra {
+						v.errs = append(v.errs, FieldError{File: v.files[key], Line: key.Line, Path: joinPath(path, key.Value), Message: "unknown key"})
+						continue
+					}
+				case *Schema:
+					prop = extra
+				}
+			}
+			if prop != nil {
+				v.validate(prop, value, joinPath(path, key.Value), key)
+			}
+		}
+		for _, name := range s.Required {
+			if !present[name] {
+				missing := FieldError{Path: joinPath(path, name), Message: "is required"}
+				if key != nil {
+					missing.File, missing.Line = v.files[key], key.Line
+				}
+				v.errs = append(v.errs, missing)
+			}
+		}
+		if s.MinItems != nil && len(node.Content)/2 < *s.MinItems {
+			fail(node, fmt.Sprintf("must have length at least %d", *s.MinItems))
+		}
+		if s.MaxItems != nil && len(node.Content)/2 > *s.MaxItems {
+			fail(node, fmt.Sprintf("must have length at most %d", *s.MaxItems))
+		}
+	case yaml.SequenceNode:
+		if s.Items != nil {
+			for i, item := range node.Content {
+				v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), item)
+			}
+		}
+		if s.MinItems != nil && len(node.Content) < *s.MinItems {
+			fail(node, fmt.Sprintf("must have length at least %d", *s.MinItems))
+		}
+		if s.MaxItems != nil && len(node.Content) > *s.MaxItems {
+			fail(node, fmt.Sprintf("must have length at most %d", *s.MaxItems))
+		}
+	case yaml.ScalarNode:
+		if msg := checkScalar(s, node); msg != "" {
+			fail(node, msg)
+		}
+	}
+}
+
+// nodeHasType reports whether node is a JSON Schema type.
+func nodeHasType(node *yaml.Node, typ string) bool {
+	switch typ {
+	case "object":
+		return node.Kind == yaml.MappingNode
+	case "array":
+		return node.Kind == yaml.SequenceNode
+	case "string":
+		return node.Kind == yaml.ScalarNode && node.Tag == "!!str"
+	case "boolean":
+		return node.Kind == yaml.ScalarNode && node.Tag == "!!bool"
+	case "integer":
+		return node.Kind == yaml.ScalarNode && node.Tag == "!!int"
+	case "number":
+		return node.Kind == yaml.ScalarNode && (node.Tag == "!!int" || node.Tag == "!!float")
+	}
+	return true
+}
+
+func article(typ string) string {
+	if strings.IndexByte("aeiou", typ[0]) >= 0 {
+		return "an"
+	}
+	return "a"
+}
+
+// checkScalar returns why a scalar breaks the value keywords of s, or "".
+func checkScalar(s *Schema, node *yaml.Node) string {
+	if len(s.Enum) > 0 {
+		options := make([]string, len(s.Enum))
+		found := false
+		for i, option := range s.Enum {
+			options[i] = fmt.Sprint(option)
+			found = found || options[i] == node.Value
+		}
+		if !found {
+			return fmt.Sprintf("must be one of %s, got %q", strings.Join(options, ", "), node.Value)
+		}
+	}
+	if s.Pattern != "" && s.Type == "string" {
+		if _, err := time.ParseDuration(node.Value); err != nil {
+			return fmt.Sprintf("must be a duration such as \"30s\", got %q", node.Value)
+		}
+	}
+	if s.MinLength != nil && len([]rune(node.Value)) < *s.MinLength {
+		return fmt.Sprintf("must have length at least %d", *s.MinLength)
+	}
+	if s.MaxLength != nil && len([]rune(node.Value)) > *s.MaxLength {
+		return fmt.Sprintf("must have length at most %d", *s.MaxLength)
+	}
+	if s.Minimum != nil || s.Maximum != nil {
+		n, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return ""
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return "must be at least " + strconv.FormatFloat(*s.Minimum, 'f', -1, 64)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return "must be at most " + strconv.FormatFloat(*s.Maximum, 'f', -1, 64)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,366 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces resolved secrets wherever the configuration is printed.
+const Redacted = "[REDACTED]"
+
+// ErrSecretNotFound is returned by a SecretProvider that has no secret under the requested name.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// secretRefPattern matches a secret reference such as ${file:/run/secrets/db} or ${env:DB_PASSWORD}.
+// A reference preceded by another $ is an escape for a literal "${".
+var secretRefPattern = regexp.MustCompile(`\$?\$\{([a-zA-Z][a-zA-Z0-9_-]*):([^}]*)\}`)
+
+// SecretProvider resolves the secret references of one scheme. A reference ${<scheme>:<ref>} in
+// any string value of the configuration is replaced with the result of Resolve(ref).
+type SecretProvider interface {
+	Scheme() string
+	Resolve(ref string) (string, error)
+}
+
+// IsSecretRef reports whether s contains a secret reference.
+func IsSecretRef(s string) bool {
+	for _, m := range secretRefPattern.FindAllString(s, -1) {
+		if !strings.HasPrefix(m, "$$") {
+			return true
+		}
+	}
+	return false
+}
+
+// FileSecretProvider resolves ${file:<path>} to the contents of the file, without a trailing
+// newline. This is how Docker and Kubernetes mount secrets.
+type FileSecretProvider struct {
+	Dir string // Directory relative paths are resolved against, the working directory if empty
+}
+
+func (p FileSecretProvider) Scheme() string { return "file" }
+
+func (p FileSecretProvider) Resolve(ref string) (string, error) {
+	path := ref
+	if !filepath.IsAbs(path) && p.Dir != "" {
+		path = filepath.Join(p.Dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvSecretProvider resolves ${env:<name>} to the value of an environment variable. Unlike an
+// ACACIA_* override, the reference keeps the file's structure and may be embedded in a value,
+// e.g. "postgres://app:${env:DB_PASSWORD}@db/app".
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) Scheme() string { return "env" }
+
+func (EnvSecretProvider) Resolve(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, ref)
+	}
+	return value, nil
+}
+
+// Vault is a local file of secrets encrypted with AES-256-GCM. It resolves ${vault:<name>}.
+// Names are authenticated with their values, so entries cannot be swapped in the file.
+type Vault struct {
+	mu      sync.Mutex
+	path    string
+	aead    cipher.AEAD
+	secrets map[string]string // Name -> base64 nonce and ciphertext
+}
+
+// vaultFile is the on-disk format of a Vault.
+type vaultFile struct {
+	Version int               `json:"version"`
+	Secrets map[string]string `json:"secrets"`
+}
+
+// GenerateVaultKey returns a new random vault key, base64-encoded.
+func GenerateVaultKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generate vault key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// OpenVault opens the vault at path with a base64-encoded 32-byte key. A missing file is an
+// empty vault, created by the first Set.
+func OpenVault(path, key string) (*Vault, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("vault key is not base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("vault key must be 32 bytes, got %d", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("vault key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("vault key: %w", err)
+	}
+
+	v := &Vault{path: path, aead: aead, secrets: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read vault: %w", err)
+	}
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse vault %s: %w", path, err)
+	}
+	if file.Version != 1 {
+		return nil, fmt.Errorf("vault %s: unsupported version %d", path, file.Version)
+	}
+	if file.Secrets != nil {
+		v.secrets = file.Secrets
+	}
+	return v, nil
+}
+
+func (v *Vault) Scheme() string { return "vault" }
+
+// Resolve decrypts the secret called name.
+func (v *Vault) Resolve(name string) (string, error) {
+	v.mu.Lock()
+	sealed, ok := v.secrets[name]
+	v.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not in vault %s", ErrSecretNotFound, name, v.path)
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < v.aead.NonceSize() {
+		return "", fmt.Errorf("vault secret %s is corrupt", name)
+	}
+	nonce, ciphertext := data[:v.aead.NonceSize()], data[v.aead.NonceSize():]
+	plain, err := v.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("decrypt vault secret %s: wrong key or corrupt vault", name)
+	}
+	return string(plain), nil
+}
+
+// Set encrypts value under name and saves the vault.
+func (v *Vault) Set(name, value string) error {
+	if name == "" || strings.ContainsAny(name, "{}") {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := v.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets[name] = base64.StdEncoding.EncodeToString(sealed)
+	return v.save()
+}
+
+// Delete removes the secret called name and saves the vault. It returns ErrSecretNotFound if there is none.
+func (v *Vault) Delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.secrets[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	delete(v.secrets, name)
+	return v.save()
+}
+
+// Names returns the names of the secrets in the vault, sorted.
+func (v *Vault) Names() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// save writes the vault atomically, readable by its owner only. The caller holds v.mu.
+func (v *Vault) save() error {
+	data, err := json.MarshalIndent(vaultFile{Version: 1, Secrets: v.secrets}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal vault: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), filepath.Base(v.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write vault: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write vault: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write vault: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("write vault: %w", err)
+	}
+	return nil
+}
+
+// secretResolver expands the secret references in configuration values and remembers the
+// values holding secrets, so that they can be redacted.
+type secretResolver struct {
+	providers map[string]SecretProvider
+	resolved  map[string][]string // Expanded value -> secrets in it, longest first
+}
+
+func newSecretResolver(providers []SecretProvider) *secretResolver {
+	r := &secretResolver{providers: make(map[string]SecretProvider), resolved: make(map[string][]string)}
+	r.register(FileSecretProvider{})
+	r.register(EnvSecretProvider{})
+	for _, p := range providers {
+		r.register(p) // May replace the built-in providers
+	}
+	return r
+}
+
+func (r *secretResolver) register(p SecretProvider) {
+	r.providers[p.Scheme()] = p
+}
+
+// expand replaces the secret references in s.
+func (r *secretResolver) expand(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var firstErr error
+	var secrets []string
+	out := secretRefPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.HasPrefix(m, "$$") {
+			return m[1:]
+		}
+		parts := secretRefPattern.FindStringSubmatch(m)
+		p, ok := r.providers[parts[1]]
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unknown secret provider %q", parts[1])
+			}
+			return m
+		}
+		value, err := p.Resolve(parts[2])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("resolve %s: %w", m, err)
+			}
+			return m
+		}
+		if value != "" {
+			secrets = append(secrets, value)
+		}
+		return value
+	})
+	if len(secrets) > 0 {
+		// Longest first, so that a secret containing another is not partially revealed
+		sort.SliceStable(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+		r.resolved[out] = secrets
+	}
+	return out, firstErr
+}
+
+// resolveAll returns a copy of value, a tree of settings as returned by viper, with the secret
+// references in its strings expanded. The copy keeps the secrets out of viper's own state.
+// Failures are collected into errs under their path.
+func (r *secretResolver) resolveAll(value interface{}, path string, errs *ValidationErrors) interface{} {
+	switch v := value.(type) {
+	case string:
+		expanded, err := r.expand(v)
+		if err != nil {
+			*errs = append(*errs, FieldError{Path: path, Message: err.Error()})
+		}
+		return expanded
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = r.resolveAll(item, joinPath(path, key), errs)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.resolveAll(item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, item := range v {
+			out[i] = r.resolveAll(item, fmt.Sprintf("%s[%d]", path, i), errs).(string)
+		}
+		return out
+	}
+	return value
+}
+
+// redactValue redacts the secrets in the strings of a tree decoded from JSON. Only values that
+// were expanded from a reference are touched, so a short secret such as a user name does not
+// hide unrelated settings that happen to contain it.
+func redactValue(value interface{}, secrets map[string][]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		for _, secret := range secrets[v] {
+			v = strings.ReplaceAll(v, secret, Redacted)
+		}
+		return v
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = redactValue(item, secrets)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item, secrets)
+		}
+	}
+	return value
+}
+
+// MarshalJSON encodes the configuration with its secrets redacted, so that logging it, for
+// instance with zap.Any, does not reveal them.
+func (c *Config) MarshalJSON() ([]byte, error) {
+	type plain Config // Without this method
+	data, err := json.Marshal((*plain)(c))
+	if err != nil || len(c.secrets) == 0 {
+		return data, err
+	}
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Keep large integers intact
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(tree, c.secrets))
+}
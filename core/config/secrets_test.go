@@ -0,0 +1,167 @@
+package config_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"acacia/core/config"
+)
+
+// staticProvider resolves ${static:name} from a map.
+type staticProvider map[string]string
+
+func (staticProvider) Scheme() string { return "static" }
+func (p staticProvider) Resolve(ref string) (string, error) {
+	if v, ok := p[ref]; ok {
+		return v, nil
+	}
+	return "", config.ErrSecretNotFound
+}
+
+func TestReadFile_ResolvesSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db"), []byte("s3cret-db\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := config.GenerateVaultKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vaultPath := filepath.Join(dir, "vault.json")
+	vault, err := config.OpenVault(vaultPath, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Set("api_token", "vault-token"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_VAULT_KEY", key)
+	t.Setenv("TEST_DB_USER", "dbuser")
+
+	path := filepath.Join(dir, "config.yaml")
+	yaml := `environment: development
+secrets:
+  vault_file: ` + vaultPath + `
+  vault_key: ${env:TEST_VAULT_KEY}
+modules:
+  db:
+    dsn: postgres://${env:TEST_DB_USER}:${file:` + filepath.Join(dir, "db") + `}@db/app
+    nested:
+      token: ${vault:api_token}
+    hosts: ["${static:host}"]
+    literal: $${env:NOT_A_SECRET}
+`
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.ReadFile(path, staticProvider{"host": "db.internal"})
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	db := cfg.Modules["db"]
+	if db["dsn"] != "postgres://dbuser:s3cret-db@db/app" {
+		t.Errorf("dsn = %v", db["dsn"])
+	}
+	if token := db["nested"].(map[string]interface{})["token"]; token != "vault-token" {
+		t.Errorf("vault token = %v", token)
+	}
+	if hosts := db["hosts"].([]interface{}); hosts[0] != "db.internal" {
+		t.Errorf("hosts = %v", hosts)
+	}
+	if db["literal"] != "${env:NOT_A_SECRET}" {
+		t.Errorf("escaped reference = %v", db["literal"])
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, secret := range []string{"s3cret-db", "vault-token", key} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("marshalled config reveals %q:\n%s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "postgres://"+config.Redacted+":"+config.Redacted+"@db/app") {
+		t.Errorf("marshalled config:\n%s", data)
+	}
+	if !strings.Contains(string(data), `"Environment":"development"`) {
+		t.Errorf("values without secrets should be kept:\n%s", data)
+	}
+
+	// Change sets do not reveal secrets either
+	for _, c := range config.Diff(nil, cfg) {
+		if text := c.String(); strings.Contains(text, "s3cret-db") || strings.Contains(text, "vault-token") {
+			t.Errorf("change reveals a secret: %s", text)
+		}
+	}
+	if db["dsn"] != "postgres://dbuser:s3cret-db@db/app" {
+		t.Errorf("diffing modified the snapshot: dsn = %v", db["dsn"])
+	}
+}
+
+func TestReadFile_UnresolvableSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `environment: development
+modules:
+  db:
+    password: ${env:TEST_UNSET_SECRET}
+    token: ${nope:x}
+`
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := config.ReadFile(path)
+	var errs config.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("ReadFile = %v, want two ValidationErrors", err)
+	}
+	if errs[0].Line != 4 || errs[0].Path != "modules.db.password" || !strings.Contains(errs[0].Message, "TEST_UNSET_SECRET is not set") {
+		t.Errorf("first error = %v", errs[0])
+	}
+	if errs[1].Line != 5 || !strings.Contains(errs[1].Message, `unknown secret provider "nope"`) {
+		t.Errorf("second error = %v", errs[1])
+	}
+}
+
+func TestVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	key, _ := config.GenerateVaultKey()
+	vault, err := config.OpenVault(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Set("db", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "hunter2") {
+		t.Fatalf("vault stores the secret in plain text:\n%s", data)
+	}
+
+	reopened, err := config.OpenVault(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := reopened.Resolve("db"); err != nil || v != "hunter2" {
+		t.Errorf("Resolve = %q, %v", v, err)
+	}
+	other, _ := config.GenerateVaultKey()
+	wrong, err := config.OpenVault(path, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Resolve("db"); err == nil {
+		t.Error("decrypting with the wrong key should fail")
+	}
+	if err := reopened.Delete("db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Resolve("db"); !errors.Is(err, config.ErrSecretNotFound) {
+		t.Errorf("Resolve after Delete = %v", err)
+	}
+}
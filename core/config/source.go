@@ -0,0 +1,131 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sourceFetchTimeout bounds how long loading waits for each remote source.
+const sourceFetchTimeout = 10 * time.Second
+
+// ErrBadSignature is returned when a remote document is unsigned or its signature does not
+// match the configured key.
+var ErrBadSignature = errors.New("bad configuration signature")
+
+// Document is a configuration layer fetched from a Source.
+type Document struct {
+	Data      []byte // YAML or JSON, like a config file
+	Signature string // Base64 Ed25519 signature of Data, see SignDocument; empty if the source has none
+	Revision  string // Identifies the revision, e.g. an ETag or a store revision, for logs
+}
+
+// Source is a configuration layer kept outside the local file system, such as an HTTP endpoint
+// or a key/value store. Implementations are in config/remote. They must be safe for concurrent use.
+type Source interface {
+	// Name identifies the source in origins and errors, e.g. its URL.
+	Name() string
+	// Fetch returns the current document.
+	Fetch(ctx context.Context) (Document, error)
+}
+
+// WatchableSource is a Source that can tell when its document changed. A Manager created with
+// LoadOptions.Watch reloads the configuration when it does.
+type WatchableSource interface {
+	Source
+	// Watch calls onChange whenever the document may have changed, until ctx is done. It
+	// returns once watching has started.
+	Watch(ctx context.Context, onChange func()) error
+}
+
+// GenerateSigningKey returns a new Ed25519 key pair, base64 encoded, for signing remote
+// configuration documents. The private key signs with SignDocument; the public key goes to
+// LoadOptions.SourceKey.
+func GenerateSigningKey() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("generate signing key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// SignDocument returns the base64 signature of data with the private key from GenerateSigningKey.
+func SignDocument(privateKey string, data []byte) (string, error) {
+	key, err := decodeKey(privateKey, ed25519.PrivateKeySize)
+	if err != nil {
+		return "", fmt.Errorf("invalid signing key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519.PrivateKey(key), data)), nil
+}
+
+// VerifyDocument checks that doc is signed with the private key matching publicKey.
+func VerifyDocument(publicKey string, doc Document) error {
+	key, err := decodeKey(publicKey, ed25519.PublicKeySize)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	if doc.Signature == "" {
+		return fmt.Errorf("%w: document is not signed", ErrBadSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(doc.Signature))
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), doc.Data, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// decodeKey decodes a base64 key of the given size.
+func decodeKey(key string, size int) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != size {
+		return nil, fmt.Errorf("want %d bytes, got %d", size, len(data))
+	}
+	return data, nil
+}
+
+// addSources fetches the documents of the remote sources and merges them, in order. If key is
+// set, each document must be signed with the matching private key.
+func (t *layeredTree) addSources(sources []Source, key string) error {
+	for _, src := range sources {
+		ctx, cancel := context.WithTimeout(context.Background(), sourceFetchTimeout)
+		doc, err := src.Fetch(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to fetch config from %s: %w", src.Name(), err)
+		}
+		if key != "" {
+			if err := VerifyDocument(key, doc); err != nil {
+				return fmt.Errorf("config from %s: %w", src.Name(), err)
+			}
+		}
+		if err := t.addDocument(src.Name(), doc.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchSources reloads through onChange whenever a watchable source changes. The returned
+// function stops watching.
+func watchSources(sources []Source, onChange func(name string)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, src := range sources {
+		watchable, ok := src.(WatchableSource)
+		if !ok {
+			continue
+		}
+		name := src.Name()
+		if err := watchable.Watch(ctx, func() { onChange(name) }); err != nil {
+			fmt.Printf("Warning: changes to %s will not be picked up: %v\n", name, err)
+		}
+	}
+	return cancel
+}
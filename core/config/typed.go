@@ -0,0 +1,465 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// Sections of the config file that hold component configurations.
+const (
+	SectionModules  = "modules"
+	SectionGateways = "gateways"
+)
+
+// TypedConfig is implemented by modules and gateways that declare a typed configuration.
+// ConfigSpec returns a pointer to a new zero value of the component's config struct. Its fields
+// are named by their mapstructure tags, take defaults from `default` tags and are checked
+// against `validate` tags:
+//
+//	type Config struct {
+//		Address string        `mapstructure:"address" validate:"required"`
+//		Workers int           `mapstructure:"workers" default:"4" validate:"min=1,max=64"`
+//		Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+//		Mode    string        `mapstructure:"mode" default:"fast" validate:"oneof=fast safe"`
+//	}
+//
+// The kernel passes Configure a pointer of the same type, already decoded and validated.
+type TypedConfig interface {
+	ConfigSpec() interface{}
+}
+
+// FieldError is a problem with one configuration value.
+type FieldError struct {
+	File    string // Config file the value is in, empty if unknown (e.g. a missing value)
+	Line    int    // Line in File, 0 if unknown
+	Path    string // Dotted path of the value, e.g. "modules.chat.workers"
+	Message string
+}
+
+func (e FieldError) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s: %s", e.File, e.Line, e.Path, e.Message)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s: %s", e.File, e.Path, e.Message)
+	default:
+		return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+}
+
+// ValidationErrors lists all problems found in a configuration, one per line.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	lines := make([]string, len(v))
+	for i, e := range v {
+		lines[i] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DecodeTyped decodes raw into a new value of spec's config type: defaults are applied first,
+// then raw is decoded over them and the result validated. Unknown keys are errors. All problems
+// are returned together as ValidationErrors with paths relative to raw.
+func DecodeTyped(spec TypedConfig, raw map[string]interface{}) (interface{}, error) {
+	target := spec.ConfigSpec()
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config spec must be a pointer to a struct, got %T", target)
+	}
+
+	var errs ValidationErrors
+	applyDefaults(v.Elem(), "", &errs)
+	if len(errs) > 0 {
+		return nil, errs // Broken default tags are a bug in the component, not in the file
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           target,
+		WeaklyTypedInput: true, // Like viper, so that environment variables can set numbers
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create decoder: %w", err)
+	}
+	if err := decoder.Decode(raw); err != nil {
+		collectDecodeErrors(err, &errs)
+	}
+	checkUnknownKeys(v.Elem().Type(), raw, "", &errs)
+	validateStruct(v.Elem(), "", &errs)
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return target, nil
+}
+
+// DecodeComponent decodes the typed config of the component called name from section
+// (SectionModules or SectionGateways), after upgrading it if spec implements MigratingConfig.
+// Errors carry their full path and, if the config was loaded from a file, their position in it.
+func (c *Config) DecodeComponent(section, name string, spec TypedConfig) (interface{}, error) {
+	raw, err := MigrateSection(section+"."+name, spec, c.componentSection(section, name))
+	if err != nil {
+		return nil, err
+	}
+	typed, err := DecodeTyped(spec, raw)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		return typed, err
+	}
+	return nil, c.locate(section+"."+name, errs)
+}
+
+// ValidateComponents decodes the typed configs of the given modules and gateways, keyed by
+// component name, and reports the problems of all of them at once.
+func (c *Config) ValidateComponents(modules, gateways map[string]TypedConfig) error {
+	var all ValidationErrors
+	check := func(section string, specs map[string]TypedConfig) error {
+		for name, spec := range specs {
+			_, err := c.DecodeComponent(section, name, spec)
+			var errs ValidationErrors
+			if errors.As(err, &errs) {
+				all = append(all, errs...)
+			} else if err != nil {
+				return fmt.Errorf("%s.%s: %w", section, name, err)
+			}
+		}
+		return nil
+	}
+	if err := check(SectionModules, modules); err != nil {
+		return err
+	}
+	if err := check(SectionGateways, gateways); err != nil {
+		return err
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Line != all[j].Line {
+			return all[i].Line < all[j].Line
+		}
+		return all[i].Path < all[j].Path
+	})
+	return all
+}
+
+// SourceFile returns the config file the configuration was loaded from, if any.
+func (c *Config) SourceFile() string {
+	return c.source
+}
+
+func (c *Config) componentSection(section, name string) map[string]interface{} {
+	switch section {
+	case SectionModules:
+		return c.Modules[name]
+	case SectionGateways:
+		return c.Gateways[name]
+	}
+	return nil
+}
+
+// locate prefixes the paths of errs with prefix and fills in their position in the layer that
+// set them. Values missing from every layer are reported at the closest enclosing key that is
+// present, or else in the base config file.
+func (c *Config) locate(prefix string, errs ValidationErrors) ValidationErrors {
+	located := make(ValidationErrors, len(errs))
+	for i, e := range errs {
+		e.Path = joinPath(prefix, e.Path)
+		if c.tree != nil {
+			e.File, e.Line = c.tree.position(e.Path)
+		}
+		if e.File == "" {
+			e.File = c.source
+		}
+		located[i] = e
+	}
+	return located
+}
+
+// splitPath turns "a.b[2].c" into ["a", "b", "2", "c"].
+func splitPath(path string) []string {
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	return strings.Split(path, ".")
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case strings.HasPrefix(path, "["):
+		return prefix + path
+	}
+	return prefix + "." + path
+}
+
+// fieldName returns the key a struct field is decoded from and whether it is squashed into its
+// parent, following mapstructure's rules.
+func fieldName(f reflect.StructField) (name string, squash bool) {
+	tag := f.Tag.Get("mapstructure")
+	name, opts, _ := strings.Cut(tag, ",")
+	if opts == "squash" || (f.Anonymous && name == "") {
+		return "", true
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, false
+}
+
+// structFields calls fn for every decoded field of the struct v, descending into squashed ones.
+func structFields(v reflect.Value, fn func(f reflect.StructField, fv reflect.Value, name string)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, squash := fieldName(f)
+		if name == "-" {
+			continue
+		}
+		if squash && f.Type.Kind() == reflect.Struct {
+			structFields(v.Field(i), fn)
+			continue
+		}
+		fn(f, v.Field(i), name)
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyDefaults sets the zero fields of v that have a default tag.
+func applyDefaults(v reflect.Value, prefix string, errs *ValidationErrors) {
+	structFields(v, func(f reflect.StructField, fv reflect.Value, name string) {
+		path := joinPath(prefix, name)
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			applyDefaults(fv, path, errs)
+			return
+		}
+		def, ok := f.Tag.Lookup("default")
+		if !ok || !fv.IsZero() {
+			return
+		}
+		if err := setFromString(fv, def); err != nil {
+			*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("invalid default %q: %v", def, err)})
+		}
+	})
+}
+
+// setFromString parses s into v according to v's type.
+func setFromString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setFromString(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("defaults are not supported for %s fields", v.Type())
+	}
+	return nil
+}
+
+// collectDecodeErrors flattens the errors mapstructure joins together.
+func collectDecodeErrors(err error, errs *ValidationErrors) {
+	var decodeErr *mapstructure.DecodeError
+	if errors.As(err, &decodeErr) && err == error(decodeErr) {
+		inner := decodeErr.Unwrap()
+		if _, joined := inner.(interface{ Unwrap() []error }); joined {
+			collectDecodeErrors(inner, errs)
+			return
+		}
+		*errs = append(*errs, FieldError{Path: decodeErr.Name(), Message: inner.Error()})
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			collectDecodeErrors(e, errs)
+		}
+		return
+	}
+	if inner := errors.Unwrap(err); inner != nil {
+		collectDecodeErrors(inner, errs)
+		return
+	}
+	*errs = append(*errs, FieldError{Message: err.Error()})
+}
+
+// checkUnknownKeys reports keys of raw that no field of the struct type t decodes.
+func checkUnknownKeys(t reflect.Type, raw map[string]interface{}, prefix string, errs *ValidationErrors) {
+	fields := make(map[string]reflect.Type)
+	remain := false
+	structFields(reflect.New(t).Elem(), func(f reflect.StructField, fv reflect.Value, name string) {
+		if strings.HasSuffix(f.Tag.Get("mapstructure"), ",remain") {
+			remain = true
+		}
+		fields[strings.ToLower(name)] = f.Type
+	})
+	if remain {
+		return
+	}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ft, ok := fields[strings.ToLower(key)]
+		if !ok {
+			*errs = append(*errs, FieldError{Path: joinPath(prefix, key), Message: "unknown key"})
+			continue
+		}
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if nested, isMap := raw[key].(map[string]interface{}); isMap && ft.Kind() == reflect.Struct {
+			checkUnknownKeys(ft, nested, joinPath(prefix, key), errs)
+		}
+	}
+}
+
+// validateStruct checks the fields of v against their validate tags.
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) {
+	structFields(v, func(f reflect.StructField, fv reflect.Value, name string) {
+		path := joinPath(prefix, name)
+		if rules := f.Tag.Get("validate"); rules != "" {
+			for _, rule := range strings.Split(rules, ",") {
+				if msg := checkRule(fv, strings.TrimSpace(rule)); msg != "" {
+					*errs = append(*errs, FieldError{Path: path, Message: msg})
+				}
+			}
+		}
+		switch {
+		case fv.Kind() == reflect.Struct && fv.Type() != durationType:
+			validateStruct(fv, path, errs)
+		case fv.Kind() == reflect.Ptr && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct:
+			validateStruct(fv.Elem(), path, errs)
+		case fv.Kind() == reflect.Map && fv.Type().Key().Kind() == reflect.String &&
+			fv.Type().Elem().Kind() == reflect.Struct:
+			keys := fv.MapKeys()
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+			for _, key := range keys {
+				validateStruct(fv.MapIndex(key), joinPath(path, key.String()), errs)
+			}
+		}
+	})
+}
+
+// checkRule returns why v breaks rule, or "" if it does not. Bounds apply to the value of
+// numbers and durations and to the length of strings, slices and maps.
+func checkRule(v reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	switch name {
+	case "":
+		return ""
+	case "required":
+		if v.IsZero() {
+			return "is required"
+		}
+	case "min", "max":
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return "" // Absent optional values are only checked by required
+		}
+		return checkBound(reflect.Indirect(v), name, arg)
+	case "oneof":
+		options := strings.Fields(arg)
+		value := fmt.Sprint(reflect.Indirect(v).Interface())
+		for _, option := range options {
+			if value == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(options, ", "), value)
+	default:
+		return fmt.Sprintf("unknown validation rule %q", rule)
+	}
+	return ""
+}
+
+func checkBound(v reflect.Value, name, arg string) string {
+	var actual, bound float64
+	var err error
+	what := "be"
+	switch {
+	case v.Type() == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(arg)
+		actual, bound = float64(v.Int()), float64(d)
+	case v.Kind() == reflect.String || v.Kind() == reflect.Slice || v.Kind() == reflect.Map:
+		bound, err = strconv.ParseFloat(arg, 64)
+		actual, what = float64(v.Len()), "have length"
+	case v.CanInt():
+		bound, err = strconv.ParseFloat(arg, 64)
+		actual = float64(v.Int())
+	case v.CanUint():
+		bound, err = strconv.ParseFloat(arg, 64)
+		actual = float64(v.Uint())
+	case v.CanFloat():
+		bound, err = strconv.ParseFloat(arg, 64)
+		actual = v.Float()
+	default:
+		return fmt.Sprintf("%s is not supported for %s fields", name, v.Type())
+	}
+	if err != nil {
+		return fmt.Sprintf("invalid validation rule %s=%s: %v", name, arg, err)
+	}
+	if name == "min" && actual < bound {
+		return fmt.Sprintf("must %s at least %s", what, arg)
+	}
+	if name == "max" && actual > bound {
+		return fmt.Sprintf("must %s at most %s", what, arg)
+	}
+	return ""
+}
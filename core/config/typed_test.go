@@ -0,0 +1,215 @@
+package config_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"acacia/core/config"
+)
+
+type listenerConfig struct {
+	Address string `mapstructure:"address" validate:"required"`
+	Backlog int    `mapstructure:"backlog" default:"128" validate:"min=1"`
+}
+
+type chatConfig struct {
+	Workers  int            `mapstructure:"workers" default:"4" validate:"min=1,max=64"`
+	Timeout  time.Duration  `mapstructure:"timeout" default:"30s" validate:"min=1s"`
+	Mode     string         `mapstructure:"mode" default:"fast" validate:"oneof=fast safe"`
+	Rooms    []string       `mapstructure:"rooms" default:"lobby,help"`
+	Listener listenerConfig `mapstructure:"listener"`
+}
+
+type chatSpec struct{}
+
+func (chatSpec) ConfigSpec() interface{} { return &chatConfig{} }
+
+func TestDecodeTyped_Defaults(t *testing.T) {
+	typed, err := config.DecodeTyped(chatSpec{}, map[string]interface{}{
+		"workers":  "8", // Environment variables arrive as strings
+		"listener": map[string]interface{}{"address": ":9000"},
+	})
+	if err != nil {
+		t.Fatalf("DecodeTyped: %v", err)
+	}
+	cfg := typed.(*chatConfig)
+	if cfg.Workers != 8 || cfg.Timeout != 30*time.Second || cfg.Mode != "fast" || cfg.Listener.Backlog != 128 {
+		t.Errorf("decoded = %+v", cfg)
+	}
+	if len(cfg.Rooms) != 2 || cfg.Rooms[1] != "help" {
+		t.Errorf("rooms = %v", cfg.Rooms)
+	}
+}
+
+func TestConfig_ValidateComponents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `environment: development
+modules:
+  chat:
+    workers: 0
+    timeout: soon
+    mode: turbo
+    colour: blue
+    listener:
+      backlog: -1
+`
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	err = cfg.ValidateComponents(map[string]config.TypedConfig{"chat": chatSpec{}}, nil)
+	var errs config.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ValidateComponents = %v, want ValidationErrors", err)
+	}
+	want := map[string]int{ // Path -> line
+		"modules.chat.workers":          4,
+		"modules.chat.timeout":          5,
+		"modules.chat.mode":             6,
+		"modules.chat.colour":           7,
+		"modules.chat.listener.backlog": 9,
+		"modules.chat.listener.address": 8, // Missing, reported at the enclosing key
+	}
+	got := make(map[string]int)
+	for _, e := range errs {
+		if e.File != path {
+			t.Errorf("%s: file = %q", e.Path, e.File)
+		}
+		got[e.Path] = e.Line
+	}
+	for p, line := range want {
+		if got[p] != line {
+			t.Errorf("%s reported at line %d, want %d (errors:\n%v)", p, got[p], line, err)
+		}
+	}
+	if !strings.Contains(err.Error(), path+":6: modules.chat.mode: must be one of fast, safe") {
+		t.Errorf("error text:\n%v", err)
+	}
+
+	// Components without a section still get their defaults validated
+	if err := cfg.ValidateComponents(nil, map[string]config.TypedConfig{"chat": chatSpec{}}); err == nil ||
+		!strings.Contains(err.Error(), "gateways.chat.listener.address: is required") {
+		t.Errorf("missing section: %v", err)
+	}
+}
+
+func TestGenerateSchema(t *testing.T) {
+	s := config.GenerateSchema(map[string]config.TypedConfig{"chat": chatSpec{}}, nil)
+	if s.Dialect != config.SchemaDialect || s.Properties["environment"].Enum[0] != "development" {
+		t.Fatalf("root schema = %+v", s)
+	}
+	chat := s.Properties["modules"].Properties["chat"]
+	if chat.AdditionalProperties != false {
+		t.Errorf("typed configs should not allow unknown keys")
+	}
+	workers := chat.Properties["workers"]
+	if workers.Type != "integer" || workers.Default != 4 || *workers.Minimum != 1 || *workers.Maximum != 64 {
+		t.Errorf("workers = %+v", workers)
+	}
+	if timeout := chat.Properties["timeout"]; timeout.Type != "string" || timeout.Default != "30s" || timeout.Pattern == "" {
+		t.Errorf("timeout = %+v", timeout)
+	}
+	listener := chat.Properties["listener"]
+	if len(listener.Required) != 1 || listener.Required[0] != "address" {
+		t.Errorf("listener required = %v", listener.Required) // backlog has a default
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+}
+
+func TestConfig_ValidateSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `environment: development
+server_port: 8080
+auth:
+  rolse: []
+timeouts:
+  module_operation_seconds: "10"
+modules:
+  chat:
+    workers: 100
+    timeout: soon
+    listener: {}
+  other:
+    anything: goes
+`
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	err = cfg.ValidateSchema(config.GenerateSchema(map[string]config.TypedConfig{"chat": chatSpec{}}, nil))
+	var errs config.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ValidateSchema = %v, want ValidationErrors", err)
+	}
+	want := []string{
+		path + ":4: auth.rolse: unknown key",
+		path + ":6: timeouts.module_operation_seconds: must be an integer",
+		path + ":9: modules.chat.workers: must be at most 64",
+		path + `:10: modules.chat.timeout: must be a duration such as "30s", got "soon"`,
+		path + ":11: modules.chat.listener.address: is required",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors:\n%v\nwant:\n%s", err, strings.Join(want, "\n"))
+	}
+	for i, e := range errs {
+		if e.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, e.Error(), want[i])
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := &config.Config{Environment: "prod", Timeouts: config.TimeoutsConfig{ConfigChange: 5, ModuleOperation: 10, GatewayOperation: 0}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "environment: must be one of development, staging, production") ||
+		!strings.Contains(err.Error(), "timeouts.gateway_operation_seconds: must be at least 1") {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+func TestTimeoutsConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "timeouts:\n  module_operation_seconds: 20\n  modules:\n    chat:\n      start: 1m\n      stop: 30s\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(config.LoadOptions{File: path})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, tc := range []struct {
+		section, name string
+		phase         config.Phase
+		want          time.Duration
+	}{
+		{config.SectionModules, "chat", config.PhaseStart, time.Minute},
+		{config.SectionModules, "chat", config.PhaseStop, 30 * time.Second},
+		{config.SectionModules, "chat", config.PhaseOnReady, 20 * time.Second},
+		{config.SectionModules, "chat", config.PhaseOnConfigChanged, 5 * time.Second},
+		{config.SectionModules, "audit", config.PhaseStart, 20 * time.Second},
+		{config.SectionGateways, "chat", config.PhaseStart, 10 * time.Second},
+	} {
+		if got := cfg.Timeouts.Timeout(tc.section, tc.name, tc.phase); got != tc.want {
+			t.Errorf("Timeout(%s, %s, %s) = %s, want %s", tc.section, tc.name, tc.phase, got, tc.want)
+		}
+	}
+
+	cfg.Timeouts.Gateways = map[string]config.PhaseTimeouts{"web": {Stop: -time.Second}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "timeouts.gateways.web.stop: must be at least 0s") {
+		t.Errorf("Validate = %v", err)
+	}
+}
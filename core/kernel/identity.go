@@ -0,0 +1,162 @@
+package kernel
+
+import (
+	"acacia/core/auth"   // Imports the auth package for principals and access checks.
+	"acacia/core/events" // Imports the events package for the scoped event bus.
+	"acacia/core/logger" // Imports the custom logger for rejected permissions and events.
+	"acacia/core/plugin" // Imports the plugin package for PluginMetadata.
+
+	"context" // Provides context for lifecycle calls.
+	"fmt"     // Implements formatted I/O.
+
+	"go.uber.org/zap" // A fast, structured, leveled logging library.
+)
+
+// Principal types of the identities the kernel issues to components.
+const (
+	PrincipalTypeModule  = "module"
+	PrincipalTypeGateway = "gateway"
+)
+
+// MetadataProvider is implemented by modules and gateways that declare plugin metadata. The
+// declared Permissions are granted to the principal the kernel issues to the component.
+type MetadataProvider interface {
+	PluginMetadata() plugin.PluginMetadata
+}
+
+// PrincipalReceiver is implemented by modules and gateways that want their kernel-issued
+// principal, e.g. to attach it to contexts of work they start themselves.
+type PrincipalReceiver interface {
+	SetPrincipal(p auth.Principal)
+}
+
+// undeclaredPermissions are granted to components that declare no metadata, so that they keep
+// the unrestricted event bus access they had before components had identities.
+var undeclaredPermissions = []auth.Permission{"core.events.*"}
+
+// newComponentPrincipal mints the identity of a module or gateway. Its roles are the component
+// type and "<type>.<name>", so operators can grant extra permissions to all modules or to one
+// in auth.roles. Its direct permissions are the ones declared in the component's plugin
+// metadata, plus access to its own services (modules) or its own registry entry (gateways).
+func newComponentPrincipal(ctx context.Context, kind, name, version string, component interface{}) auth.Principal {
+	own := auth.Permission("gateway." + name + ".*")
+	if kind == PrincipalTypeModule {
+		own = auth.Permission("service." + name + ".*")
+	}
+	perms := []auth.Permission{own}
+
+	attributes := map[string]interface{}{"component": name}
+	if version != "" {
+		attributes["version"] = version
+	}
+	if mp, ok := component.(MetadataProvider); ok {
+		md := mp.PluginMetadata()
+		if md.Type != "" {
+			attributes["plugin_type"] = md.Type
+		}
+		for _, declared := range md.Permissions {
+			if err := auth.ValidatePermission(auth.Permission(declared)); err != nil {
+				logger.Warn(ctx, "Ignoring invalid permission declared by component",
+					zap.String("type", kind), zap.String("component", name), zap.String("permission", declared), zap.Error(err))
+				continue
+			}
+			perms = append(perms, auth.Permission(declared))
+		}
+	} else {
+		perms = append(perms, undeclaredPermissions...)
+	}
+
+	roles := []string{kind, kind + "." + name}
+	return auth.NewPrincipalWithAttributes(name, kind, roles, attributes).WithPermissions(perms...)
+}
+
+// principalKey identifies a component in the kernel's principal map.
+func principalKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+// issuePrincipal mints and stores the principal for a component, hands it to the component if
+// it implements PrincipalReceiver, and returns it.
+func (k *kernel) issuePrincipal(ctx context.Context, kind, name, version string, component interface{}) auth.Principal {
+	p := newComponentPrincipal(ctx, kind, name, version, component)
+	k.principalsMu.Lock()
+	k.principals[principalKey(kind, name)] = p
+	k.principalsMu.Unlock()
+	if receiver, ok := component.(PrincipalReceiver); ok {
+		receiver.SetPrincipal(p)
+	}
+	return p
+}
+
+// revokePrincipal forgets the principal of a removed component.
+func (k *kernel) revokePrincipal(kind, name string) {
+	k.principalsMu.Lock()
+	delete(k.principals, principalKey(kind, name))
+	k.principalsMu.Unlock()
+}
+
+// attachModule issues the principal of module m and hands m an event bus scoped to it and the
+// kernel's registry.
+func (k *kernel) attachModule(ctx context.Context, m Module) {
+	p := k.issuePrincipal(ctx, PrincipalTypeModule, m.Name(), m.Version(), m)
+	m.SetEventBus(&componentBus{bus: k.eventBus, ac: k.accessController, principal: p})
+	m.SetRegistry(k.registry)
+}
+
+// attachGateway issues the principal of gateway g and hands g an event bus scoped to it.
+func (k *kernel) attachGateway(ctx context.Context, g Gateway) {
+	p := k.issuePrincipal(ctx, PrincipalTypeGateway, g.Name(), "", g)
+	g.SetEventBus(&componentBus{bus: k.eventBus, ac: k.accessController, principal: p})
+}
+
+// componentContext returns ctx acting as the component: the component's principal replaces
+// whatever principal ctx carried, e.g. the one of the caller that added the module.
+func (k *kernel) componentContext(ctx context.Context, kind, name string) context.Context {
+	k.principalsMu.RLock()
+	p, ok := k.principals[principalKey(kind, name)]
+	k.principalsMu.RUnlock()
+	if !ok {
+		return ctx
+	}
+	return auth.ContextWithPrincipal(ctx, p)
+}
+
+// moduleContext returns ctx acting as module m.
+func (k *kernel) moduleContext(ctx context.Context, m Module) context.Context {
+	return k.componentContext(ctx, PrincipalTypeModule, m.Name())
+}
+
+// gatewayContext returns ctx acting as gateway g.
+func (k *kernel) gatewayContext(ctx context.Context, g Gateway) context.Context {
+	return k.componentContext(ctx, PrincipalTypeGateway, g.Name())
+}
+
+// componentBus is the view of the kernel's event bus handed to a component. Publishing and
+// subscribing are authorized against the component's principal.
+type componentBus struct {
+	bus       events.Bus
+	ac        auth.AccessController
+	principal auth.Principal
+}
+
+// Subscribe subscribes to topic if the component may subscribe to it.
+func (b *componentBus) Subscribe(topic string) (<-chan events.TypedEvent, func(), error) {
+	if !b.ac.CanSubscribeEvent(context.Background(), b.principal, topic) {
+		return nil, func() {}, fmt.Errorf("access denied: %s %s cannot subscribe to %q", b.principal.Type(), b.principal.ID(), topic)
+	}
+	return b.bus.Subscribe(topic)
+}
+
+// Publish publishes payload if the component may publish to topic; otherwise the event is
+// dropped and a warning logged, as Publish cannot report errors.
+func (b *componentBus) Publish(ctx context.Context, topic string, payload events.TypedEvent) {
+	if !b.ac.CanPublishEvent(ctx, b.principal, topic) {
+		logger.Warn(ctx, "Event dropped, component may not publish it",
+			zap.String("type", b.principal.Type()), zap.String("component", b.principal.ID()), zap.String("topic", topic))
+		return
+	}
+	b.bus.Publish(ctx, topic, payload)
+}
+
+// Close does nothing: the bus is shared and owned by the kernel.
+func (b *componentBus) Close() {}
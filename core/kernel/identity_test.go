@@ -0,0 +1,115 @@
+package kernel_test
+
+import (
+	"acacia/core/auth"
+	"acacia/core/config"
+	"acacia/core/kernel"
+	"acacia/core/plugin"
+	"context"
+	"testing"
+	"time"
+)
+
+// identityModule is a recModule that declares plugin metadata and records its identity.
+type identityModule struct {
+	*recModule
+	permissions  []string
+	principal    auth.Principal
+	startedAs    auth.Principal
+	onReadyAsked auth.Principal
+}
+
+func (m *identityModule) PluginMetadata() plugin.PluginMetadata {
+	return plugin.PluginMetadata{Name: m.name, Type: "module", Permissions: m.permissions}
+}
+func (m *identityModule) SetPrincipal(p auth.Principal) { m.principal = p }
+func (m *identityModule) Start(ctx context.Context) error {
+	m.startedAs = auth.PrincipalFromContext(ctx)
+	return m.recModule.Start(ctx)
+}
+func (m *identityModule) OnReady(ctx context.Context) error {
+	m.onReadyAsked = auth.PrincipalFromContext(ctx)
+	return m.recModule.OnReady(ctx)
+}
+
+// identityGateway is a recGateway that records the principal it was started as.
+type identityGateway struct {
+	*recGateway
+	startedAs auth.Principal
+}
+
+func (g *identityGateway) Start(ctx context.Context) error {
+	g.startedAs = auth.PrincipalFromContext(ctx)
+	return g.recGateway.Start(ctx)
+}
+
+func TestKernel_ComponentPrincipals(t *testing.T) {
+	rec := &recorder{}
+	// Enforce permissions; nil would allow everything
+	krn := kernel.New(config.NewStaticManager(&config.Config{}), auth.NewDefaultAccessController(auth.NewConfigRBACProvider(nil)))
+	ctx := auth.ContextWithPrincipal(context.Background(),
+		auth.NewDefaultPrincipal("test-loader", "system", nil).WithPermissions("kernel.module.*"))
+
+	metered := &identityModule{
+		recModule:   &recModule{name: "metered", rec: rec, eventReceived: make(chan struct{})},
+		permissions: []string{"core.events.subscribe.test.event", "core..events"},
+	}
+	open := &recModule{name: "open", rec: rec, eventReceived: make(chan struct{})}
+	gw := &identityGateway{recGateway: &recGateway{name: "edge", rec: rec}}
+	if err := krn.AddModule(ctx, metered); err != nil {
+		t.Fatalf("add metered: %v", err)
+	}
+	if err := krn.AddModule(ctx, open); err != nil {
+		t.Fatalf("add open: %v", err)
+	}
+	if err := krn.AddGateway(gw); err != nil {
+		t.Fatalf("add gateway: %v", err)
+	}
+	if err := krn.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer krn.Stop(context.Background())
+
+	p := metered.principal
+	if p == nil || p.ID() != "metered" || p.Type() != kernel.PrincipalTypeModule {
+		t.Fatalf("issued principal = %v", p)
+	}
+	if roles := p.Roles(); len(roles) != 2 || roles[0] != "module" || roles[1] != "module.metered" {
+		t.Errorf("roles = %v", roles)
+	}
+	perms := p.(auth.PermissionHolder).Permissions()
+	if len(perms) != 2 || perms[0] != "service.metered.*" || perms[1] != "core.events.subscribe.test.event" {
+		t.Errorf("permissions = %v, want own services and the valid declared permission", perms)
+	}
+	if metered.startedAs == nil || metered.startedAs.ID() != "metered" {
+		t.Errorf("Start ran as %v, want the module's principal", metered.startedAs)
+	}
+	if metered.onReadyAsked == nil || metered.onReadyAsked.ID() != "metered" {
+		t.Errorf("OnReady ran as %v, want the module's principal", metered.onReadyAsked)
+	}
+	if gw.startedAs == nil || gw.startedAs.ID() != "edge" || gw.startedAs.Type() != kernel.PrincipalTypeGateway {
+		t.Errorf("gateway started as %v", gw.startedAs)
+	}
+
+	// The metered module did not declare a publish permission, so its event is dropped...
+	metered.eventBus.Publish(context.Background(), "test.event", &TestEvent{Data: "dropped"})
+	select {
+	case <-open.eventReceived:
+		t.Fatal("event published without permission was delivered")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// ...and it may only subscribe to what it declared.
+	if _, _, err := metered.eventBus.Subscribe("other.event"); err == nil {
+		t.Error("subscribing to an undeclared topic should fail")
+	}
+
+	// A module without metadata keeps full event access, and the metered module receives its events.
+	open.eventBus.Publish(context.Background(), "test.event", &TestEvent{Data: "delivered"})
+	for _, ch := range []chan struct{}{metered.eventReceived, open.eventReceived} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
@@ -66,8 +66,9 @@ type Module interface {
 	RegisterServices(reg registry.Registry) error
 	// Stop gracefully shuts down the module, honoring the provided context for cancellation.
 	Stop(ctx context.Context) error
-	// OnConfigChanged is called when the application's configuration is reloaded.
-	OnConfigChanged(ctx context.Context, newCfg interface{}) error
+	// OnConfigChanged is called when the module's section of the configuration changed, with
+	// the new section and the changes within it. It is not called for unrelated changes.
+	OnConfigChanged(ctx context.Context, change config.ComponentChange) error
 	// ShutdownTimeout returns the duration to wait for the module to stop gracefully.
 	ShutdownTimeout() time.Duration
 	// UnregisterServices is called when the module is stopped or removed, allowing it to unregister its services from the kernel's registry.
@@ -143,6 +144,11 @@ type Kernel interface {
 	// Health returns the aggregated health status of all registered components.
 	Health(ctx context.Context) map[string]HealthStatus
 	GetRegistry() registry.Registry
+	// DescribeServices lists the registered service providers the principal in ctx may access,
+	// with their owning module, Go type, version and registration time.
+	DescribeServices(ctx context.Context) ([]registry.ServiceInfo, error)
+	// DescribeGateways lists the registered gateways the principal in ctx may access.
+	DescribeGateways(ctx context.Context) ([]registry.GatewayInfo, error)
 }
 
 // HealthStatus represents the health of a component.
@@ -169,15 +175,12 @@ type DevOptions struct {
 
 // Predefined errors for common kernel operations.
 var (
-	errDuplicate      = errors.New("duplicate name")         // Returned when attempting to add a module/gateway with a name that already exists.
-	errNotFound       = errors.New("not found")              // Returned when a module/gateway with the specified name is not found.
-	errAlreadyRunning = errors.New("kernel already running") // Returned when attempting to start a kernel that is already running.
-	errNotRunning     = errors.New("kernel not running")     // Returned when attempting to stop a kernel that is not running.
-	errVersion        = errors.New("version conflict")       // Returned when a module's version is incompatible.
-)
-
-const (
-	moduleOperationTimeout = 30 * time.Second // Default timeout for module operations
+	errDuplicate      = errors.New("duplicate name")               // Returned when attempting to add a module/gateway with a name that already exists.
+	errNotFound       = errors.New("not found")                    // Returned when a module/gateway with the specified name is not found.
+	errAlreadyRunning = errors.New("kernel already running")       // Returned when attempting to start a kernel that is already running.
+	errNotRunning     = errors.New("kernel not running")           // Returned when attempting to stop a kernel that is not running.
+	errVersion        = errors.New("version conflict")             // Returned when a module's version is incompatible.
+	errBusy           = errors.New("lifecycle call still running") // Returned while a component's timed-out lifecycle call has not returned.
 )
 
 // Kernel event types
@@ -188,6 +191,10 @@ const (
 	GatewayAddedEventType   = "gateway.added"
 	GatewayStartedEventType = "gateway.started"
 	GatewayStoppedEventType = "gateway.stopped"
+
+	ServiceRegisteredEventType   = "service.registered"
+	ServiceUnregisteredEventType = "service.unregistered"
+	ServiceReplacedEventType     = "service.replaced"
 )
 
 // ModuleEvent is the base struct for module-related events.
@@ -246,54 +253,178 @@ type GatewayStoppedEvent struct {
 
 func (e GatewayStoppedEvent) EventType() string { return GatewayStoppedEventType }
 
+// ServiceEvent is the base struct for service registry events.
+type ServiceEvent struct {
+	ServiceName string
+	ModuleName  string
+	Version     string
+}
+
+func (e ServiceEvent) EventType() string { return "" } // Base implementation, overridden by specific events
+
+// ServiceRegisteredEvent is published when a service is registered with the kernel's registry.
+type ServiceRegisteredEvent struct {
+	ServiceEvent
+}
+
+func (e ServiceRegisteredEvent) EventType() string { return ServiceRegisteredEventType }
+
+// ServiceUnregisteredEvent is published when a service is removed from the kernel's registry.
+type ServiceUnregisteredEvent struct {
+	ServiceEvent
+}
+
+func (e ServiceUnregisteredEvent) EventType() string { return ServiceUnregisteredEventType }
+
+// ServiceReplacedEvent is published when a service registration is replaced in place.
+type ServiceReplacedEvent struct {
+	ServiceEvent
+}
+
+func (e ServiceReplacedEvent) EventType() string { return ServiceReplacedEventType }
+
 // New returns a new Kernel implementation.
-// It initializes the internal maps for modules and gateways and sets the application configuration.
-func New(cfg *config.Config, ac auth.AccessController) Kernel {
+// It initializes the internal maps for modules and gateways and follows the configuration owned by configs.
+func New(configs *config.Manager, ac auth.AccessController) Kernel {
 	if ac == nil {
 		// Provide a default "allow all" controller for development/unauthenticated scenarios.
 		// This is useful for setups where auth is not a concern.
 		ac = auth.NewDefaultAccessController(nil)
 	}
+	return NewWithRegistry(configs, ac, registry.NewDefaultRegistry(ac)) // Initialize the service registry with the access controller
+}
+
+// NewWithRegistry creates a kernel that uses reg as its service registry, e.g. a
+// distributed registry from registry/remote. Service change events and health-aware
+// resolution are wired up when reg supports them, as the DefaultRegistry does.
+func NewWithRegistry(configs *config.Manager, ac auth.AccessController, reg registry.Registry) Kernel {
+	if ac == nil {
+		ac = auth.NewDefaultAccessController(nil)
+	}
 	k := &kernel{
-		config:           cfg,
+		configs:          configs,
 		modules:          make(map[string]Module),
 		gateways:         make(map[string]Gateway),
 		moduleStates:     make(map[string]bool),
 		accessController: ac,
-		registry:         registry.NewDefaultRegistry(ac), // Initialize the service registry with the access controller
-		eventBus:         events.New(),                    // Initialize the event bus
+		registry:         reg,
+		eventBus:         events.New(), // Initialize the event bus
+		principals:       make(map[string]auth.Principal),
+		abandoned:        make(map[string]*phaseCall),
+	}
+	// Republish registry changes as kernel events so consumers can rebind to replaced services
+	if notifier, ok := reg.(interface {
+		OnChange(func(registry.ServiceChange))
+	}); ok {
+		notifier.OnChange(k.publishServiceChange)
+	}
+	// Health-aware service resolution consults the providers' HealthReporter implementations
+	if checker, ok := reg.(interface {
+		SetHealthCheck(registry.HealthCheckFunc)
+	}); ok {
+		checker.SetHealthCheck(k.providerHealthy)
 	}
 	// Watch for config changes and notify modules
-	cfg.AddConfigChangeHook(func(newCfg *config.Config) {
-		configChangeTimeout := time.Duration(newCfg.Timeouts.ConfigChange) * time.Second
-		ctx, cancel := context.WithTimeout(context.Background(), configChangeTimeout)
-		defer cancel() // Ensure the context is cancelled to release resources.
+	k.unsubscribeConfig = k.followConfig()
+	return k
+}
 
-		k.mu.RLock()
-		defer k.mu.RUnlock()
-		for _, m := range k.modules {
-			if err := m.OnConfigChanged(ctx, newCfg); err != nil {
-				logger.Error(ctx, "Module failed to handle config change", zap.String("module", m.Name()), zap.Error(err))
-			}
+// followConfig subscribes the kernel to its config manager, to validate the typed sections of
+// its components in new snapshots and pass the changed ones on. The returned function stops it.
+func (k *kernel) followConfig() (stop func()) {
+	removeValidator := k.configs.AddValidator(k.validateConfig)
+	unsubscribe := k.configs.Subscribe(k.onConfigChanged)
+	return func() {
+		unsubscribe()
+		removeValidator()
+	}
+}
+
+// validateConfig rejects a new configuration snapshot if a module or gateway with a typed config
+// would fail to decode its section.
+func (k *kernel) validateConfig(newCfg *config.Config) error {
+	modules, gateways := make(map[string]config.TypedConfig), make(map[string]config.TypedConfig)
+	k.mu.RLock()
+	for name, m := range k.modules {
+		if spec, typed := m.(config.TypedConfig); typed {
+			modules[name] = spec
 		}
-		for _, g := range k.gateways {
-			// Pass the specific gateway config to the gateway's Configure method
-			if gatewayCfg, ok := newCfg.Gateways[g.Name()]; ok {
-				if err := g.Configure(gatewayCfg); err != nil {
-					logger.Error(ctx, "Gateway failed to re-configure on config change", zap.String("gateway", g.Name()), zap.Error(err))
-				}
-			} else {
-				logger.Warn(ctx, "No configuration found for gateway during config change", zap.String("gateway", g.Name()))
+	}
+	for name, g := range k.gateways {
+		if spec, typed := g.(config.TypedConfig); typed {
+			gateways[name] = spec
+		}
+	}
+	k.mu.RUnlock()
+	return newCfg.ValidateComponents(modules, gateways)
+}
+
+// onConfigChanged passes the sections of a new configuration snapshot that changed to their
+// modules and gateways, and reports which of them accepted it. Components whose section did not
+// change are left alone.
+func (k *kernel) onConfigChanged(newCfg *config.Config, changes config.ChangeSet) []config.ComponentResult {
+	ctx := context.Background() // Each call is bounded by its component's on_config_changed timeout
+
+	var results []config.ComponentResult
+	report := func(section, name string, err error) {
+		result := config.ComponentResult{Component: section + "." + name}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, m := range k.modules {
+		moduleChanges := changes.Component(config.SectionModules, m.Name())
+		if len(moduleChanges) == 0 {
+			continue // Only modules whose section changed are notified
+		}
+		// Modules with a typed config get their decoded section, others the raw one
+		moduleCfg, _, err := componentConfig(newCfg, config.SectionModules, m.Name(), m)
+		if err != nil {
+			logger.Error(ctx, "Invalid module configuration, keeping the previous one", zap.String("module", m.Name()), zap.Error(err))
+			report(config.SectionModules, m.Name(), err)
+			continue
+		}
+		change := config.ComponentChange{Section: config.SectionModules, Name: m.Name(), Config: moduleCfg, Changes: moduleChanges}
+		err = k.runPhase(k.moduleContext(ctx, m), config.SectionModules, m, config.PhaseOnConfigChanged, func(ctx context.Context) error {
+			return m.OnConfigChanged(ctx, change)
+		})
+		if err != nil {
+			logger.Error(ctx, "Module failed to handle config change", zap.String("module", m.Name()), zap.Error(err))
+		}
+		report(config.SectionModules, m.Name(), err)
+	}
+	for _, g := range k.gateways {
+		if len(changes.Component(config.SectionGateways, g.Name())) == 0 {
+			continue // Only gateways whose section changed are re-configured
+		}
+		// Pass the specific gateway config to the gateway's Configure method
+		gatewayCfg, ok, err := componentConfig(newCfg, config.SectionGateways, g.Name(), g)
+		if err != nil {
+			logger.Error(ctx, "Invalid gateway configuration, keeping the previous one", zap.String("gateway", g.Name()), zap.Error(err))
+		} else if ok {
+			err = k.runPhase(k.gatewayContext(ctx, g), config.SectionGateways, g, config.PhaseOnConfigChanged, func(context.Context) error {
+				return g.Configure(gatewayCfg)
+			})
+			if err != nil {
+				logger.Error(ctx, "Gateway failed to re-configure on config change", zap.String("gateway", g.Name()), zap.Error(err))
 			}
+		} else {
+			logger.Warn(ctx, "Configuration of gateway removed, keeping the previous one", zap.String("gateway", g.Name()))
+			err = fmt.Errorf("configuration removed, keeping the previous one")
 		}
-	})
-	return k
+		report(config.SectionGateways, g.Name(), err)
+	}
+	return results
 }
 
 // kernel is the concrete implementation of the Kernel interface.
 type kernel struct {
 	mu               sync.RWMutex          // Mutex to protect concurrent access to modules, gateways, and running state.
-	config           *config.Config        // Application configuration, accessible to modules.
+	configs          *config.Manager       // Owns the application configuration; read snapshots with Current.
 	modules          map[string]Module     // Stores registered modules.
 	gateways         map[string]Gateway    // Stores registered gateways.
 	moduleStates     map[string]bool       // Stores the enabled/disabled state of modules.
@@ -301,6 +432,14 @@ type kernel struct {
 	accessController auth.AccessController // New field
 	registry         registry.Registry     // Service registry for inter-module communication
 	eventBus         events.Bus            // Event bus for system-wide events
+
+	principalsMu sync.RWMutex              // Protects principals; lifecycle calls read it while mu is held.
+	principals   map[string]auth.Principal // Kernel-issued identities of modules and gateways, see identity.go.
+
+	phasesMu  sync.Mutex            // Protects abandoned; runPhase may be called while mu is held.
+	abandoned map[string]*phaseCall // Timed-out lifecycle calls still running, by "<section>.<name>".
+
+	unsubscribeConfig func() // Stops config validation and change notifications; nil once the kernel has stopped.
 }
 
 // GetRegistry returns the kernel's service registry.
@@ -308,6 +447,48 @@ func (k *kernel) GetRegistry() registry.Registry {
 	return k.registry
 }
 
+// DescribeServices lists the service providers in the kernel's registry that the principal in ctx may access.
+func (k *kernel) DescribeServices(ctx context.Context) ([]registry.ServiceInfo, error) {
+	return k.registry.ListServices(ctx)
+}
+
+// DescribeGateways lists the gateways in the kernel's registry that the principal in ctx may access.
+func (k *kernel) DescribeGateways(ctx context.Context) ([]registry.GatewayInfo, error) {
+	return k.registry.ListGateways(ctx)
+}
+
+// publishServiceChange publishes a registry change on the kernel's event bus.
+func (k *kernel) publishServiceChange(change registry.ServiceChange) {
+	base := ServiceEvent{ServiceName: change.Name, ModuleName: change.ModuleName, Version: change.Version}
+	switch change.Type {
+	case registry.ServiceRegistered:
+		k.eventBus.Publish(context.Background(), ServiceRegisteredEventType, ServiceRegisteredEvent{ServiceEvent: base})
+	case registry.ServiceUnregistered:
+		k.eventBus.Publish(context.Background(), ServiceUnregisteredEventType, ServiceUnregisteredEvent{ServiceEvent: base})
+	case registry.ServiceReplaced:
+		k.eventBus.Publish(context.Background(), ServiceReplacedEventType, ServiceReplacedEvent{ServiceEvent: base})
+	}
+}
+
+// providerHealthy reports whether a service provider is healthy. The service itself is consulted
+// if it implements HealthReporter, otherwise the module that registered it. Providers without a
+// reporter and "degraded" providers are considered healthy.
+func (k *kernel) providerHealthy(ctx context.Context, moduleName string, service interface{}) bool {
+	hr, ok := service.(HealthReporter)
+	if !ok {
+		k.mu.RLock()
+		m, exists := k.modules[moduleName]
+		k.mu.RUnlock()
+		if !exists {
+			return true
+		}
+		if hr, ok = m.(HealthReporter); !ok {
+			return true
+		}
+	}
+	return hr.Health(ctx).Status != "unhealthy"
+}
+
 // safelyExecute runs a function and recovers from panics, returning an error instead.
 func (k *kernel) safelyExecute(ctx context.Context, componentName string, componentType string, operation string, fn func() error) (err error) {
 	defer func() {
@@ -324,6 +505,103 @@ func (k *kernel) safelyExecute(ctx context.Context, componentName string, compon
 	return fn()
 }
 
+// lifecycleComponent is what runPhase needs of a module or gateway.
+type lifecycleComponent interface {
+	Name() string
+	ShutdownTimeout() time.Duration
+}
+
+// phaseOperations names the lifecycle methods of each phase in logs and errors.
+var phaseOperations = map[config.Phase]string{
+	config.PhaseOnLoad:           "OnLoad",
+	config.PhaseConfigure:        "Configure",
+	config.PhaseStart:            "Start",
+	config.PhaseRegisterServices: "RegisterServices",
+	config.PhaseOnReady:          "OnReady",
+	config.PhaseStop:             "Stop",
+	config.PhaseOnConfigChanged:  "OnConfigChanged",
+}
+
+// phaseTimeout returns the timeout of a lifecycle phase of c, a component of section: the
+// override in the timeouts config if there is one, then for Stop the component's own
+// ShutdownTimeout if it sets one, otherwise the section default. Zero means no timeout.
+func (k *kernel) phaseTimeout(section string, c lifecycleComponent, phase config.Phase) time.Duration {
+	timeouts := k.configs.Current().Timeouts
+	if timeout, ok := timeouts.Override(section, c.Name(), phase); ok {
+		return timeout
+	}
+	if phase == config.PhaseStop && c.ShutdownTimeout() > 0 {
+		return c.ShutdownTimeout()
+	}
+	return timeouts.Default(section, phase)
+}
+
+// phaseCall tracks a lifecycle call that runPhase may abandon at its deadline.
+type phaseCall struct {
+	operation string
+	finished  bool // Guarded by kernel.phasesMu
+}
+
+// runPhase runs fn, the given lifecycle call of c, through safelyExecute with ctx bounded by
+// the phase's timeout. A call still running at the deadline is abandoned to finish in the
+// background: runPhase returns an error wrapping context.DeadlineExceeded and counts it in
+// metrics.LifecycleTimeoutCounter, as it does for calls that give up on ctx themselves.
+// Until an abandoned call returns, every lifecycle call of a component with the same name
+// fails with errBusy, so a late Configure or Stop never overlaps a later Start.
+func (k *kernel) runPhase(ctx context.Context, section string, c lifecycleComponent, phase config.Phase, fn func(ctx context.Context) error) error {
+	componentType := "module"
+	if section == config.SectionGateways {
+		componentType = "gateway"
+	}
+	operation := phaseOperations[phase]
+	key := section + "." + c.Name()
+	k.phasesMu.Lock()
+	pending, busy := k.abandoned[key]
+	k.phasesMu.Unlock()
+	if busy {
+		logger.Warn(ctx, "Lifecycle call refused while a timed-out call is still running", zap.String("type", componentType), zap.String("component", c.Name()), zap.String("operation", operation), zap.String("running", pending.operation))
+		return fmt.Errorf("%s %s: %s still running after timing out: %w", componentType, c.Name(), pending.operation, errBusy)
+	}
+
+	timeout := k.phaseTimeout(section, c, phase)
+	if timeout <= 0 {
+		return k.safelyExecute(ctx, c.Name(), componentType, operation, func() error { return fn(ctx) })
+	}
+
+	phaseCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	call := &phaseCall{operation: operation}
+	done := make(chan error, 1) // Buffered, so that an abandoned call does not leak its goroutine
+	go func() {
+		err := k.safelyExecute(phaseCtx, c.Name(), componentType, operation, func() error { return fn(phaseCtx) })
+		k.phasesMu.Lock()
+		call.finished = true
+		if k.abandoned[key] == call {
+			delete(k.abandoned, key)
+		}
+		k.phasesMu.Unlock()
+		done <- err
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-phaseCtx.Done():
+		err = phaseCtx.Err()
+		k.phasesMu.Lock()
+		if !call.finished {
+			k.abandoned[key] = call
+		}
+		k.phasesMu.Unlock()
+	}
+	// Only the phase's own deadline counts; the caller's context may have been cancelled or have expired first
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && errors.Is(phaseCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		metrics.LifecycleTimeoutCounter.WithLabelValues(componentType, c.Name(), string(phase)).Inc()
+		logger.Warn(ctx, "Lifecycle call timed out", zap.String("type", componentType), zap.String("component", c.Name()), zap.String("operation", operation), zap.Duration("timeout", timeout))
+		return fmt.Errorf("%s timed out after %s: %w", operation, timeout, err)
+	}
+	return err
+}
+
 // ReloadModule attempts to stop an existing module, replace it with a new instance,
 // and then start the new instance. It includes a rollback mechanism if the new module fails to start.
 func (k *kernel) ReloadModule(m Module) error {
@@ -347,13 +625,9 @@ func (k *kernel) ReloadModule(m Module) error {
 	logger.Info(context.Background(), "Attempting to reload module", zap.String("module", name))
 
 	// Stop the old module
-	stopTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-	stopCtx, stopCancel := context.WithTimeout(context.Background(), stopTimeout)
-	defer stopCancel()
+	stopCtx := k.moduleContext(context.Background(), oldModule)
 	metrics.ModuleStopCounter.WithLabelValues(name, "attempt").Inc()
-	err := k.safelyExecute(stopCtx, oldModule.Name(), "module", "Stop", func() error {
-		return oldModule.Stop(stopCtx)
-	})
+	err := k.runPhase(stopCtx, config.SectionModules, oldModule, config.PhaseStop, oldModule.Stop)
 	if err != nil {
 		metrics.ModuleStopCounter.WithLabelValues(name, "failed").Inc()
 		logger.Error(stopCtx, "Failed to stop old module during reload", zap.String("module", name), zap.Error(err))
@@ -362,79 +636,119 @@ func (k *kernel) ReloadModule(m Module) error {
 	metrics.ModuleStopCounter.WithLabelValues(name, "success").Inc()
 	logger.Info(stopCtx, "Old module stopped during reload", zap.String("module", name))
 
+	// Drop the old module's services so consumers watching the registry can rebind
+	k.registry.UnregisterServicesByModule(name)
+
 	// Replace with new module
 	k.mu.Lock()
 	k.modules[name] = m
 	k.mu.Unlock()
 	logger.Info(context.Background(), "Module replaced in kernel map", zap.String("module", name))
 
+	// The new instance gets its own identity, derived from its own declared permissions
+	k.attachModule(context.Background(), m)
+	moduleCtx := k.moduleContext(context.Background(), m)
+
 	// Configure the new module
-	configureTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-	if moduleConfig, ok := k.config.Modules[name]; ok {
-		configureCtx, configureCancel := context.WithTimeout(context.Background(), configureTimeout)
-		defer configureCancel()
-		err := k.safelyExecute(configureCtx, m.Name(), "module", "Configure", func() error {
-			return m.Configure(moduleConfig)
-		})
+	moduleConfig, ok, err := componentConfig(k.configs.Current(), config.SectionModules, name, m)
+	if err != nil || ok {
+		if err == nil {
+			err = k.runPhase(moduleCtx, config.SectionModules, m, config.PhaseConfigure, func(context.Context) error {
+				return m.Configure(moduleConfig)
+			})
+		}
 		if err != nil {
-			logger.Error(configureCtx, "Failed to configure new module during reload", zap.String("module", name), zap.Error(err))
+			logger.Error(moduleCtx, "Failed to configure new module during reload", zap.String("module", name), zap.Error(err))
 			// Rollback: try to restore and start the old module
 			k.mu.Lock()
 			k.modules[name] = oldModule
 			k.mu.Unlock()
-			rollbackStartCtx, rollbackStartCancel := context.WithTimeout(context.Background(), stopTimeout) // Use stopTimeout for rollback start
-			defer rollbackStartCancel()
-			if rollbackErr := oldModule.Start(rollbackStartCtx); rollbackErr != nil {
-				logger.Error(rollbackStartCtx, "Failed to rollback to old module after new module config failed", zap.String("module", name), zap.Error(rollbackErr))
+			k.issuePrincipal(context.Background(), PrincipalTypeModule, name, oldModule.Version(), oldModule)
+			rollbackCtx := k.moduleContext(context.Background(), oldModule)
+			if rollbackErr := k.runPhase(rollbackCtx, config.SectionModules, oldModule, config.PhaseStart, oldModule.Start); rollbackErr != nil {
+				logger.Error(rollbackCtx, "Failed to rollback to old module after new module config failed", zap.String("module", name), zap.Error(rollbackErr))
 				return fmt.Errorf("configure new module %s: %w; rollback failed: %w", name, err, rollbackErr)
 			}
+			k.reregisterServices(rollbackCtx, oldModule)
 			return fmt.Errorf("configure new module %s: %w", name, err)
 		}
 	}
 
 	// Start the new module
-	startTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-	startCtx, startCancel := context.WithTimeout(context.Background(), startTimeout)
-	defer startCancel()
 	metrics.ModuleStartCounter.WithLabelValues(name, "attempt").Inc()
-	err = k.safelyExecute(startCtx, m.Name(), "module", "Start", func() error {
-		return m.Start(startCtx)
-	})
+	err = k.runPhase(moduleCtx, config.SectionModules, m, config.PhaseStart, m.Start)
 	if err != nil {
 		metrics.ModuleStartCounter.WithLabelValues(name, "failed").Inc()
-		logger.Error(startCtx, "Failed to start new module during reload, attempting rollback", zap.String("module", name), zap.Error(err))
+		logger.Error(moduleCtx, "Failed to start new module during reload, attempting rollback", zap.String("module", name), zap.Error(err))
 		// Rollback: try to restore and start the old module
 		k.mu.Lock()
 		k.modules[name] = oldModule
 		k.mu.Unlock()
-		rollbackStartCtx, rollbackStartCancel := context.WithTimeout(context.Background(), stopTimeout) // Use stopTimeout for rollback start
-		defer rollbackStartCancel()
-		logger.Info(rollbackStartCtx, "Attempting to restart old module after new module failed to start", zap.String("module", name))
-		if rollbackErr := oldModule.Start(rollbackStartCtx); rollbackErr != nil {
-			logger.Error(rollbackStartCtx, "Failed to rollback to old module", zap.String("module", name), zap.Error(rollbackErr))
+		k.issuePrincipal(context.Background(), PrincipalTypeModule, name, oldModule.Version(), oldModule)
+		rollbackCtx := k.moduleContext(context.Background(), oldModule)
+		logger.Info(rollbackCtx, "Attempting to restart old module after new module failed to start", zap.String("module", name))
+		if rollbackErr := k.runPhase(rollbackCtx, config.SectionModules, oldModule, config.PhaseStart, oldModule.Start); rollbackErr != nil {
+			logger.Error(rollbackCtx, "Failed to rollback to old module", zap.String("module", name), zap.Error(rollbackErr))
 			return fmt.Errorf("start new module %s: %w; rollback failed: %w", name, err, rollbackErr)
 		}
-		logger.Info(rollbackStartCtx, "Rollback to old module successful", zap.String("module", name))
+		k.reregisterServices(rollbackCtx, oldModule)
+		logger.Info(rollbackCtx, "Rollback to old module successful", zap.String("module", name))
 		return fmt.Errorf("start new module %s: %w", name, err)
 	}
 	metrics.ModuleStartCounter.WithLabelValues(name, "success").Inc()
-	logger.Info(startCtx, "New module started successfully during reload", zap.String("module", name))
+	logger.Info(moduleCtx, "New module started successfully during reload", zap.String("module", name))
+
+	// Register the new module's services
+	k.reregisterServices(moduleCtx, m)
 
 	// Call OnReady for the new module
-	onReadyTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-	onReadyCtx, onReadyCancel := context.WithTimeout(context.Background(), onReadyTimeout)
-	defer onReadyCancel()
-	err = k.safelyExecute(onReadyCtx, m.Name(), "module", "OnReady", func() error {
-		return m.OnReady(onReadyCtx)
-	})
+	err = k.runPhase(moduleCtx, config.SectionModules, m, config.PhaseOnReady, m.OnReady)
 	if err != nil {
-		logger.Error(onReadyCtx, "Failed to call OnReady for new module during reload", zap.String("module", name), zap.Error(err))
+		logger.Error(moduleCtx, "Failed to call OnReady for new module during reload", zap.String("module", name), zap.Error(err))
 		// Decide if this should trigger a rollback or just be logged. For now, log and continue.
 	}
 
 	return nil // Reload successful.
 }
 
+// reregisterServices asks a (re)started module to register its services, logging any failure.
+func (k *kernel) reregisterServices(ctx context.Context, m Module) {
+	err := k.runPhase(ctx, config.SectionModules, m, config.PhaseRegisterServices, func(context.Context) error {
+		return m.RegisterServices(k.registry)
+	})
+	if err != nil {
+		logger.Error(ctx, "Failed to call RegisterServices for module", zap.String("module", m.Name()), zap.Error(err))
+	}
+}
+
+// stopModuleQuietly stops m on a best-effort basis, as when undoing a failed kernel start.
+func (k *kernel) stopModuleQuietly(m Module) {
+	_ = k.runPhase(k.moduleContext(context.Background(), m), config.SectionModules, m, config.PhaseStop, m.Stop)
+}
+
+// componentConfig returns what to pass to the Configure method of a module or gateway: the
+// decoded and validated typed config for components implementing config.TypedConfig, otherwise
+// the raw section of cfg. Either way the section is first upgraded with the component's
+// migrations, if it implements config.MigratingConfig. ok is false if there is nothing to
+// configure.
+func componentConfig(cfg *config.Config, section, name string, component interface{}) (value interface{}, ok bool, err error) {
+	if spec, typed := component.(config.TypedConfig); typed {
+		value, err := cfg.DecodeComponent(section, name, spec)
+		return value, err == nil, err
+	}
+	var raw map[string]interface{}
+	if section == config.SectionModules {
+		raw, ok = cfg.Modules[name]
+	} else {
+		raw, ok = cfg.Gateways[name]
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	raw, err = config.MigrateSection(section+"."+name, component, raw)
+	return raw, err == nil, err
+}
+
 // AddModule registers a new module with the kernel. If the kernel is already running,
 // the module will be started immediately.
 // Requires context with principal for security validation.
@@ -471,38 +785,42 @@ func (k *kernel) AddModule(ctx context.Context, m Module) error {
 	running := k.running
 	k.mu.Unlock()
 
-	// Provide the event bus to the module
-	m.SetEventBus(k.eventBus)
-
-	// Provide the registry to the module if it implements SetRegistry
-	if regSetter, ok := m.(interface{ SetRegistry(registry.Registry) }); ok {
-		regSetter.SetRegistry(k.registry)
-	}
+	// Issue the module's identity and hand it the event bus and registry. From here on the
+	// lifecycle calls run as the module rather than as the caller that added it.
+	k.attachModule(ctx, m)
+	ctx = k.moduleContext(ctx, m)
 
 	// Call OnLoad for the new module
-	onLoadTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-	onLoadCtx, onLoadCancel := context.WithTimeout(ctx, onLoadTimeout)
-	defer onLoadCancel()
-	err := k.safelyExecute(onLoadCtx, m.Name(), "module", "OnLoad", func() error {
-		return m.OnLoad(onLoadCtx)
-	})
+	err := k.runPhase(ctx, config.SectionModules, m, config.PhaseOnLoad, m.OnLoad)
 	if err != nil {
 		k.mu.Lock() // Re-acquire lock to delete module on failure
 		delete(k.modules, name)
 		k.mu.Unlock()
-		logger.Error(onLoadCtx, "Failed to call OnLoad for module", zap.String("module", name), zap.Error(err))
+		k.revokePrincipal(PrincipalTypeModule, name)
+		logger.Error(ctx, "Failed to call OnLoad for module", zap.String("module", name), zap.Error(err))
 		return fmt.Errorf("module %s OnLoad: %w", name, err)
 	}
 
 	// Configure the module
-	if moduleConfig, ok := k.config.Modules[name]; ok {
-		configureCtx, configureCancel := context.WithTimeout(ctx, moduleOperationTimeout)
-		defer configureCancel()
-		err := k.safelyExecute(configureCtx, m.Name(), "module", "Configure", func() error {
+	moduleConfig, ok, err := componentConfig(k.configs.Current(), config.SectionModules, name, m)
+	if err != nil {
+		k.mu.Lock()
+		delete(k.modules, name)
+		k.mu.Unlock()
+		k.revokePrincipal(PrincipalTypeModule, name)
+		logger.Error(ctx, "Invalid module configuration", zap.String("module", name), zap.Error(err))
+		return fmt.Errorf("configure module %s: %w", name, err)
+	}
+	if ok {
+		err := k.runPhase(ctx, config.SectionModules, m, config.PhaseConfigure, func(context.Context) error {
 			return m.Configure(moduleConfig)
 		})
 		if err != nil {
-			logger.Error(configureCtx, "Failed to configure module", zap.String("module", name), zap.Error(err))
+			k.mu.Lock()
+			delete(k.modules, name)
+			k.mu.Unlock()
+			k.revokePrincipal(PrincipalTypeModule, name)
+			logger.Error(ctx, "Failed to configure module", zap.String("module", name), zap.Error(err))
 			return fmt.Errorf("configure module %s: %w", name, err)
 		}
 	}
@@ -516,29 +834,22 @@ func (k *kernel) AddModule(ctx context.Context, m Module) error {
 		// a mechanism to re-evaluate the full dependency graph or trigger a kernel reload. This is a
 		// significant architectural decision and is currently out of scope for this polishing task.
 		logger.Info(ctx, "Kernel is running, attempting to start newly added module (dynamic dependency re-evaluation limited)", zap.String("module", name))
-		startTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-		startCtx, startCancel := context.WithTimeout(ctx, startTimeout)
-		defer startCancel()
 		metrics.ModuleStartCounter.WithLabelValues(name, "attempt").Inc()
-		err := k.safelyExecute(startCtx, m.Name(), "module", "Start", func() error {
-			return m.Start(startCtx)
-		})
+		err := k.runPhase(ctx, config.SectionModules, m, config.PhaseStart, m.Start)
 		if err != nil {
 			k.mu.Lock()
 			delete(k.modules, name)
 			k.mu.Unlock()
-			logger.Error(startCtx, "Failed to start module immediately after adding", zap.String("module", name), zap.Error(err))
+			k.revokePrincipal(PrincipalTypeModule, name)
+			logger.Error(ctx, "Failed to start module immediately after adding", zap.String("module", name), zap.Error(err))
 			metrics.ModuleStartCounter.WithLabelValues(name, "failed").Inc()
 			return fmt.Errorf("start module %s: %w", name, err)
 		}
 		metrics.ModuleStartCounter.WithLabelValues(name, "success").Inc()
-		logger.Info(startCtx, "Module started immediately after adding", zap.String("module", name))
+		logger.Info(ctx, "Module started immediately after adding", zap.String("module", name))
 
 		// Call RegisterServices for the newly added module
-		registerServicesTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-		registerServicesCtx, registerServicesCancel := context.WithTimeout(ctx, registerServicesTimeout)
-		defer registerServicesCancel()
-		err = k.safelyExecute(registerServicesCtx, m.Name(), "module", "RegisterServices", func() error {
+		err = k.runPhase(ctx, config.SectionModules, m, config.PhaseRegisterServices, func(context.Context) error {
 			return m.RegisterServices(k.registry)
 		})
 		if err != nil {
@@ -546,14 +857,9 @@ func (k *kernel) AddModule(ctx context.Context, m Module) error {
 		}
 
 		// Call OnReady for the newly added module
-		onReadyTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-		onReadyCtx, onReadyCancel := context.WithTimeout(ctx, onReadyTimeout)
-		defer onReadyCancel()
-		err = k.safelyExecute(onReadyCtx, m.Name(), "module", "OnReady", func() error {
-			return m.OnReady(onReadyCtx)
-		})
+		err = k.runPhase(ctx, config.SectionModules, m, config.PhaseOnReady, m.OnReady)
 		if err != nil {
-			logger.Error(onReadyCtx, "Failed to call OnReady for newly added module", zap.String("module", name), zap.Error(err))
+			logger.Error(ctx, "Failed to call OnReady for newly added module", zap.String("module", name), zap.Error(err))
 		}
 	}
 	return nil
@@ -606,13 +912,8 @@ func (k *kernel) RemoveModule(ctx context.Context, name string) error {
 	logger.Info(ctx, "Unregistered services for module", zap.String("module", name))
 
 	if running {
-		stopTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-		stopCtx, stopCancel := context.WithTimeout(ctx, stopTimeout)
-		defer stopCancel()
 		metrics.ModuleStopCounter.WithLabelValues(name, "attempt").Inc()
-		if err := k.safelyExecute(stopCtx, m.Name(), "module", "Stop", func() error {
-			return m.Stop(stopCtx)
-		}); err != nil {
+		if err := k.runPhase(k.moduleContext(ctx, m), config.SectionModules, m, config.PhaseStop, m.Stop); err != nil {
 			k.mu.Lock()
 			k.modules[name] = m         // Restore the module to the map if stopping fails.
 			k.moduleStates[name] = true // Restore its state as enabled
@@ -624,6 +925,7 @@ func (k *kernel) RemoveModule(ctx context.Context, name string) error {
 		metrics.ModuleStopCounter.WithLabelValues(name, "success").Inc()
 		logger.Info(ctx, "Module stopped during removal", zap.String("module", name))
 	}
+	k.revokePrincipal(PrincipalTypeModule, name)
 	return nil
 }
 
@@ -657,8 +959,17 @@ func (k *kernel) AddGateway(g Gateway) error {
 	}
 
 	// Configure the gateway before adding it.
-	if gatewayConfig, ok := k.config.Gateways[name]; ok {
-		if err := g.Configure(gatewayConfig); err != nil {
+	gatewayConfig, ok, err := componentConfig(k.configs.Current(), config.SectionGateways, name, g)
+	if err != nil {
+		k.mu.Unlock()
+		logger.Error(context.Background(), "Invalid gateway configuration", zap.String("gateway", name), zap.Error(err))
+		return fmt.Errorf("configure gateway %s: %w", name, err)
+	}
+	if ok {
+		err := k.runPhase(context.Background(), config.SectionGateways, g, config.PhaseConfigure, func(context.Context) error {
+			return g.Configure(gatewayConfig)
+		})
+		if err != nil {
 			k.mu.Unlock()
 			logger.Error(context.Background(), "Failed to configure gateway", zap.String("gateway", name), zap.Error(err))
 			return fmt.Errorf("configure gateway %s: %w", name, err)
@@ -669,8 +980,8 @@ func (k *kernel) AddGateway(g Gateway) error {
 	running := k.running
 	k.mu.Unlock()
 
-	// Provide the event bus to the gateway
-	g.SetEventBus(k.eventBus)
+	// Issue the gateway's identity and hand it the event bus
+	k.attachGateway(context.Background(), g)
 
 	// Register the gateway with the registry
 	if err := k.registry.RegisterGateway(name, g); err != nil {
@@ -681,15 +992,13 @@ func (k *kernel) AddGateway(g Gateway) error {
 	logger.Info(context.Background(), "Gateway added and registered", zap.String("gateway", name))
 
 	if running {
-		startTimeout := time.Duration(k.config.Timeouts.GatewayOperation) * time.Second
-		startCtx, startCancel := context.WithTimeout(context.Background(), startTimeout)
-		defer startCancel()
 		metrics.GatewayStartCounter.WithLabelValues(name, "attempt").Inc()
-		if err := g.Start(startCtx); err != nil {
+		if err := k.runPhase(k.gatewayContext(context.Background(), g), config.SectionGateways, g, config.PhaseStart, g.Start); err != nil {
 			k.mu.Lock()
 			delete(k.gateways, name) // Remove the gateway from the map if it fails to start.
 			k.mu.Unlock()
 			k.registry.UnregisterGateway(name) // Unregister from registry on failure
+			k.revokePrincipal(PrincipalTypeGateway, name)
 			logger.Error(context.Background(), "Failed to start gateway immediately after adding", zap.String("gateway", name), zap.Error(err))
 			metrics.GatewayStartCounter.WithLabelValues(name, "failed").Inc()
 			return fmt.Errorf("start gateway %s: %w", name, err)
@@ -721,11 +1030,8 @@ func (k *kernel) RemoveGateway(name string) error {
 	logger.Info(context.Background(), "Gateway removed and unregistered", zap.String("gateway", name))
 
 	if running {
-		stopTimeout := time.Duration(k.config.Timeouts.GatewayOperation) * time.Second
-		stopCtx, stopCancel := context.WithTimeout(context.Background(), stopTimeout)
-		defer stopCancel()
 		metrics.GatewayStopCounter.WithLabelValues(name, "attempt").Inc()
-		if err := g.Stop(stopCtx); err != nil {
+		if err := k.runPhase(k.gatewayContext(context.Background(), g), config.SectionGateways, g, config.PhaseStop, g.Stop); err != nil {
 			k.mu.Lock()
 			k.gateways[name] = g // Restore the gateway to the map if stopping fails.
 			k.mu.Unlock()
@@ -737,6 +1043,7 @@ func (k *kernel) RemoveGateway(name string) error {
 		metrics.GatewayStopCounter.WithLabelValues(name, "success").Inc()
 		logger.Info(context.Background(), "Gateway stopped during removal", zap.String("gateway", name))
 	}
+	k.revokePrincipal(PrincipalTypeGateway, name)
 	return nil // Gateway removed successfully.
 }
 
@@ -770,6 +1077,9 @@ func (k *kernel) Start(ctx context.Context) error {
 		return errAlreadyRunning                                                                        // Return error if kernel is already running.
 	}
 	k.running = true // Set kernel to running state.
+	if k.unsubscribeConfig == nil {
+		k.unsubscribeConfig = k.followConfig() // Restarted after a Stop
+	}
 	// Create local slices of modules and gateways to avoid holding the lock during Start calls.
 	// Only include modules that are enabled.
 	modulesToStart := make([]Module, 0, len(k.modules))
@@ -806,11 +1116,9 @@ func (k *kernel) Start(ctx context.Context) error {
 
 	// Start modules in dependency order
 	for _, m := range orderedModules {
-		moduleCtx, moduleSpan := tracer.Start(ctx, fmt.Sprintf("Module.Start: %s", m.Name()), trace.WithAttributes(attribute.String("module.name", m.Name())))
+		moduleCtx, moduleSpan := tracer.Start(k.moduleContext(ctx, m), fmt.Sprintf("Module.Start: %s", m.Name()), trace.WithAttributes(attribute.String("module.name", m.Name())))
 		metrics.ModuleStartCounter.WithLabelValues(m.Name(), "attempt").Inc()
-		err := k.safelyExecute(moduleCtx, m.Name(), "module", "Start", func() error {
-			return m.Start(moduleCtx)
-		})
+		err := k.runPhase(moduleCtx, config.SectionModules, m, config.PhaseStart, m.Start)
 		if err != nil {
 			moduleSpan.RecordError(err)
 			moduleSpan.SetStatus(codes.Error, err.Error())
@@ -825,7 +1133,7 @@ func (k *kernel) Start(ctx context.Context) error {
 				if orderedModules[i].Name() == m.Name() {
 					break
 				}
-				_ = orderedModules[i].Stop(context.Background())
+				k.stopModuleQuietly(orderedModules[i])
 			}
 			moduleSpan.End()
 			return fmt.Errorf("start module %s: %w", m.Name(), err)
@@ -837,8 +1145,8 @@ func (k *kernel) Start(ctx context.Context) error {
 
 	// Call RegisterServices for all started modules
 	for _, m := range orderedModules {
-		registerServicesCtx, registerServicesSpan := tracer.Start(ctx, fmt.Sprintf("Module.RegisterServices: %s", m.Name()), trace.WithAttributes(attribute.String("module.name", m.Name())))
-		err := k.safelyExecute(registerServicesCtx, m.Name(), "module", "RegisterServices", func() error {
+		registerServicesCtx, registerServicesSpan := tracer.Start(k.moduleContext(ctx, m), fmt.Sprintf("Module.RegisterServices: %s", m.Name()), trace.WithAttributes(attribute.String("module.name", m.Name())))
+		err := k.runPhase(registerServicesCtx, config.SectionModules, m, config.PhaseRegisterServices, func(context.Context) error {
 			return m.RegisterServices(k.registry)
 		})
 		if err != nil {
@@ -847,7 +1155,7 @@ func (k *kernel) Start(ctx context.Context) error {
 			logger.Error(ctx, "Failed to call RegisterServices for module, halting kernel startup.", zap.String("module", m.Name()), zap.Error(err))
 			// Stop all modules that have already started, in reverse order.
 			for i := len(orderedModules) - 1; i >= 0; i-- {
-				_ = orderedModules[i].Stop(context.Background())
+				k.stopModuleQuietly(orderedModules[i])
 			}
 			registerServicesSpan.End()
 			return fmt.Errorf("module %s RegisterServices: %w", m.Name(), err)
@@ -857,18 +1165,14 @@ func (k *kernel) Start(ctx context.Context) error {
 
 	// Call OnReady for all started modules
 	for _, m := range orderedModules {
-		onReadyCtx, onReadySpan := tracer.Start(ctx, fmt.Sprintf("Module.OnReady: %s", m.Name()), trace.WithAttributes(attribute.String("module.name", m.Name())))
-		err := k.safelyExecute(onReadyCtx, m.Name(), "module", "OnReady", func() error {
-			return m.OnReady(onReadyCtx)
-		})
+		onReadyCtx, onReadySpan := tracer.Start(k.moduleContext(ctx, m), fmt.Sprintf("Module.OnReady: %s", m.Name()), trace.WithAttributes(attribute.String("module.name", m.Name())))
+		err := k.runPhase(onReadyCtx, config.SectionModules, m, config.PhaseOnReady, m.OnReady)
 		if err != nil {
 			onReadySpan.RecordError(err)
 			onReadySpan.SetStatus(codes.Error, err.Error())
 			logger.Error(ctx, "Failed to call OnReady for module. Attempting to stop module due to OnReady failure.", zap.String("module", m.Name()), zap.Error(err))
 			// Stop the module if OnReady fails to ensure stability.
-			stopCtx, stopCancel := context.WithTimeout(context.Background(), m.ShutdownTimeout())
-			defer stopCancel()
-			if stopErr := m.Stop(stopCtx); stopErr != nil {
+			if stopErr := k.runPhase(k.moduleContext(context.Background(), m), config.SectionModules, m, config.PhaseStop, m.Stop); stopErr != nil {
 				logger.Error(ctx, "Failed to stop module after OnReady failure", zap.String("module", m.Name()), zap.Error(stopErr))
 			}
 			return fmt.Errorf("module %s OnReady: %w", m.Name(), err) // Propagate the error
@@ -877,16 +1181,10 @@ func (k *kernel) Start(ctx context.Context) error {
 	}
 
 	// Then gateways
-	gatewayStartTimeout := time.Duration(k.config.Timeouts.GatewayOperation) * time.Second
 	for _, g := range gatewaysToStart {
-		gatewayCtx, gatewaySpan := tracer.Start(ctx, fmt.Sprintf("Gateway.Start: %s", g.Name()), trace.WithAttributes(attribute.String("gateway.name", g.Name())))
+		gatewayCtx, gatewaySpan := tracer.Start(k.gatewayContext(ctx, g), fmt.Sprintf("Gateway.Start: %s", g.Name()), trace.WithAttributes(attribute.String("gateway.name", g.Name())))
 		metrics.GatewayStartCounter.WithLabelValues(g.Name(), "attempt").Inc()
-		err := k.safelyExecute(gatewayCtx, g.Name(), "gateway", "Start", func() error {
-			// Use a context with timeout for the individual gateway start
-			startCtx, startCancel := context.WithTimeout(gatewayCtx, gatewayStartTimeout)
-			defer startCancel()
-			return g.Start(startCtx)
-		})
+		err := k.runPhase(gatewayCtx, config.SectionGateways, g, config.PhaseStart, g.Start)
 		if err != nil {
 			gatewaySpan.RecordError(err)
 			gatewaySpan.SetStatus(codes.Error, err.Error())
@@ -901,11 +1199,11 @@ func (k *kernel) Start(ctx context.Context) error {
 				if started == g {
 					break
 				}
-				_ = started.Stop(context.Background())
+				_ = k.runPhase(k.gatewayContext(context.Background(), started), config.SectionGateways, started, config.PhaseStop, started.Stop)
 			}
 			// and stop all modules in reverse order
 			for i := len(orderedModules) - 1; i >= 0; i-- {
-				_ = orderedModules[i].Stop(context.Background())
+				k.stopModuleQuietly(orderedModules[i])
 			}
 			gatewaySpan.End()
 			return fmt.Errorf("start gateway %s: %w", g.Name(), err)
@@ -929,6 +1227,10 @@ func (k *kernel) Stop(ctx context.Context) error {
 		return errNotRunning                                                                 // Return error if kernel is not running.
 	}
 	k.running = false // Set kernel to not running state.
+	// A stopped kernel no longer follows the configuration, so that it can be garbage collected
+	// while the manager lives on. The call does not block on a notification in progress.
+	k.unsubscribeConfig()
+	k.unsubscribeConfig = nil
 
 	tracer := otel.Tracer("acacia-kernel")
 	ctx, span := tracer.Start(ctx, "Kernel.Stop")
@@ -955,20 +1257,10 @@ func (k *kernel) Stop(ctx context.Context) error {
 
 	// Stop gateways first (reverse order is not necessary since we don't track order, but we stop all)
 	var firstErr error // To capture the first error encountered during stopping.
-	gatewayStopTimeout := time.Duration(k.config.Timeouts.GatewayOperation) * time.Second
 	for _, g := range gatewaysToStop {
-		timeout := g.ShutdownTimeout()
-		if timeout <= 0 {
-			timeout = gatewayStopTimeout
-		}
-		stopCtx, stopCancel := context.WithTimeout(ctx, timeout)
-
-		gatewayCtx, gatewaySpan := tracer.Start(stopCtx, fmt.Sprintf("Gateway.Stop: %s", g.Name()), trace.WithAttributes(attribute.String("gateway.name", g.Name())))
+		gatewayCtx, gatewaySpan := tracer.Start(k.gatewayContext(ctx, g), fmt.Sprintf("Gateway.Stop: %s", g.Name()), trace.WithAttributes(attribute.String("gateway.name", g.Name())))
 		metrics.GatewayStopCounter.WithLabelValues(g.Name(), "attempt").Inc()
-		err := k.safelyExecute(gatewayCtx, g.Name(), "gateway", "Stop", func() error {
-			return g.Stop(gatewayCtx)
-		})
-		stopCancel()
+		err := k.runPhase(gatewayCtx, config.SectionGateways, g, config.PhaseStop, g.Stop)
 		if err != nil {
 			gatewaySpan.RecordError(err)
 			gatewaySpan.SetStatus(codes.Error, err.Error())
@@ -984,20 +1276,10 @@ func (k *kernel) Stop(ctx context.Context) error {
 		gatewaySpan.End()
 	}
 	// Then modules in reverse dependency order
-	moduleStopTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
 	for _, m := range orderedModulesToStop {
-		timeout := m.ShutdownTimeout()
-		if timeout <= 0 {
-			timeout = moduleStopTimeout
-		}
-		stopCtx, stopCancel := context.WithTimeout(ctx, timeout)
-
-		moduleCtx, moduleSpan := tracer.Start(stopCtx, fmt.Sprintf("Module.Stop: %s", m.Name()), trace.WithAttributes(attribute.String("module.name", m.Name())))
+		moduleCtx, moduleSpan := tracer.Start(k.moduleContext(ctx, m), fmt.Sprintf("Module.Stop: %s", m.Name()), trace.WithAttributes(attribute.String("module.name", m.Name())))
 		metrics.ModuleStopCounter.WithLabelValues(m.Name(), "attempt").Inc()
-		err := k.safelyExecute(moduleCtx, m.Name(), "module", "Stop", func() error {
-			return m.Stop(moduleCtx)
-		})
-		stopCancel()
+		err := k.runPhase(moduleCtx, config.SectionModules, m, config.PhaseStop, m.Stop)
 		if err != nil {
 			moduleSpan.RecordError(err)
 			moduleSpan.SetStatus(codes.Error, err.Error())
@@ -1174,6 +1456,7 @@ func (k *kernel) EnableModule(ctx context.Context, name string) error {
 		return fmt.Errorf("module %s: %w", name, errNotFound)
 	}
 
+	wasEnabled := k.moduleStates[name]
 	k.moduleStates[name] = true // Mark as enabled
 	logger.Info(ctx, "Module marked as enabled", zap.String("module", name), zap.String("principal", principal.ID()))
 
@@ -1183,11 +1466,9 @@ func (k *kernel) EnableModule(ctx context.Context, name string) error {
 		// against all currently running modules. For a more robust dynamic integration, consider
 		// a mechanism to re-evaluate the full dependency graph or trigger a kernel reload. This is a
 		// significant architectural decision and is currently out of scope for this polishing task.
-		startTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-		startCtx, startCancel := context.WithTimeout(ctx, startTimeout)
-		defer startCancel()
+		startCtx := k.moduleContext(ctx, m)
 		metrics.ModuleStartCounter.WithLabelValues(name, "attempt").Inc()
-		if err := m.Start(startCtx); err != nil {
+		if err := k.runPhase(startCtx, config.SectionModules, m, config.PhaseStart, m.Start); err != nil {
 			metrics.ModuleStartCounter.WithLabelValues(name, "failed").Inc()
 			logger.Error(ctx, "Failed to start enabled module", zap.String("module", name), zap.Error(err))
 			return fmt.Errorf("start enabled module %s: %w", name, err)
@@ -1195,8 +1476,11 @@ func (k *kernel) EnableModule(ctx context.Context, name string) error {
 		metrics.ModuleStartCounter.WithLabelValues(name, "success").Inc()
 		logger.Info(ctx, "Enabled module started successfully", zap.String("module", name))
 
-		// Services should already be registered if the module was previously started.
-		// OnReady should also not be re-triggered on a simple enable/disable cycle.
+		// Services were unregistered when the module was disabled, so register them again.
+		// OnReady should not be re-triggered on a simple enable/disable cycle.
+		if !wasEnabled {
+			k.reregisterServices(startCtx, m)
+		}
 	}
 	return nil
 }
@@ -1231,17 +1515,17 @@ func (k *kernel) DisableModule(ctx context.Context, name string) error {
 
 	if k.running {
 		logger.Info(ctx, "Kernel is running, attempting to stop disabled module", zap.String("module", name))
-		stopTimeout := time.Duration(k.config.Timeouts.ModuleOperation) * time.Second
-		stopCtx, stopCancel := context.WithTimeout(ctx, stopTimeout)
-		defer stopCancel()
 		metrics.ModuleStopCounter.WithLabelValues(name, "attempt").Inc()
-		if err := m.Stop(stopCtx); err != nil {
+		if err := k.runPhase(k.moduleContext(ctx, m), config.SectionModules, m, config.PhaseStop, m.Stop); err != nil {
 			metrics.ModuleStopCounter.WithLabelValues(name, "failed").Inc()
 			logger.Error(ctx, "Failed to stop disabled module", zap.String("module", name), zap.Error(err))
 			return fmt.Errorf("stop disabled module %s: %w", name, err)
 		}
 		metrics.ModuleStopCounter.WithLabelValues(name, "success").Inc()
 		logger.Info(ctx, "Disabled module stopped successfully", zap.String("module", name))
+
+		// Unregister the stopped module's services so consumers do not keep calling into it.
+		k.registry.UnregisterServicesByModule(name)
 	}
 	return nil
 }
@@ -5,12 +5,17 @@ import (
 	"acacia/core/config"
 	"acacia/core/events"
 	"acacia/core/kernel"
+	"acacia/core/metrics"
 	"acacia/core/registry"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // testPrincipal implements auth.Principal for testing purposes.
@@ -135,8 +140,11 @@ func (m *recModule) Stop(ctx context.Context) error {
 	}
 	return nil
 }
-func (m *recModule) OnConfigChanged(ctx context.Context, newCfg interface{}) error {
+func (m *recModule) OnConfigChanged(ctx context.Context, change config.ComponentChange) error {
 	m.rec.add("module:" + m.name + ":onconfigchanged")
+	for _, c := range change.Changes {
+		m.rec.add("module:" + m.name + ":changed:" + c.Path)
+	}
 	return nil
 }
 func (m *recModule) ShutdownTimeout() time.Duration { return 5 * time.Second }
@@ -168,7 +176,7 @@ func (g *recGateway) ShutdownTimeout() time.Duration  { return 5 * time.Second }
 
 func TestKernel_StartStopOrdering(t *testing.T) {
 	rec := &recorder{}
-	krn := kernel.New(&config.Config{}, nil)
+	krn := kernel.New(config.NewStaticManager(&config.Config{}), nil)
 
 	// Create context with test principal that has module permissions
 	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
@@ -194,7 +202,7 @@ func TestKernel_StartStopOrdering(t *testing.T) {
 
 func TestKernel_ServiceAndEventInteraction(t *testing.T) {
 	rec := &recorder{}
-	krn := kernel.New(&config.Config{}, nil)
+	krn := kernel.New(config.NewStaticManager(&config.Config{}), nil)
 
 	testMod := &recModule{
 		name:          "test-module",
@@ -238,3 +246,337 @@ func TestKernel_ServiceAndEventInteraction(t *testing.T) {
 		t.Fatalf("stop kernel: %v", err)
 	}
 }
+
+func TestKernel_ServiceEventsOnDisable(t *testing.T) {
+	rec := &recorder{}
+	krn := kernel.New(config.NewStaticManager(&config.Config{}), nil)
+
+	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
+		id: "test-kernel", pType: "system", roles: []string{"kernel.module.*"},
+	})
+	testMod := &recModule{name: "test-module", rec: rec, eventReceived: make(chan struct{})}
+	_ = krn.AddModule(ctx, testMod)
+
+	unregistered, cancel, _ := testMod.eventBus.Subscribe(kernel.ServiceUnregisteredEventType)
+	defer cancel()
+
+	if err := krn.Start(context.Background()); err != nil {
+		t.Fatalf("start kernel: %v", err)
+	}
+	defer krn.Stop(context.Background())
+
+	if err := krn.DisableModule(ctx, "test-module"); err != nil {
+		t.Fatalf("disable module: %v", err)
+	}
+
+	select {
+	case ev := <-unregistered:
+		e, ok := ev.(kernel.ServiceUnregisteredEvent)
+		if !ok || e.ServiceName != "test-moduleService" || e.ModuleName != "test-module" {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for service.unregistered event")
+	}
+
+	ctxWithP := auth.ContextWithPrincipal(context.Background(), &testPrincipal{id: "test", pType: "test", roles: []string{"admin"}})
+	if _, err := krn.GetRegistry().GetService(ctxWithP, "test-moduleService"); err == nil {
+		t.Fatal("service of a disabled module should no longer be registered")
+	}
+}
+
+type typedModuleConfig struct {
+	Workers int    `mapstructure:"workers" default:"2" validate:"min=1"`
+	Mode    string `mapstructure:"mode" default:"fast" validate:"oneof=fast safe"`
+}
+
+// typedModule is a recModule that declares a typed config.
+type typedModule struct {
+	*recModule
+	cfg interface{}
+}
+
+func (m *typedModule) ConfigSpec() interface{} { return &typedModuleConfig{} }
+func (m *typedModule) Configure(cfg interface{}) error {
+	m.cfg = cfg
+	return m.recModule.Configure(cfg)
+}
+
+func TestKernel_TypedModuleConfig(t *testing.T) {
+	rec := &recorder{}
+	krn := kernel.New(config.NewStaticManager(&config.Config{Modules: map[string]map[string]interface{}{
+		"typed": {"workers": 8},
+		"bad":   {"workers": 0, "mode": "turbo"},
+	}}), nil)
+	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
+		id: "test-kernel", pType: "system", roles: []string{"kernel.module.*"},
+	})
+
+	// Modules without a section still get their defaults
+	for _, name := range []string{"typed", "unconfigured"} {
+		m := &typedModule{recModule: &recModule{name: name, rec: rec}}
+		if err := krn.AddModule(ctx, m); err != nil {
+			t.Fatalf("add %s: %v", name, err)
+		}
+		cfg, ok := m.cfg.(*typedModuleConfig)
+		if !ok || cfg.Mode != "fast" || (name == "typed" && cfg.Workers != 8) || (name == "unconfigured" && cfg.Workers != 2) {
+			t.Errorf("%s configured with %#v", name, m.cfg)
+		}
+	}
+
+	bad := &typedModule{recModule: &recModule{name: "bad", rec: rec}}
+	err := krn.AddModule(ctx, bad)
+	if err == nil || bad.cfg != nil {
+		t.Fatalf("invalid config should fail before Configure, got %v", err)
+	}
+	for _, want := range []string{"modules.bad.workers: must be at least 1", "modules.bad.mode: must be one of fast, safe"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should contain %q", err, want)
+		}
+	}
+	// The rejected module is not left behind, so a retry fails on its config again rather than as a duplicate
+	if _, ok := krn.GetModule("bad"); ok {
+		t.Error("module with invalid config should not stay registered")
+	}
+	if err := krn.AddModule(ctx, bad); err == nil || strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("retry = %v, want the validation error again", err)
+	}
+}
+
+func (r *recorder) count(ev string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, e := range r.events {
+		if e == ev {
+			n++
+		}
+	}
+	return n
+}
+
+func TestKernel_ConfigManagerSubscriptions(t *testing.T) {
+	// Two kernels with their own managers in one process: changes to one reach only its modules
+	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
+		id: "test-kernel", pType: "system", roles: []string{"kernel.module.*"},
+	})
+	recA, recB := &recorder{}, &recorder{}
+	configsA, configsB := config.NewStaticManager(&config.Config{}), config.NewStaticManager(&config.Config{})
+	krnA, krnB := kernel.New(configsA, nil), kernel.New(configsB, nil)
+	if err := krnA.AddModule(ctx, &recModule{name: "a", rec: recA}); err != nil {
+		t.Fatal(err)
+	}
+	if err := krnB.AddModule(ctx, &recModule{name: "b", rec: recB}); err != nil {
+		t.Fatal(err)
+	}
+	for _, krn := range []kernel.Kernel{krnA, krnB} {
+		if err := krn.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer krnB.Stop(context.Background())
+
+	configsA.Update(&config.Config{
+		Modules:  map[string]map[string]interface{}{"a": {"level": 1}},
+		Timeouts: config.TimeoutsConfig{ConfigChange: 1},
+	})
+	if recA.count("module:a:onconfigchanged") != 1 || recB.count("module:b:onconfigchanged") != 0 {
+		t.Fatalf("events A = %v, B = %v", recA.events, recB.events)
+	}
+
+	// A stopped kernel no longer follows its manager
+	if err := krnA.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	configsA.Update(&config.Config{
+		Modules:  map[string]map[string]interface{}{"a": {"level": 2}},
+		Timeouts: config.TimeoutsConfig{ConfigChange: 1},
+	})
+	if n := recA.count("module:a:onconfigchanged"); n != 1 {
+		t.Errorf("stopped kernel notified %d times", n)
+	}
+}
+
+func TestKernel_ConfigChangesReachOnlyTheirComponent(t *testing.T) {
+	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
+		id: "test-kernel", pType: "system", roles: []string{"kernel.module.*"},
+	})
+	rec := &recorder{}
+	configs := config.NewStaticManager(&config.Config{
+		Modules:  map[string]map[string]interface{}{"a": {"level": 1}, "b": {"level": 1}},
+		Timeouts: config.TimeoutsConfig{ConfigChange: 1},
+	})
+	krn := kernel.New(configs, nil)
+	for _, name := range []string{"a", "b"} {
+		if err := krn.AddModule(ctx, &recModule{name: name, rec: rec}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := krn.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer krn.Stop(context.Background())
+
+	report, err := configs.Update(&config.Config{
+		Environment: "staging", // Global settings are read from the snapshot, nobody is notified
+		Modules:     map[string]map[string]interface{}{"a": {"level": 2, "mode": "safe"}, "b": {"level": 1}},
+		Timeouts:    config.TimeoutsConfig{ConfigChange: 1},
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if rec.count("module:a:onconfigchanged") != 1 || rec.count("module:b:onconfigchanged") != 0 {
+		t.Fatalf("events = %v", rec.events)
+	}
+	if len(report.Components) != 1 || report.Components[0] != (config.ComponentResult{Component: "modules.a"}) {
+		t.Errorf("report = %s", report)
+	}
+	if rec.count("module:a:changed:level") != 1 || rec.count("module:a:changed:mode") != 1 {
+		t.Errorf("change set paths should be relative to the section: %v", rec.events)
+	}
+}
+
+func TestKernel_RejectsInvalidConfigChange(t *testing.T) {
+	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
+		id: "test-kernel", pType: "system", roles: []string{"kernel.module.*"},
+	})
+	rec := &recorder{}
+	configs := config.NewStaticManager(&config.Config{
+		Modules:  map[string]map[string]interface{}{"typed": {"workers": 2}},
+		Timeouts: config.TimeoutsConfig{ConfigChange: 1},
+	})
+	krn := kernel.New(configs, nil)
+	m := &typedModule{recModule: &recModule{name: "typed", rec: rec}}
+	if err := krn.AddModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := krn.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer krn.Stop(context.Background())
+
+	// The invalid section is rejected before anything is applied
+	_, err := configs.Update(&config.Config{
+		Modules:  map[string]map[string]interface{}{"typed": {"workers": 0}},
+		Timeouts: config.TimeoutsConfig{ConfigChange: 1},
+	})
+	if err == nil || !strings.Contains(err.Error(), "modules.typed.workers: must be at least 1") {
+		t.Fatalf("Update = %v, want the validation error", err)
+	}
+	if configs.Current().Modules["typed"]["workers"] != 2 || rec.count("module:typed:onconfigchanged") != 0 {
+		t.Errorf("rejected config was applied: events = %v", rec.events)
+	}
+
+	// A valid one reaches the module, decoded
+	if _, err := configs.Update(&config.Config{
+		Modules:  map[string]map[string]interface{}{"typed": {"workers": 4}},
+		Timeouts: config.TimeoutsConfig{ConfigChange: 1},
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if rec.count("module:typed:changed:workers") != 1 {
+		t.Errorf("events = %v", rec.events)
+	}
+}
+
+// stuckModule's Start ignores its context and only returns once released.
+type stuckModule struct {
+	*recModule
+	release chan struct{}
+}
+
+func (m *stuckModule) Start(ctx context.Context) error {
+	<-m.release
+	return m.recModule.Start(ctx)
+}
+
+func TestKernel_PhaseTimeouts(t *testing.T) {
+	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
+		id: "test-kernel", pType: "system", roles: []string{"kernel.module.*"},
+	})
+	rec := &recorder{}
+	krn := kernel.New(config.NewStaticManager(&config.Config{
+		Timeouts: config.TimeoutsConfig{
+			ModuleOperation: 10,
+			Modules:         map[string]config.PhaseTimeouts{"stuck": {Start: 50 * time.Millisecond}},
+		},
+	}), nil)
+	m := &stuckModule{recModule: &recModule{name: "stuck", rec: rec}, release: make(chan struct{})}
+	defer close(m.release)
+	if err := krn.AddModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	timeouts := metrics.LifecycleTimeoutCounter.WithLabelValues("module", "stuck", "start")
+	before := testutil.ToFloat64(timeouts)
+	began := time.Now()
+	err := krn.Start(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "Start timed out after 50ms") {
+		t.Fatalf("Start = %v, want the module's start timeout", err)
+	}
+	if elapsed := time.Since(began); elapsed > 5*time.Second {
+		t.Errorf("Start took %s, the override was not applied", elapsed)
+	}
+	if got := testutil.ToFloat64(timeouts); got != before+1 {
+		t.Errorf("timeouts counted = %v, want %v", got, before+1)
+	}
+}
+
+// slowConfigModule's Configure only returns once released.
+type slowConfigModule struct {
+	*recModule
+	release chan struct{}
+}
+
+func (m *slowConfigModule) Configure(cfg interface{}) error {
+	<-m.release
+	return m.recModule.Configure(cfg)
+}
+
+func TestKernel_ConfigureOverrunsTimeout(t *testing.T) {
+	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
+		id: "test-kernel", pType: "system", roles: []string{"kernel.module.*"},
+	})
+	rec := &recorder{}
+	krn := kernel.New(config.NewStaticManager(&config.Config{
+		Modules: map[string]map[string]interface{}{"slow": {"level": 1}},
+		Timeouts: config.TimeoutsConfig{
+			ModuleOperation: 10,
+			Modules:         map[string]config.PhaseTimeouts{"slow": {Configure: 50 * time.Millisecond}},
+		},
+	}), nil)
+	m := &slowConfigModule{recModule: &recModule{name: "slow", rec: rec}, release: make(chan struct{})}
+
+	err := krn.AddModule(ctx, m)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "Configure timed out after 50ms") {
+		t.Fatalf("AddModule = %v, want the configure timeout", err)
+	}
+
+	// The abandoned Configure is still running, so the module cannot be brought back yet
+	err = krn.AddModule(ctx, m)
+	if err == nil || !strings.Contains(err.Error(), "Configure still running after timing out") {
+		t.Fatalf("AddModule during the abandoned Configure = %v, want it refused", err)
+	}
+	if n := rec.count("module:slow:onload"); n != 1 {
+		t.Errorf("OnLoad ran %d times, want 1: lifecycle calls overlapped the abandoned Configure", n)
+	}
+
+	close(m.release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if err = krn.AddModule(ctx, m); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("AddModule after the abandoned Configure returned: %v", err)
+	}
+	if err := krn.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer krn.Stop(context.Background())
+	if !m.started {
+		t.Error("module should start once its Configure has returned")
+	}
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func TestKernel_Security_RequiresPrincipal(t *testing.T) {
-	krn := kernel.New(&config.Config{}, nil)
+	krn := kernel.New(config.NewStaticManager(&config.Config{}), nil)
 
 	// Test AddModule without principal should fail
 	err := krn.AddModule(context.Background(), &recModule{name: "test", rec: &recorder{}})
@@ -60,7 +60,7 @@ func (d *denyModuleAccessController) HasPermission(p auth.Principal, perm auth.P
 
 func TestKernel_Security_InsufficientPermissions(t *testing.T) {
 	// Use a custom access controller that denies module permissions
-	krn := kernel.New(&config.Config{}, &denyModuleAccessController{})
+	krn := kernel.New(config.NewStaticManager(&config.Config{}), &denyModuleAccessController{})
 
 	// Test with principal that doesn't have required permissions
 	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
@@ -76,7 +76,7 @@ func TestKernel_Security_InsufficientPermissions(t *testing.T) {
 }
 
 func TestKernel_Security_EnableDisablePermissions(t *testing.T) {
-	krn := kernel.New(&config.Config{}, nil)
+	krn := kernel.New(config.NewStaticManager(&config.Config{}), nil)
 
 	// First add a module with proper permissions
 	ctx := auth.ContextWithPrincipal(context.Background(), &testPrincipal{
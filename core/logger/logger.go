@@ -57,7 +57,7 @@ func WithComponentName(ctx context.Context, componentName string) context.Contex
 // Info checks permission before logging
 func Info(ctx context.Context, msg string, fields ...zap.Field) {
 	componentName := getComponentNameFromContext(ctx)
-	principal := auth.NewDefaultPrincipal(componentName, "component", []string{}) // Create a principal
+	principal := auth.ComponentPrincipal(componentName) // Shared per component, no allocation per call
 	if globalAccessController != nil && !globalAccessController.CanLog(ctx, principal) {
 		return // Not authorized to log
 	}
@@ -67,7 +67,7 @@ func Info(ctx context.Context, msg string, fields ...zap.Field) {
 // Warn checks permission before logging
 func Warn(ctx context.Context, msg string, fields ...zap.Field) {
 	componentName := getComponentNameFromContext(ctx)
-	principal := auth.NewDefaultPrincipal(componentName, "component", []string{}) // Create a principal
+	principal := auth.ComponentPrincipal(componentName) // Shared per component, no allocation per call
 	if globalAccessController != nil && !globalAccessController.CanLog(ctx, principal) {
 		return // Not authorized to log
 	}
@@ -77,7 +77,7 @@ func Warn(ctx context.Context, msg string, fields ...zap.Field) {
 // Error checks permission before logging
 func Error(ctx context.Context, msg string, fields ...zap.Field) {
 	componentName := getComponentNameFromContext(ctx)
-	principal := auth.NewDefaultPrincipal(componentName, "component", []string{}) // Create a principal
+	principal := auth.ComponentPrincipal(componentName) // Shared per component, no allocation per call
 	if globalAccessController != nil && !globalAccessController.CanLog(ctx, principal) {
 		return // Not authorized to log
 	}
@@ -87,7 +87,7 @@ func Error(ctx context.Context, msg string, fields ...zap.Field) {
 // Fatal checks permission before logging
 func Fatal(ctx context.Context, msg string, fields ...zap.Field) {
 	componentName := getComponentNameFromContext(ctx)
-	principal := auth.NewDefaultPrincipal(componentName, "component", []string{}) // Create a principal
+	principal := auth.ComponentPrincipal(componentName) // Shared per component, no allocation per call
 	if globalAccessController != nil && !globalAccessController.CanLog(ctx, principal) {
 		return // Not authorized to log
 	}
@@ -97,7 +97,7 @@ func Fatal(ctx context.Context, msg string, fields ...zap.Field) {
 // Debug checks permission before logging
 func Debug(ctx context.Context, msg string, fields ...zap.Field) {
 	componentName := getComponentNameFromContext(ctx)
-	principal := auth.NewDefaultPrincipal(componentName, "component", []string{}) // Create a principal
+	principal := auth.ComponentPrincipal(componentName) // Shared per component, no allocation per call
 	if globalAccessController != nil && !globalAccessController.CanLog(ctx, principal) {
 		return // Not authorized to log
 	}
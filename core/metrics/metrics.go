@@ -64,6 +64,26 @@ var (
 		Name: "acacia_gateway_stops_total",
 		Help: "Total number of gateway stop attempts.",
 	}, []string{"gateway", "status"})
+
+	// LifecycleTimeoutCounter counts module and gateway lifecycle calls that did not return
+	// within their timeout, by phase (see config.Phase).
+	LifecycleTimeoutCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "acacia_lifecycle_timeouts_total",
+		Help: "Total number of module and gateway lifecycle calls that exceeded their timeout.",
+	}, []string{"type", "component", "phase"})
+
+	// ServiceCallCounter counts calls made through registry service proxies.
+	ServiceCallCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "acacia_service_calls_total",
+		Help: "Total number of inter-module service calls made through registry proxies.",
+	}, []string{"service", "module", "method", "status"})
+
+	// ServiceCallDuration measures the latency of calls made through registry service proxies.
+	ServiceCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "acacia_service_call_duration_seconds",
+		Help:    "Duration of inter-module service calls in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "module", "method"})
 )
 
 // Wrapper functions for controlled access to metrics
@@ -71,7 +91,7 @@ var (
 // IncrementRequestCounter safely increments the request counter with permission check
 func IncrementRequestCounter(ctx context.Context, handler, method string) {
 	componentName := getComponentNameFromContext(ctx)
-	principal := auth.NewDefaultPrincipal(componentName, "component", []string{}) // Create a principal
+	principal := auth.ComponentPrincipal(componentName) // Shared per component, no allocation per call
 	if globalAccessController != nil && !globalAccessController.CanAccessMetrics(ctx, principal) {
 		return // Not authorized
 	}
@@ -81,13 +101,13 @@ func IncrementRequestCounter(ctx context.Context, handler, method string) {
 // ObserveRequestDuration safely observes request duration with permission check
 func ObserveRequestDuration(ctx context.Context, handler, method string, duration float64) {
 	componentName := getComponentNameFromContext(ctx)
-	principal := auth.NewDefaultPrincipal(componentName, "component", []string{}) // Create a principal
+	principal := auth.ComponentPrincipal(componentName) // Shared per component, no allocation per call
 	if globalAccessController != nil && !globalAccessController.CanAccessMetrics(ctx, principal) {
 		return // Not authorized
 	}
 	RequestDuration.WithLabelValues(handler, method).Observe(duration)
 }
 
-// Note: ModuleStartCounter, ModuleStopCounter, GatewayStartCounter, and GatewayStopCounter
-// are used internally by the kernel and remain as direct access for now.
+// Note: ModuleStartCounter, ModuleStopCounter, GatewayStartCounter, GatewayStopCounter and the
+// service call metrics are used internally by the kernel and registry and remain as direct access for now.
 // In a more complete implementation, we might want to restrict these as well.
@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"acacia/core/auth"
+)
+
+// ServiceInfo describes one registered service provider, for debugging and tooling.
+type ServiceInfo struct {
+	Name         string            `json:"name"`
+	ModuleName   string            `json:"module"`
+	Type         string            `json:"type"`               // Go type of the registered value, e.g. "*scores.Board"
+	Contract     string            `json:"contract,omitempty"` // Declared contract type, if any
+	Version      string            `json:"version,omitempty"`
+	Priority     int               `json:"priority,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	RegisteredAt time.Time         `json:"registered_at"`
+}
+
+// GatewayInfo describes one registered gateway.
+type GatewayInfo struct {
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// ListServices returns every service provider the principal in ctx is authorized to access,
+// ordered by name and then as GetService would prefer them. Providers the principal may not
+// access are omitted rather than reported as errors.
+func (r *DefaultRegistry) ListServices(ctx context.Context) ([]ServiceInfo, error) {
+	if auth.PrincipalFromContext(ctx) == nil {
+		return nil, fmt.Errorf("no principal found in context for service listing")
+	}
+
+	r.mu.RLock()
+	names := make([]string, 0, len(r.services))
+	for name := range r.services {
+		names = append(names, name)
+	}
+	services := make(map[string][]serviceEntry, len(r.services))
+	for name, entries := range r.services {
+		services[name] = entries // Copy-on-write slices can be read after unlocking
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	var infos []ServiceInfo
+	for _, name := range names {
+		accessible, err := r.accessibleEntries(ctx, name, services[name])
+		if err != nil {
+			continue // Not authorized for any provider of this service
+		}
+		for _, entry := range accessible {
+			info := ServiceInfo{
+				Name:         name,
+				ModuleName:   entry.moduleName,
+				Type:         typeName(entry.service),
+				Version:      entry.versionString(),
+				Priority:     entry.priority,
+				Metadata:     copyMetadata(entry.metadata),
+				RegisteredAt: entry.registeredAt,
+			}
+			if entry.contract != nil {
+				info.Contract = entry.contract.String()
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// ListGateways returns every gateway the principal in ctx is authorized to access, ordered by name.
+func (r *DefaultRegistry) ListGateways(ctx context.Context) ([]GatewayInfo, error) {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil, fmt.Errorf("no principal found in context for gateway listing")
+	}
+
+	r.mu.RLock()
+	var infos []GatewayInfo
+	for name, entry := range r.gateways {
+		if !r.accessController.HasPermission(p, GatewayPermission(name)) {
+			continue
+		}
+		infos = append(infos, GatewayInfo{
+			Name:         name,
+			Type:         typeName(entry.service),
+			RegisteredAt: entry.registeredAt,
+		})
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// typeName returns the Go type of v as a string, e.g. "*scores.Board".
+func typeName(v interface{}) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return reflect.TypeOf(v).String()
+}
@@ -0,0 +1,226 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"acacia/core/auth"
+	"acacia/core/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrCallDenied is returned by service proxies when the caller may not invoke a method.
+var ErrCallDenied = errors.New("service call denied")
+
+// ProxyFactory builds a typed proxy for a service. target is the resolved service and invoker
+// wraps each call with authorization, metrics and tracing. Typed proxies are usually generated
+// or hand-written wrappers implementing the service's interface, e.g.
+//
+//	func(target interface{}, inv *registry.Invoker) interface{} {
+//		return &scoresProxy{target: target.(Scores), inv: inv}
+//	}
+//
+//	func (p *scoresProxy) Top(ctx context.Context, n int) (res []Score, err error) {
+//		err = p.inv.Invoke(ctx, "Top", func(ctx context.Context) error {
+//			res, err = p.target.Top(ctx, n)
+//			return err
+//		})
+//		return res, err
+//	}
+type ProxyFactory func(target interface{}, invoker *Invoker) interface{}
+
+// MethodPermission returns the permission required to call method on a service owned by moduleName.
+// Convention: "service.<module_name>.<service_name>.call.<method>"
+func MethodPermission(moduleName, name, method string) auth.Permission {
+	return auth.Permission(fmt.Sprintf("service.%s.%s.call.%s", moduleName, name, method))
+}
+
+// Invoker authorizes, measures and traces individual calls to one service provider.
+type Invoker struct {
+	name       string
+	moduleName string
+	ac         auth.AccessController
+}
+
+// Service returns the name of the service the invoker calls.
+func (i *Invoker) Service() string { return i.name }
+
+// Module returns the name of the module providing the service.
+func (i *Invoker) Module() string { return i.moduleName }
+
+// Invoke runs call on behalf of the principal in ctx if it holds the method's permission.
+// Each call gets its own span, and its outcome and latency are recorded in core/metrics.
+// The context passed to call carries the span.
+func (i *Invoker) Invoke(ctx context.Context, method string, call func(ctx context.Context) error) (err error) {
+	start := time.Now()
+	tracer := otel.Tracer("acacia-registry")
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("Service.Call: %s.%s", i.name, method), trace.WithAttributes(
+		attribute.String("service.name", i.name),
+		attribute.String("service.module", i.moduleName),
+		attribute.String("service.method", method),
+	))
+	defer span.End()
+
+	status := "panic" // Overwritten unless call panics
+	defer func() {
+		metrics.ServiceCallCounter.WithLabelValues(i.name, i.moduleName, method, status).Inc()
+		if status != "denied" {
+			metrics.ServiceCallDuration.WithLabelValues(i.name, i.moduleName, method).Observe(time.Since(start).Seconds())
+		}
+	}()
+
+	if err := i.authorize(ctx, method); err != nil {
+		status = "denied"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	err = call(ctx)
+	if err != nil {
+		status = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	status = "ok"
+	return nil
+}
+
+// authorize checks that the principal in ctx may call method.
+func (i *Invoker) authorize(ctx context.Context, method string) error {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return fmt.Errorf("no principal found in context for call to %s.%s: %w", i.name, method, ErrCallDenied)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("principal.id", p.ID()), attribute.String("principal.type", p.Type()))
+
+	permission := MethodPermission(i.moduleName, i.name, method)
+	if !i.ac.HasPermission(p, permission) {
+		return fmt.Errorf("principal %s (type: %s) may not call %s.%s (missing permission: %s): %w", p.ID(), p.Type(), i.name, method, permission, ErrCallDenied)
+	}
+	return nil
+}
+
+// ServiceProxy is the reflective proxy returned by GetServiceProxy for services without a
+// registered ProxyFactory. Methods are invoked by name through Call.
+type ServiceProxy struct {
+	target  reflect.Value
+	invoker *Invoker
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Call invokes the exported method of the service with the given arguments. If the method takes
+// a context.Context as its first parameter, it must be omitted from args; the call's traced
+// context is passed instead. A trailing error result is returned as Call's error, and the
+// remaining results are returned in order.
+func (p *ServiceProxy) Call(ctx context.Context, method string, args ...interface{}) ([]interface{}, error) {
+	m := p.target.MethodByName(method)
+	if !m.IsValid() {
+		return nil, fmt.Errorf("service '%s' has no method %s", p.invoker.name, method)
+	}
+
+	var results []interface{}
+	err := p.invoker.Invoke(ctx, method, func(ctx context.Context) error {
+		in, err := callArgs(ctx, m.Type(), args)
+		if err != nil {
+			return fmt.Errorf("call %s.%s: %w", p.invoker.name, method, err)
+		}
+		out := m.Call(in)
+		if n := len(out); n > 0 && m.Type().Out(n-1) == errorType {
+			callErr, _ := out[n-1].Interface().(error)
+			out = out[:n-1]
+			if callErr != nil {
+				return callErr
+			}
+		}
+		results = make([]interface{}, len(out))
+		for i, v := range out {
+			results[i] = v.Interface()
+		}
+		return nil
+	})
+	return results, err
+}
+
+// callArgs converts args to the parameters of a method of type t, prepending ctx if the
+// method expects a context. Nil arguments become the parameter's zero value.
+func callArgs(ctx context.Context, t reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	var in []reflect.Value
+	offset := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		in = append(in, reflect.ValueOf(ctx))
+		offset = 1
+	}
+
+	fixed := t.NumIn() - offset
+	if t.IsVariadic() {
+		fixed--
+		if len(args) < fixed {
+			return nil, fmt.Errorf("expected at least %d arguments, got %d", fixed, len(args))
+		}
+	} else if len(args) != fixed {
+		return nil, fmt.Errorf("expected %d arguments, got %d", fixed, len(args))
+	}
+
+	for i, arg := range args {
+		var pt reflect.Type
+		if i < fixed {
+			pt = t.In(i + offset)
+		} else {
+			pt = t.In(t.NumIn() - 1).Elem() // Variadic element type
+		}
+		if arg == nil {
+			in = append(in, reflect.Zero(pt))
+			continue
+		}
+		v := reflect.ValueOf(arg)
+		if !v.Type().AssignableTo(pt) {
+			return nil, fmt.Errorf("argument %d is %s, not assignable to %s", i, v.Type(), pt)
+		}
+		in = append(in, v)
+	}
+	return in, nil
+}
+
+// RegisterProxyFactory installs the factory GetServiceProxy uses to build typed proxies for
+// the named service, replacing any previous factory. A nil factory restores the reflective proxy.
+func (r *DefaultRegistry) RegisterProxyFactory(name string, factory ProxyFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if factory == nil {
+		delete(r.proxyFactories, name)
+		return
+	}
+	r.proxyFactories[name] = factory
+}
+
+// GetServiceProxy resolves the named service like GetService, including the lookup-time access
+// check, and wraps the provider in a proxy that authorizes, measures and traces every call.
+func (r *DefaultRegistry) GetServiceProxy(ctx context.Context, name string) (interface{}, error) {
+	entry, err := r.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	invoker := &Invoker{name: name, moduleName: entry.moduleName, ac: r.accessController}
+
+	r.mu.RLock()
+	factory := r.proxyFactories[name]
+	r.mu.RUnlock()
+	if factory != nil {
+		return factory(entry.service, invoker), nil
+	}
+	return &ServiceProxy{target: reflect.ValueOf(entry.service), invoker: invoker}, nil
+}
@@ -2,36 +2,143 @@ package registry
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
+	"time"
 
 	"acacia/core/auth" // Import the auth package
+
+	"github.com/Masterminds/semver/v3" // For service version constraints
 )
 
 // Registry defines the interface for a centralized service discovery mechanism.
 type Registry interface {
 	RegisterService(name string, service interface{}, moduleName string) error
+	// RegisterServiceWithContract registers a service and records the contract type it is
+	// declared to satisfy. Registration fails if the service does not implement the contract.
+	RegisterServiceWithContract(name string, service interface{}, contract reflect.Type, moduleName string) error
+	// RegisterServiceWithOptions registers a service with a semantic version, metadata and contract.
+	// Several versions of the same service name may be registered side by side.
+	RegisterServiceWithOptions(name string, service interface{}, moduleName string, opts ServiceOptions) error
 	// GetService retrieves a registered service by its name, performing an access check.
 	// The context must contain a Principal for the access check to be effective.
 	GetService(ctx context.Context, name string) (interface{}, error)
+	// GetServiceVersion retrieves the highest registered version of a service that satisfies
+	// the semver constraint (e.g. ">= 2.0, < 3"), performing the same access check as GetService.
+	GetServiceVersion(ctx context.Context, name string, constraint string) (interface{}, error)
+	// GetAll returns every accessible provider of a service, for fanning calls out.
+	GetAll(ctx context.Context, name string) ([]interface{}, error)
+	// SetResolutionStrategy sets how GetService picks among several providers of a service.
+	SetResolutionStrategy(name string, strategy Strategy) error
+	// ServiceVersions describes every registered version of a service, newest first.
+	ServiceVersions(ctx context.Context, name string) ([]ServiceVersion, error)
+	// GetServicesByType returns all services the principal in ctx may access whose value
+	// satisfies the given type. Services the principal is not authorized for are skipped.
+	GetServicesByType(ctx context.Context, contract reflect.Type) (map[string]interface{}, error)
 	UnregisterService(name string)
+	// UnregisterServiceVersion unregisters a single version of a service.
+	UnregisterServiceVersion(name string, version string)
 	UnregisterServicesByModule(moduleName string)
+	// Watch streams registered, unregistered and replaced changes for the named service
+	// (or every service if name is empty) until ctx is done. The context must contain a Principal.
+	Watch(ctx context.Context, name string) (<-chan ServiceChange, error)
+	// WatchModule streams changes for every service owned by moduleName until ctx is done.
+	WatchModule(ctx context.Context, moduleName string) (<-chan ServiceChange, error)
+	// GetServiceProxy resolves a service like GetService but returns a proxy that authorizes,
+	// measures and traces every call. It is the typed proxy built by the factory registered with
+	// RegisterProxyFactory if there is one, and a *ServiceProxy otherwise.
+	GetServiceProxy(ctx context.Context, name string) (interface{}, error)
+	// RegisterProxyFactory installs the factory used to build typed proxies for a service.
+	RegisterProxyFactory(name string, factory ProxyFactory)
+	// ListServices describes every registered service provider the principal in ctx may access.
+	ListServices(ctx context.Context) ([]ServiceInfo, error)
+	// ListGateways describes every registered gateway the principal in ctx may access.
+	ListGateways(ctx context.Context) ([]GatewayInfo, error)
 	GetGateway(ctx context.Context, name string) (interface{}, error)
 	RegisterGateway(name string, gateway interface{}) error
 	UnregisterGateway(name string)
 }
 
+var (
+	// ErrNotFound is returned when no service or gateway is registered under the requested name.
+	ErrNotFound = errors.New("not found")
+	// ErrContractViolation is returned when a service does not satisfy its declared contract.
+	ErrContractViolation = errors.New("service does not satisfy contract")
+	// ErrTypeMismatch is returned by typed lookups when the registered service is not of the requested type.
+	ErrTypeMismatch = errors.New("service type mismatch")
+	// ErrNoMatchingVersion is returned when no registered version satisfies a version constraint.
+	ErrNoMatchingVersion = errors.New("no matching service version")
+)
+
+// ServiceOptions describes how a service is registered.
+type ServiceOptions struct {
+	// Version is the semantic version of the service contract. Empty means unversioned.
+	Version string
+	// Metadata holds optional free-form information about the service (e.g. protocol, region).
+	Metadata map[string]string
+	// Contract is the type the service is declared to satisfy. Nil skips the contract check.
+	Contract reflect.Type
+	// Replace overwrites an existing registration with the same name and version instead of
+	// failing. Watchers receive a ServiceReplaced change.
+	Replace bool
+	// MultiProvider allows several modules to provide the same service name and version.
+	// Every provider of that name and version must opt in.
+	MultiProvider bool
+	// Priority orders providers of the same version for StrategyPriority; higher wins.
+	Priority int
+}
+
+// ServiceVersion describes one registered version of a service by one provider.
+type ServiceVersion struct {
+	Version    string            `json:"version,omitempty"`
+	ModuleName string            `json:"module"`
+	Priority   int               `json:"priority,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
 // DefaultRegistry is a concrete implementation of the Registry interface.
 type DefaultRegistry struct {
-	services         map[string]serviceEntry
+	services         map[string][]serviceEntry // All versions of each service, newest first
 	mu               sync.RWMutex
 	accessController auth.AccessController   // New field
 	gateways         map[string]serviceEntry // Add a map for gateways
+
+	watchMu sync.Mutex // Protects watchers and listeners; never held together with mu
+
+	queueMu    sync.Mutex      // Protects queued and delivering; taken while mu is held to order changes
+	queued     []ServiceChange // Changes awaiting delivery, in the order they were applied
+	delivering bool            // Whether a goroutine is delivering queued changes
+	watchers   map[*watcher]struct{}
+	listeners  []func(ServiceChange)
+
+	strategyMu  sync.Mutex // Protects strategies, counters and healthCheck
+	strategies  map[string]Strategy
+	counters    map[string]uint64 // Round-robin position per service name
+	healthCheck HealthCheckFunc
+
+	proxyFactories map[string]ProxyFactory // Typed proxy factories by service name, protected by mu
 }
 
 type serviceEntry struct {
-	service    interface{}
-	moduleName string
+	service      interface{}
+	moduleName   string
+	contract     reflect.Type    // Declared contract type, nil if registered untyped
+	version      *semver.Version // Service version, nil if registered unversioned
+	metadata     map[string]string
+	multi        bool // Registered with ServiceOptions.MultiProvider
+	priority     int
+	registeredAt time.Time
+}
+
+// versionString returns the entry's original version string, or "" if unversioned.
+func (e serviceEntry) versionString() string {
+	if e.version == nil {
+		return ""
+	}
+	return e.version.Original()
 }
 
 // NewDefaultRegistry creates a new instance of DefaultRegistry.
@@ -43,28 +150,137 @@ func NewDefaultRegistry(ac auth.AccessController) *DefaultRegistry {
 		ac = auth.NewDefaultAccessController(nil)
 	}
 	return &DefaultRegistry{
-		services:         make(map[string]serviceEntry),
+		services:         make(map[string][]serviceEntry),
 		gateways:         make(map[string]serviceEntry), // Initialize gateways map
 		accessController: ac,
+		watchers:         make(map[*watcher]struct{}),
+		strategies:       make(map[string]Strategy),
+		counters:         make(map[string]uint64),
+		proxyFactories:   make(map[string]ProxyFactory),
 	}
 }
 
 // RegisterService registers a service with a given name and the module it belongs to.
 func (r *DefaultRegistry) RegisterService(name string, service interface{}, moduleName string) error {
+	return r.RegisterServiceWithContract(name, service, nil, moduleName)
+}
+
+// RegisterServiceWithContract registers a service together with the contract type it is declared
+// to satisfy. A nil contract behaves like RegisterService.
+func (r *DefaultRegistry) RegisterServiceWithContract(name string, service interface{}, contract reflect.Type, moduleName string) error {
+	return r.RegisterServiceWithOptions(name, service, moduleName, ServiceOptions{Contract: contract})
+}
+
+// RegisterServiceWithOptions registers a service with a semantic version, metadata and contract.
+// Registering the same name and version twice is an error, but different versions of the same
+// service may coexist, e.g. while consumers migrate from one major version to the next.
+func (r *DefaultRegistry) RegisterServiceWithOptions(name string, service interface{}, moduleName string, opts ServiceOptions) error {
+	if opts.Contract != nil {
+		if err := checkContract(service, opts.Contract); err != nil {
+			return fmt.Errorf("register service '%s': %w", name, err)
+		}
+	}
+
+	entry := serviceEntry{
+		service:      service,
+		moduleName:   moduleName,
+		contract:     opts.Contract,
+		metadata:     copyMetadata(opts.Metadata),
+		multi:        opts.MultiProvider,
+		priority:     opts.Priority,
+		registeredAt: time.Now(),
+	}
+	if opts.Version != "" {
+		v, err := semver.NewVersion(opts.Version)
+		if err != nil {
+			return fmt.Errorf("service '%s' has invalid version %q: %w", name, opts.Version, err)
+		}
+		entry.version = v
+	}
+
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	changeType := ServiceRegistered
+	var kept []serviceEntry
+	for _, existing := range r.services[name] {
+		if !sameVersion(existing.version, entry.version) {
+			kept = append(kept, existing)
+			continue
+		}
+		if existing.moduleName != entry.moduleName && existing.multi && entry.multi {
+			// Another module provides the same service; both opted into multiple providers.
+			kept = append(kept, existing)
+			continue
+		}
+		if !opts.Replace {
+			r.mu.Unlock()
+			if entry.version == nil {
+				return fmt.Errorf("service with name '%s' already registered", name)
+			}
+			return fmt.Errorf("service with name '%s' version %s already registered", name, entry.version.Original())
+		}
+		changeType = ServiceReplaced
+	}
+
+	// kept is a fresh slice, so readers holding the previous one after releasing the lock are unaffected.
+	entries := append(kept, entry)
+	sortEntries(entries)
+	r.services[name] = entries
+	r.enqueue([]ServiceChange{changeFor(changeType, name, entry)})
+	r.mu.Unlock()
+
+	r.notify()
+	return nil
+}
+
+// sameVersion reports whether two possibly nil versions are equal.
+func sameVersion(a, b *semver.Version) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(b)
+}
 
-	if _, exists := r.services[name]; exists {
-		return fmt.Errorf("service with name '%s' already registered", name)
+// sortEntries orders entries newest version first, with unversioned entries last.
+// Providers of the same version are ordered by descending priority, then registration order.
+func sortEntries(entries []serviceEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		vi, vj := entries[i].version, entries[j].version
+		if sameVersion(vi, vj) {
+			return entries[i].priority > entries[j].priority
+		}
+		if vi == nil || vj == nil {
+			return vj == nil && vi != nil
+		}
+		return vi.GreaterThan(vj)
+	})
+}
+
+// copyMetadata returns a copy of md so callers cannot mutate registered metadata.
+func copyMetadata(md map[string]string) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(md))
+	for k, v := range md {
+		out[k] = v
 	}
+	return out
+}
 
-	// Check if the service is a kernel.Gateway. If so, register it in the gateways map.
-	// This is a simplified check; a more robust solution might involve a dedicated RegisterGateway method
-	// or a type assertion against a kernel.Gateway interface. For now, we'll assume
-	// that if a service is intended to be a gateway, it will be retrieved via GetGateway.
-	r.services[name] = serviceEntry{
-		service:    service,
-		moduleName: moduleName,
+// checkContract verifies that service is a non-nil value satisfying contract.
+func checkContract(service interface{}, contract reflect.Type) error {
+	if service == nil {
+		return fmt.Errorf("nil service for contract %s: %w", contract, ErrContractViolation)
+	}
+	t := reflect.TypeOf(service)
+	if contract.Kind() == reflect.Interface {
+		if !t.Implements(contract) {
+			return fmt.Errorf("%s does not implement %s: %w", t, contract, ErrContractViolation)
+		}
+		return nil
+	}
+	if !t.AssignableTo(contract) {
+		return fmt.Errorf("%s is not assignable to %s: %w", t, contract, ErrContractViolation)
 	}
 	return nil
 }
@@ -79,8 +295,9 @@ func (r *DefaultRegistry) RegisterGateway(name string, gateway interface{}) erro
 	}
 
 	r.gateways[name] = serviceEntry{
-		service:    gateway,
-		moduleName: name, // For gateways, the moduleName is typically the gateway name itself
+		service:      gateway,
+		moduleName:   name, // For gateways, the moduleName is typically the gateway name itself
+		registeredAt: time.Now(),
 	}
 	return nil
 }
@@ -94,56 +311,241 @@ func (r *DefaultRegistry) UnregisterGateway(name string) {
 
 // GetService retrieves a registered service by its name, performing an access check.
 // The context must contain a Principal for the access check to be effective.
+// When several versions are registered, the newest one is returned. When several modules
+// provide that version, one is picked using the service's resolution strategy.
 func (r *DefaultRegistry) GetService(ctx context.Context, name string) (interface{}, error) {
+	entry, err := r.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return entry.service, nil
+}
+
+// lookup resolves the provider of the newest version of name that GetService would return.
+func (r *DefaultRegistry) lookup(ctx context.Context, name string) (serviceEntry, error) {
 	r.mu.RLock()
-	entry, exists := r.services[name]
+	entries := r.services[name]
 	r.mu.RUnlock() // Release read lock before potentially calling access controller
 
-	if !exists {
-		return nil, fmt.Errorf("service with name '%s' not found", name)
+	if len(entries) == 0 {
+		return serviceEntry{}, fmt.Errorf("service with name '%s' %w", name, ErrNotFound)
+	}
+	return r.resolve(ctx, name, newestProviders(entries))
+}
+
+// GetServiceVersion retrieves the highest registered version of a service that satisfies constraint.
+// Unversioned registrations never satisfy a constraint.
+func (r *DefaultRegistry) GetServiceVersion(ctx context.Context, name string, constraint string) (interface{}, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q for service '%s': %w", constraint, name, err)
+	}
+
+	r.mu.RLock()
+	entries := r.services[name]
+	r.mu.RUnlock()
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("service with name '%s' %w", name, ErrNotFound)
+	}
+
+	var matching []serviceEntry
+	for _, entry := range entries {
+		if entry.version != nil && c.Check(entry.version) {
+			matching = append(matching, entry)
+		}
+	}
+	if len(matching) > 0 {
+		entry, err := r.resolve(ctx, name, newestProviders(matching))
+		if err != nil {
+			return nil, err
+		}
+		return entry.service, nil
 	}
+	return nil, fmt.Errorf("service '%s' has no version matching %q: %w", name, constraint, ErrNoMatchingVersion)
+}
 
+// ServiceVersions describes every registered version of a service the principal in ctx may
+// access, newest first. Like GetAll, it fails only if none of them is accessible.
+func (r *DefaultRegistry) ServiceVersions(ctx context.Context, name string) ([]ServiceVersion, error) {
+	r.mu.RLock()
+	entries := append([]serviceEntry(nil), r.services[name]...)
+	r.mu.RUnlock()
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("service with name '%s' %w", name, ErrNotFound)
+	}
+
+	accessible, err := r.accessibleEntries(ctx, name, entries)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]ServiceVersion, 0, len(accessible))
+	for _, entry := range accessible {
+		versions = append(versions, ServiceVersion{
+			Version:    entry.versionString(),
+			ModuleName: entry.moduleName,
+			Priority:   entry.priority,
+			Metadata:   copyMetadata(entry.metadata),
+		})
+	}
+	return versions, nil
+}
+
+// checkServiceAccess verifies that the principal in ctx may access the given service entry.
+func (r *DefaultRegistry) checkServiceAccess(ctx context.Context, name string, entry serviceEntry) error {
 	// Extract principal from context
 	p := auth.PrincipalFromContext(ctx)
 	if p == nil {
 		// If no principal is in context, deny access for security.
-		return nil, fmt.Errorf("no principal found in context for service access check for '%s'", name)
+		return fmt.Errorf("no principal found in context for service access check for '%s'", name)
 	}
 
 	// Define the permission required to access this service
 	// Convention: "service.<module_name>.<service_name>.access"
-	permission := auth.Permission(fmt.Sprintf("service.%s.%s.access", entry.moduleName, name))
+	permission := ServicePermission(entry.moduleName, name)
 
 	// Perform the access check
 	if !r.accessController.HasPermission(p, permission) {
-		return nil, fmt.Errorf("principal %s (type: %s) is not authorized to access service '%s' (missing permission: %s)", p.ID(), p.Type(), name, permission)
+		return fmt.Errorf("principal %s (type: %s) is not authorized to access service '%s' (missing permission: %s)", p.ID(), p.Type(), name, permission)
 	}
+	return nil
+}
 
-	return entry.service, nil
+// GetServicesByType returns all services whose value satisfies contract and that the principal
+// in ctx is authorized to access, keyed by service name. For each name the newest accessible
+// matching version wins, and among its providers the service's resolution strategy picks one.
+func (r *DefaultRegistry) GetServicesByType(ctx context.Context, contract reflect.Type) (map[string]interface{}, error) {
+	if contract == nil {
+		return nil, fmt.Errorf("contract type is nil")
+	}
+
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil, fmt.Errorf("no principal found in context for service lookup by type %s", contract)
+	}
+
+	r.mu.RLock()
+	candidates := make(map[string][]serviceEntry)
+	for name, entries := range r.services {
+		for _, entry := range entries {
+			if checkContract(entry.service, contract) == nil {
+				candidates[name] = append(candidates[name], entry)
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	result := make(map[string]interface{}, len(candidates))
+	for name, entries := range candidates {
+		accessible, err := r.accessibleEntries(ctx, name, entries)
+		if err != nil {
+			continue // None of the providers is accessible
+		}
+		entry, err := r.resolve(ctx, name, newestProviders(accessible))
+		if err != nil {
+			continue // E.g. no healthy provider
+		}
+		result[name] = entry.service
+	}
+	return result, nil
+}
+
+// ServicePermission returns the permission required to access a service owned by moduleName.
+// Convention: "service.<module_name>.<service_name>.access"
+func ServicePermission(moduleName, name string) auth.Permission {
+	return auth.Permission(fmt.Sprintf("service.%s.%s.access", moduleName, name))
 }
 
-// UnregisterService unregisters a service by its name.
+// GatewayPermission returns the permission required to access a gateway.
+// Convention: "gateway.<gateway_name>.access"
+func GatewayPermission(name string) auth.Permission {
+	return auth.Permission(fmt.Sprintf("gateway.%s.access", name))
+}
+
+// UnregisterService unregisters all versions of a service by its name.
 func (r *DefaultRegistry) UnregisterService(name string) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	removed := r.services[name]
 	delete(r.services, name)
+	changes := make([]ServiceChange, 0, len(removed))
+	for _, entry := range removed {
+		changes = append(changes, changeFor(ServiceUnregistered, name, entry))
+	}
+	r.enqueue(changes)
+	r.mu.Unlock()
+	r.forgetPositions([]string{name})
+
+	r.notify()
+}
+
+// UnregisterServiceVersion unregisters a single version of a service. An empty version
+// removes the unversioned registration.
+func (r *DefaultRegistry) UnregisterServiceVersion(name string, version string) {
+	var target *semver.Version
+	if version != "" {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			return
+		}
+		target = v
+	}
+
+	r.mu.Lock()
+	var kept []serviceEntry
+	var changes []ServiceChange
+	for _, entry := range r.services[name] {
+		if sameVersion(entry.version, target) {
+			changes = append(changes, changeFor(ServiceUnregistered, name, entry))
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == 0 {
+		delete(r.services, name)
+	} else {
+		r.services[name] = kept
+	}
+	r.enqueue(changes)
+	r.mu.Unlock()
+	if len(kept) == 0 {
+		r.forgetPositions([]string{name})
+	}
+
+	r.notify()
 }
 
 // UnregisterServicesByModule unregisters all services associated with a given module name.
 func (r *DefaultRegistry) UnregisterServicesByModule(moduleName string) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	for name, entry := range r.services {
-		if entry.moduleName == moduleName {
+	var changes []ServiceChange
+	var emptied []string
+	for name, entries := range r.services {
+		var kept []serviceEntry
+		for _, entry := range entries {
+			if entry.moduleName == moduleName {
+				changes = append(changes, changeFor(ServiceUnregistered, name, entry))
+			} else {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == 0 {
 			delete(r.services, name)
+			emptied = append(emptied, name)
+		} else {
+			r.services[name] = kept
 		}
 	}
 	for name, entry := range r.gateways {
 		if entry.moduleName == moduleName {
-			r.UnregisterGateway(name) // Use the new UnregisterGateway method
+			delete(r.gateways, name) // The lock is already held, so UnregisterGateway cannot be used here
 		}
 	}
+	r.enqueue(changes)
+	r.mu.Unlock()
+	r.forgetPositions(emptied)
+
+	r.notify()
 }
 
 // GetGateway retrieves a registered gateway by its name, performing an access check.
@@ -153,7 +555,7 @@ func (r *DefaultRegistry) GetGateway(ctx context.Context, name string) (interfac
 	r.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("gateway with name '%s' not found", name)
+		return nil, fmt.Errorf("gateway with name '%s' %w", name, ErrNotFound)
 	}
 
 	p := auth.PrincipalFromContext(ctx)
@@ -161,8 +563,7 @@ func (r *DefaultRegistry) GetGateway(ctx context.Context, name string) (interfac
 		return nil, fmt.Errorf("no principal found in context for gateway access check for '%s'", name)
 	}
 
-	// Convention: "gateway.<gateway_name>.access"
-	permission := auth.Permission(fmt.Sprintf("gateway.%s.access", name))
+	permission := GatewayPermission(name)
 
 	if !r.accessController.HasPermission(p, permission) {
 		return nil, fmt.Errorf("principal %s (type: %s) is not authorized to access gateway '%s' (missing permission: %s)", p.ID(), p.Type(), name, permission)
@@ -0,0 +1,560 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"acacia/core/auth"
+	"acacia/core/metrics"
+	"acacia/core/registry"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type greeter interface {
+	Greet() string
+}
+
+type englishGreeter struct{}
+
+func (englishGreeter) Greet() string { return "hello" }
+
+type notAGreeter struct{}
+
+func adminContext() context.Context {
+	return auth.ContextWithPrincipal(context.Background(), auth.NewDefaultPrincipal("test", "user", []string{"admin"}))
+}
+
+func TestTypedRegisterAndGet(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+
+	if err := registry.Register[greeter](reg, "greeter", englishGreeter{}, "greetings"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	g, err := registry.Get[greeter](adminContext(), reg, "greeter")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if g.Greet() != "hello" {
+		t.Errorf("Greet() = %q, want hello", g.Greet())
+	}
+
+	if _, err := registry.Get[*notAGreeter](adminContext(), reg, "greeter"); !errors.Is(err, registry.ErrTypeMismatch) {
+		t.Errorf("Get with wrong type: got %v, want ErrTypeMismatch", err)
+	}
+}
+
+func TestRegisterServiceWithContract_Violation(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+
+	err := reg.RegisterServiceWithContract("bad", notAGreeter{}, reflect.TypeOf((*greeter)(nil)).Elem(), "greetings")
+	if !errors.Is(err, registry.ErrContractViolation) {
+		t.Fatalf("RegisterServiceWithContract: got %v, want ErrContractViolation", err)
+	}
+	if _, err := reg.GetService(adminContext(), "bad"); err == nil {
+		t.Error("service violating its contract should not be registered")
+	}
+}
+
+func TestTypedList(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+	_ = registry.Register[greeter](reg, "en", englishGreeter{}, "greetings")
+	_ = reg.RegisterService("other", notAGreeter{}, "misc")
+
+	greeters, err := registry.List[greeter](adminContext(), reg)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(greeters) != 1 || greeters["en"] == nil {
+		t.Errorf("List() = %v, want only en", greeters)
+	}
+
+	if _, err := registry.List[greeter](context.Background(), reg); err == nil {
+		t.Error("List without principal should fail")
+	}
+}
+
+type versionedGreeter struct{ greeting string }
+
+func (g versionedGreeter) Greet() string { return g.greeting }
+
+func TestVersionedServices(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+	for _, v := range []string{"1.4.0", "2.0.0", "2.3.1"} {
+		err := reg.RegisterServiceWithOptions("payments", versionedGreeter{greeting: v}, "payments", registry.ServiceOptions{
+			Version:  v,
+			Metadata: map[string]string{"protocol": "grpc"},
+		})
+		if err != nil {
+			t.Fatalf("register %s: %v", v, err)
+		}
+	}
+
+	if err := reg.RegisterServiceWithOptions("payments", versionedGreeter{}, "payments", registry.ServiceOptions{Version: "2.0.0"}); err == nil {
+		t.Error("registering a duplicate version should fail")
+	}
+	if err := reg.RegisterServiceWithOptions("payments", versionedGreeter{}, "payments", registry.ServiceOptions{Version: "not-a-version"}); err == nil {
+		t.Error("registering an invalid version should fail")
+	}
+
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{">= 2.0", "2.3.1"},
+		{"~2.0.0", "2.0.0"},
+		{"< 2", "1.4.0"},
+	}
+	for _, tt := range tests {
+		g, err := registry.GetVersion[greeter](adminContext(), reg, "payments", tt.constraint)
+		if err != nil {
+			t.Fatalf("GetVersion(%q): %v", tt.constraint, err)
+		}
+		if g.Greet() != tt.want {
+			t.Errorf("GetVersion(%q) = %s, want %s", tt.constraint, g.Greet(), tt.want)
+		}
+	}
+
+	if _, err := reg.GetServiceVersion(adminContext(), "payments", ">= 3"); !errors.Is(err, registry.ErrNoMatchingVersion) {
+		t.Errorf("GetServiceVersion(>= 3): got %v, want ErrNoMatchingVersion", err)
+	}
+
+	latest, err := registry.Get[greeter](adminContext(), reg, "payments")
+	if err != nil || latest.Greet() != "2.3.1" {
+		t.Errorf("Get() = %v, %v; want newest version 2.3.1", latest, err)
+	}
+
+	versions, err := reg.ServiceVersions(adminContext(), "payments")
+	if err != nil {
+		t.Fatalf("ServiceVersions: %v", err)
+	}
+	if len(versions) != 3 || versions[0].Version != "2.3.1" || versions[0].Metadata["protocol"] != "grpc" {
+		t.Errorf("ServiceVersions() = %+v", versions)
+	}
+
+	reg.UnregisterServiceVersion("payments", "2.3.1")
+	latest, err = registry.Get[greeter](adminContext(), reg, "payments")
+	if err != nil || latest.Greet() != "2.0.0" {
+		t.Errorf("after unregistering 2.3.1, Get() = %v, %v; want 2.0.0", latest, err)
+	}
+}
+
+func receiveChange(t *testing.T, ch <-chan registry.ServiceChange) registry.ServiceChange {
+	t.Helper()
+	select {
+	case c := <-ch:
+		return c
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for registry change")
+		return registry.ServiceChange{}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+	ctx, cancel := context.WithCancel(adminContext())
+
+	byName, err := reg.Watch(ctx, "matchmaker")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	byModule, err := reg.WatchModule(ctx, "lobby")
+	if err != nil {
+		t.Fatalf("WatchModule: %v", err)
+	}
+	if _, err := reg.Watch(context.Background(), "matchmaker"); err == nil {
+		t.Error("Watch without principal should fail")
+	}
+
+	_ = reg.RegisterService("matchmaker", englishGreeter{}, "lobby")
+	_ = reg.RegisterServiceWithOptions("matchmaker", englishGreeter{}, "lobby", registry.ServiceOptions{Replace: true})
+	_ = reg.RegisterService("chat", englishGreeter{}, "social")
+	reg.UnregisterServicesByModule("lobby")
+
+	for _, want := range []registry.ChangeType{registry.ServiceRegistered, registry.ServiceReplaced, registry.ServiceUnregistered} {
+		if c := receiveChange(t, byName); c.Type != want || c.Name != "matchmaker" {
+			t.Errorf("Watch: got %+v, want %s of matchmaker", c, want)
+		}
+		if c := receiveChange(t, byModule); c.Type != want || c.ModuleName != "lobby" {
+			t.Errorf("WatchModule: got %+v, want %s in lobby", c, want)
+		}
+	}
+
+	cancel()
+	for range byName {
+		// drain until closed
+	}
+}
+
+func TestWatch_ConcurrentChangesArriveInOrder(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+	ctx, cancel := context.WithCancel(adminContext())
+	defer cancel()
+	changes, err := reg.Watch(ctx, "matchmaker")
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	// A slow listener widens the window between applying a change and delivering it
+	reg.OnChange(func(registry.ServiceChange) { time.Sleep(100 * time.Microsecond) })
+
+	// 4 goroutines x 7 iterations x 2 changes, plus the final registration, fit the watcher's
+	// buffer, so nothing is dropped
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 7; i++ {
+				_ = reg.RegisterServiceWithOptions("matchmaker", englishGreeter{}, "lobby", registry.ServiceOptions{Replace: true})
+				reg.UnregisterService("matchmaker")
+			}
+		}()
+	}
+	wg.Wait()
+	_ = reg.RegisterServiceWithOptions("matchmaker", englishGreeter{}, "lobby", registry.ServiceOptions{Replace: true})
+
+	registered := false
+	for done := false; !done; {
+		select {
+		case c := <-changes:
+			switch c.Type {
+			case registry.ServiceRegistered:
+				if registered {
+					t.Fatal("registered twice without an unregistration in between")
+				}
+				registered = true
+			case registry.ServiceReplaced:
+				if !registered {
+					t.Fatal("replaced a service that was not registered")
+				}
+			case registry.ServiceUnregistered:
+				if !registered {
+					t.Fatal("unregistered a service that was not registered")
+				}
+				registered = false
+			}
+		default:
+			done = true
+		}
+	}
+	if !registered {
+		t.Error("watcher ended with the service unregistered, but it is registered")
+	}
+}
+
+func TestOnChange_ListenerMayCallRegistry(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+	ctx, cancel := context.WithCancel(adminContext())
+	defer cancel()
+
+	var seen []string
+	reg.OnChange(func(c registry.ServiceChange) {
+		seen = append(seen, c.Name)
+		if c.Name == "matchmaker" && c.Type == registry.ServiceRegistered {
+			if _, err := reg.Watch(ctx, "chat"); err != nil {
+				t.Errorf("Watch from listener: %v", err)
+			}
+			_ = reg.RegisterService("chat", englishGreeter{}, "social")
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		_ = reg.RegisterService("matchmaker", englishGreeter{}, "lobby")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RegisterService deadlocked on a listener that calls back into the registry")
+	}
+	if !reflect.DeepEqual(seen, []string{"matchmaker", "chat"}) {
+		t.Errorf("listener saw %v, want [matchmaker chat]", seen)
+	}
+}
+
+func TestRoundRobinRestartsForReregisteredService(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+	if err := reg.SetResolutionStrategy("shard-7", registry.StrategyRoundRobin); err != nil {
+		t.Fatalf("SetResolutionStrategy: %v", err)
+	}
+	register := func() {
+		for _, module := range []string{"shard-a", "shard-b"} {
+			err := reg.RegisterServiceWithOptions("shard-7", versionedGreeter{greeting: module}, module, registry.ServiceOptions{MultiProvider: true})
+			if err != nil {
+				t.Fatalf("register %s: %v", module, err)
+			}
+		}
+	}
+	greet := func() string {
+		g, err := registry.Get[greeter](adminContext(), reg, "shard-7")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		return g.Greet()
+	}
+
+	register()
+	if got := greet(); got != "shard-a" {
+		t.Fatalf("first lookup picked %s, want shard-a", got)
+	}
+	// Once the last provider is gone the service's position is forgotten
+	reg.UnregisterServicesByModule("shard-a")
+	reg.UnregisterServicesByModule("shard-b")
+	register()
+	if got := greet(); got != "shard-a" {
+		t.Errorf("first lookup after re-registering picked %s, want shard-a", got)
+	}
+	greet()
+	reg.UnregisterService("shard-7")
+	register()
+	if got := greet(); got != "shard-a" {
+		t.Errorf("first lookup after UnregisterService picked %s, want shard-a", got)
+	}
+}
+
+func TestGetServicesByType_MultipleProviders(t *testing.T) {
+	ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+		{Name: "player", Permissions: []auth.Permission{"service.mm-b.matchmaker.access"}},
+		{Name: "admin", Permissions: []auth.Permission{"service.mm-a.matchmaker.access", "service.mm-b.matchmaker.access"}},
+	}))
+	reg := registry.NewDefaultRegistry(ac)
+	// mm-a has the higher priority, so it is the first provider, but the player may only use mm-b
+	for module, priority := range map[string]int{"mm-a": 5, "mm-b": 1} {
+		err := reg.RegisterServiceWithOptions("matchmaker", versionedGreeter{greeting: module}, module, registry.ServiceOptions{
+			MultiProvider: true,
+			Priority:      priority,
+		})
+		if err != nil {
+			t.Fatalf("register %s: %v", module, err)
+		}
+	}
+	ctx := auth.ContextWithPrincipal(context.Background(), auth.NewDefaultPrincipal("p1", "user", []string{"player"}))
+
+	services, err := reg.GetServicesByType(ctx, reflect.TypeOf((*greeter)(nil)).Elem())
+	if err != nil {
+		t.Fatalf("GetServicesByType: %v", err)
+	}
+	g, ok := services["matchmaker"].(greeter)
+	if !ok || g.Greet() != "mm-b" {
+		t.Errorf("GetServicesByType = %v, want the accessible mm-b provider", services)
+	}
+
+	services, err = reg.GetServicesByType(adminContext(), reflect.TypeOf((*greeter)(nil)).Elem())
+	if err != nil {
+		t.Fatalf("GetServicesByType: %v", err)
+	}
+	if g, ok := services["matchmaker"].(greeter); !ok || g.Greet() != "mm-a" {
+		t.Errorf("GetServicesByType = %v, want the highest priority provider mm-a", services)
+	}
+}
+
+func TestMultipleProviders(t *testing.T) {
+	reg := registry.NewDefaultRegistry(nil)
+	providers := []struct {
+		module   string
+		priority int
+	}{{"mm-a", 1}, {"mm-b", 5}, {"mm-c", 3}}
+	for _, p := range providers {
+		err := reg.RegisterServiceWithOptions("matchmaker", versionedGreeter{greeting: p.module}, p.module, registry.ServiceOptions{
+			MultiProvider: true,
+			Priority:      p.priority,
+		})
+		if err != nil {
+			t.Fatalf("register %s: %v", p.module, err)
+		}
+	}
+	if err := reg.RegisterService("matchmaker", versionedGreeter{}, "mm-d"); err == nil {
+		t.Error("a provider that did not opt into MultiProvider should be rejected")
+	}
+
+	greet := func() string {
+		g, err := registry.Get[greeter](adminContext(), reg, "matchmaker")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		return g.Greet()
+	}
+
+	if got := greet(); got != "mm-b" {
+		t.Errorf("priority strategy picked %s, want mm-b", got)
+	}
+
+	if err := reg.SetResolutionStrategy("matchmaker", registry.StrategyRoundRobin); err != nil {
+		t.Fatalf("SetResolutionStrategy: %v", err)
+	}
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		seen[greet()]++
+	}
+	if len(seen) != 3 || seen["mm-a"] != 2 {
+		t.Errorf("round robin distribution = %v, want each provider twice", seen)
+	}
+
+	reg.SetHealthCheck(func(ctx context.Context, moduleName string, service interface{}) bool {
+		return moduleName != "mm-b"
+	})
+	_ = reg.SetResolutionStrategy("matchmaker", registry.StrategyHealthAware)
+	if got := greet(); got != "mm-c" {
+		t.Errorf("health-aware strategy picked %s, want mm-c", got)
+	}
+	reg.SetHealthCheck(func(ctx context.Context, moduleName string, service interface{}) bool { return false })
+	if _, err := reg.GetService(adminContext(), "matchmaker"); !errors.Is(err, registry.ErrNoHealthyProvider) {
+		t.Errorf("GetService with no healthy providers: got %v, want ErrNoHealthyProvider", err)
+	}
+
+	if err := reg.SetResolutionStrategy("matchmaker", "fastest"); err == nil {
+		t.Error("unknown strategy should be rejected")
+	}
+
+	all, err := registry.GetAllTyped[greeter](adminContext(), reg, "matchmaker")
+	if err != nil {
+		t.Fatalf("GetAllTyped: %v", err)
+	}
+	if len(all) != 3 || all[0].Greet() != "mm-b" {
+		t.Errorf("GetAllTyped() = %v, want 3 providers with mm-b first", all)
+	}
+}
+
+type scoreBoard struct{}
+
+func (scoreBoard) Top(ctx context.Context, n int) ([]string, error) {
+	if n < 0 {
+		return nil, errors.New("negative count")
+	}
+	return []string{"alice", "bob"}[:n], nil
+}
+
+func (scoreBoard) Reset(ctx context.Context) error { return nil }
+
+type scoreBoardProxy struct {
+	target scoreBoard
+	inv    *registry.Invoker
+}
+
+func (p *scoreBoardProxy) Greet() string { return "proxied " + p.inv.Service() }
+
+func TestServiceVersions_SkipsInaccessibleEntries(t *testing.T) {
+	ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+		{Name: "player", Permissions: []auth.Permission{"service.payments-v2.payments.access"}},
+	}))
+	reg := registry.NewDefaultRegistry(ac)
+	for module, version := range map[string]string{"payments-v1": "1.0.0", "payments-v2": "2.0.0"} {
+		if err := reg.RegisterServiceWithOptions("payments", versionedGreeter{}, module, registry.ServiceOptions{Version: version}); err != nil {
+			t.Fatalf("register %s: %v", version, err)
+		}
+	}
+	if err := reg.RegisterService("ledger", versionedGreeter{}, "payments-v1"); err != nil {
+		t.Fatalf("register ledger: %v", err)
+	}
+	ctx := auth.ContextWithPrincipal(context.Background(), auth.NewDefaultPrincipal("p1", "user", []string{"player"}))
+
+	versions, err := reg.ServiceVersions(ctx, "payments")
+	if err != nil {
+		t.Fatalf("ServiceVersions: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Version != "2.0.0" {
+		t.Errorf("ServiceVersions() = %+v, want only the accessible 2.0.0", versions)
+	}
+	if _, err := reg.ServiceVersions(ctx, "ledger"); err == nil {
+		t.Error("ServiceVersions should fail when no entry is accessible")
+	}
+}
+
+func TestServiceProxy(t *testing.T) {
+	ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+		{Name: "reader", Permissions: []auth.Permission{"service.scores.board.access", "service.scores.board.call.Top"}},
+	}))
+	reg := registry.NewDefaultRegistry(ac)
+	if err := reg.RegisterService("board", scoreBoard{}, "scores"); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	ctx := auth.ContextWithPrincipal(context.Background(), auth.NewDefaultPrincipal("p1", "user", []string{"reader"}))
+
+	svc, err := reg.GetServiceProxy(ctx, "board")
+	if err != nil {
+		t.Fatalf("GetServiceProxy: %v", err)
+	}
+	proxy, ok := svc.(*registry.ServiceProxy)
+	if !ok {
+		t.Fatalf("GetServiceProxy returned %T, want *ServiceProxy", svc)
+	}
+
+	before := testutil.ToFloat64(metrics.ServiceCallCounter.WithLabelValues("board", "scores", "Top", "ok"))
+	out, err := proxy.Call(ctx, "Top", 1)
+	if err != nil {
+		t.Fatalf("Call(Top): %v", err)
+	}
+	if top := out[0].([]string); len(top) != 1 || top[0] != "alice" {
+		t.Errorf("Call(Top) = %v, want [alice]", out)
+	}
+	if got := testutil.ToFloat64(metrics.ServiceCallCounter.WithLabelValues("board", "scores", "Top", "ok")); got != before+1 {
+		t.Errorf("ok call counter = %v, want %v", got, before+1)
+	}
+
+	if _, err := proxy.Call(ctx, "Top", -1); err == nil || errors.Is(err, registry.ErrCallDenied) {
+		t.Errorf("Call(Top, -1): got %v, want the service's error", err)
+	}
+	if _, err := proxy.Call(ctx, "Reset"); !errors.Is(err, registry.ErrCallDenied) {
+		t.Errorf("Call(Reset) without permission: got %v, want ErrCallDenied", err)
+	}
+	if _, err := proxy.Call(ctx, "Top", "one"); err == nil {
+		t.Error("Call with a mistyped argument should fail")
+	}
+
+	reg.RegisterProxyFactory("board", func(target interface{}, inv *registry.Invoker) interface{} {
+		return &scoreBoardProxy{target: target.(scoreBoard), inv: inv}
+	})
+	g, err := registry.GetProxy[greeter](ctx, reg, "board")
+	if err != nil {
+		t.Fatalf("GetProxy: %v", err)
+	}
+	if g.Greet() != "proxied board" {
+		t.Errorf("typed proxy Greet() = %q", g.Greet())
+	}
+}
+
+func TestListServicesAndGateways(t *testing.T) {
+	ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+		{Name: "viewer", Permissions: []auth.Permission{"service.greetings.*", "gateway.http.access"}},
+	}))
+	reg := registry.NewDefaultRegistry(ac)
+	if err := reg.RegisterServiceWithOptions("greeter", englishGreeter{}, "greetings", registry.ServiceOptions{Version: "1.0.0"}); err != nil {
+		t.Fatalf("RegisterServiceWithOptions: %v", err)
+	}
+	if err := reg.RegisterService("board", scoreBoard{}, "scores"); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	_ = reg.RegisterGateway("http", struct{}{})
+	_ = reg.RegisterGateway("ws", struct{}{})
+
+	if _, err := reg.ListServices(context.Background()); err == nil {
+		t.Error("ListServices without a principal should fail")
+	}
+
+	ctx := auth.ContextWithPrincipal(context.Background(), auth.NewDefaultPrincipal("dev", "user", []string{"viewer"}))
+	services, err := reg.ListServices(ctx)
+	if err != nil {
+		t.Fatalf("ListServices: %v", err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("ListServices() = %+v, want only the greeter", services)
+	}
+	info := services[0]
+	if info.Name != "greeter" || info.ModuleName != "greetings" || info.Version != "1.0.0" || info.Type != "registry_test.englishGreeter" || info.RegisteredAt.IsZero() {
+		t.Errorf("unexpected service info %+v", info)
+	}
+
+	gateways, err := reg.ListGateways(ctx)
+	if err != nil {
+		t.Fatalf("ListGateways: %v", err)
+	}
+	if len(gateways) != 1 || gateways[0].Name != "http" {
+		t.Errorf("ListGateways() = %+v, want only http", gateways)
+	}
+}
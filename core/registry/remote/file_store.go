@@ -0,0 +1,159 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// fileLockRetry is how long FileStore waits between attempts to acquire its lock file.
+	fileLockRetry = 10 * time.Millisecond
+	// fileLockStale is the age after which a leftover lock file is assumed to belong to a crashed process.
+	fileLockStale = 10 * time.Second
+)
+
+// FileStore is a Store backed by a JSON file. Several processes on the same host (or sharing a
+// network filesystem) can use the same path; updates are serialized through a lock file and
+// written atomically via rename.
+type FileStore struct {
+	path string
+	now  func() time.Time
+}
+
+// NewFileStore creates a FileStore that persists advertisements at path.
+// The parent directory is created if it does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create registry store directory: %w", err)
+	}
+	return &FileStore{path: path, now: time.Now}, nil
+}
+
+// Put creates or refreshes an advertisement.
+func (s *FileStore) Put(ctx context.Context, ad Advertisement) error {
+	return s.update(ctx, func(ads map[string]Advertisement) {
+		ads[ad.key()] = ad
+	})
+}
+
+// Delete removes an advertisement.
+func (s *FileStore) Delete(ctx context.Context, ad Advertisement) error {
+	return s.update(ctx, func(ads map[string]Advertisement) {
+		delete(ads, ad.key())
+	})
+}
+
+// List returns the unexpired advertisements for name, or all if name is empty.
+func (s *FileStore) List(ctx context.Context, name string) ([]Advertisement, error) {
+	ads, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	var result []Advertisement
+	for _, ad := range ads {
+		if ad.expired(now) {
+			continue
+		}
+		if name == "" || ad.Name == name {
+			result = append(result, ad)
+		}
+	}
+	sortAdvertisements(result)
+	return result, nil
+}
+
+// update applies fn to the stored advertisements under the file lock, dropping expired entries.
+func (s *FileStore) update(ctx context.Context, fn func(map[string]Advertisement)) error {
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ads, err := s.read()
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for k, ad := range ads {
+		if ad.expired(now) {
+			delete(ads, k)
+		}
+	}
+	fn(ads)
+	return s.write(ads)
+}
+
+// read loads the advertisements from disk. A missing file yields an empty set.
+func (s *FileStore) read() (map[string]Advertisement, error) {
+	ads := make(map[string]Advertisement)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ads, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read registry store %s: %w", s.path, err)
+	}
+	if len(data) == 0 {
+		return ads, nil
+	}
+	var list []Advertisement
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse registry store %s: %w", s.path, err)
+	}
+	for _, ad := range list {
+		ads[ad.key()] = ad
+	}
+	return ads, nil
+}
+
+// write atomically replaces the store file with ads.
+func (s *FileStore) write(ads map[string]Advertisement) error {
+	list := make([]Advertisement, 0, len(ads))
+	for _, ad := range ads {
+		list = append(list, ad)
+	}
+	sortAdvertisements(list)
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal registry store: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write registry store %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replace registry store %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// lock acquires the store's lock file, waiting until ctx is done.
+func (s *FileStore) lock(ctx context.Context) (func(), error) {
+	lockPath := s.path + ".lock"
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("lock registry store %s: %w", s.path, err)
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && s.now().Sub(info.ModTime()) > fileLockStale {
+			os.Remove(lockPath) // Left behind by a crashed process
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("lock registry store %s: %w", s.path, ctx.Err())
+		case <-time.After(fileLockRetry):
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"acacia/core/auth"
+	"acacia/core/logger"
+	"acacia/core/registry"
+
+	"github.com/Masterminds/semver/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTTL is how long an advertisement stays valid without a heartbeat.
+	DefaultTTL = 30 * time.Second
+	// storeTimeout bounds each store write made for this node's advertisements.
+	storeTimeout = 5 * time.Second
+)
+
+// Options configures a remote Registry.
+type Options struct {
+	// NodeID uniquely identifies this Acacia instance among the nodes sharing the store.
+	NodeID string
+	// Endpoint is where other nodes can reach the services offered by this node.
+	Endpoint Endpoint
+	// TTL is how long advertisements remain valid. Heartbeats refresh them every TTL/3.
+	// Zero means DefaultTTL.
+	TTL time.Duration
+}
+
+// RemoteService is returned by lookups for services offered by another node. The service itself
+// lives in the other process; callers use Endpoint to reach it.
+type RemoteService struct {
+	NodeID     string
+	Name       string
+	ModuleName string
+	Version    string
+	Endpoint   Endpoint
+	Metadata   map[string]string
+}
+
+// Registry is a registry.Registry that advertises locally registered services through a Store
+// and falls back to services advertised by other nodes when a lookup finds nothing locally.
+// Local services always take precedence. Watches and gateways are local to the node.
+type Registry struct {
+	*registry.DefaultRegistry
+	store Store
+	opts  Options
+	ac    auth.AccessController
+
+	mu      sync.Mutex
+	ads     map[string]Advertisement // Advertisements owned by this node, by key
+	pending []storeWrite             // Local changes not yet written to the store, in order
+	writing bool                     // Whether a writer goroutine is draining pending
+	idle    *sync.Cond               // Signalled on mu when pending has been drained
+	cancel  context.CancelFunc       // Stops the heartbeat loop, nil when not started
+	done    chan struct{}
+	now     func() time.Time
+
+	// writeMu serializes store writes so a heartbeat refresh cannot overtake a withdrawal.
+	writeMu sync.Mutex
+}
+
+// storeWrite is a queued advertisement update.
+type storeWrite struct {
+	ad     Advertisement
+	change registry.ChangeType
+}
+
+// NewRegistry creates a remote Registry for the node described by opts.
+func NewRegistry(ac auth.AccessController, store Store, opts Options) (*Registry, error) {
+	if store == nil {
+		return nil, fmt.Errorf("remote registry requires a store")
+	}
+	if opts.NodeID == "" {
+		return nil, fmt.Errorf("remote registry requires a node ID")
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if ac == nil {
+		ac = auth.NewDefaultAccessController(nil)
+	}
+
+	r := &Registry{
+		DefaultRegistry: registry.NewDefaultRegistry(ac),
+		store:           store,
+		opts:            opts,
+		ac:              ac,
+		ads:             make(map[string]Advertisement),
+		now:             time.Now,
+	}
+	r.idle = sync.NewCond(&r.mu)
+	r.DefaultRegistry.OnChange(r.onLocalChange)
+	return r, nil
+}
+
+// NodeID returns the ID this registry advertises its services under.
+func (r *Registry) NodeID() string {
+	return r.opts.NodeID
+}
+
+// Start begins refreshing this node's advertisements every TTL/3 until Stop is called.
+func (r *Registry) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return fmt.Errorf("remote registry for node '%s' already started", r.opts.NodeID)
+	}
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.heartbeat(loopCtx, r.done)
+	return nil
+}
+
+// Stop halts heartbeats and withdraws this node's advertisements from the store.
+func (r *Registry) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	r.Flush()
+
+	r.mu.Lock()
+	ads := make([]Advertisement, 0, len(r.ads))
+	for _, ad := range r.ads {
+		ads = append(ads, ad)
+	}
+	r.mu.Unlock()
+
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	var errs []error
+	for _, ad := range ads {
+		if err := r.store.Delete(ctx, ad); err != nil {
+			errs = append(errs, fmt.Errorf("withdraw service '%s': %w", ad.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// heartbeat re-publishes this node's advertisements until ctx is done.
+func (r *Registry) heartbeat(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(r.opts.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.refresh(ctx)
+		}
+	}
+}
+
+// refresh extends the expiry of every advertisement owned by this node. Each advertisement is
+// re-checked under the write lock, so one withdrawn since the refresh began is not re-published.
+func (r *Registry) refresh(ctx context.Context) {
+	r.mu.Lock()
+	keys := make([]string, 0, len(r.ads))
+	for key := range r.ads {
+		keys = append(keys, key)
+	}
+	r.mu.Unlock()
+
+	for _, key := range keys {
+		r.writeMu.Lock()
+		r.mu.Lock()
+		ad, ok := r.ads[key]
+		if ok {
+			ad.ExpiresAt = r.now().Add(r.opts.TTL)
+			r.ads[key] = ad
+		}
+		r.mu.Unlock()
+		if ok {
+			if err := r.store.Put(ctx, ad); err != nil {
+				logger.Warn(ctx, "Failed to refresh service advertisement", zap.String("service", ad.Name), zap.String("node", ad.NodeID), zap.Error(err))
+			}
+		}
+		r.writeMu.Unlock()
+	}
+}
+
+// onLocalChange mirrors local registry changes into the store. It runs as a registry listener,
+// so the store write is queued for a writer goroutine rather than made inline, keeping a slow
+// or unreachable store from stalling registrations.
+func (r *Registry) onLocalChange(change registry.ServiceChange) {
+	ad := Advertisement{
+		NodeID:     r.opts.NodeID,
+		Name:       change.Name,
+		ModuleName: change.ModuleName,
+		Version:    change.Version,
+		Endpoint:   r.opts.Endpoint,
+		Metadata:   change.Metadata,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if change.Type == registry.ServiceUnregistered {
+		delete(r.ads, ad.key())
+	} else {
+		ad.ExpiresAt = r.now().Add(r.opts.TTL)
+		r.ads[ad.key()] = ad
+	}
+	r.pending = append(r.pending, storeWrite{ad: ad, change: change.Type})
+	if !r.writing {
+		r.writing = true
+		go r.drain()
+	}
+}
+
+// drain writes queued advertisement updates to the store in order until none remain.
+func (r *Registry) drain() {
+	for {
+		r.mu.Lock()
+		if len(r.pending) == 0 {
+			r.writing = false
+			r.idle.Broadcast()
+			r.mu.Unlock()
+			return
+		}
+		w := r.pending[0]
+		r.pending = r.pending[1:]
+		r.mu.Unlock()
+
+		r.write(w)
+	}
+}
+
+// write applies a single queued update to the store.
+func (r *Registry) write(w storeWrite) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	var err error
+	if w.change == registry.ServiceUnregistered {
+		err = r.store.Delete(ctx, w.ad)
+	} else {
+		err = r.store.Put(ctx, w.ad)
+	}
+	if err != nil {
+		logger.Error(ctx, "Failed to update service advertisement", zap.String("service", w.ad.Name), zap.String("change", string(w.change)), zap.Error(err))
+	}
+}
+
+// Flush blocks until every local registry change made so far has been written to the store.
+func (r *Registry) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.writing {
+		r.idle.Wait()
+	}
+}
+
+// GetService returns the local service registered under name or, if there is none,
+// a RemoteService describing the newest version offered by another node.
+func (r *Registry) GetService(ctx context.Context, name string) (interface{}, error) {
+	svc, err := r.DefaultRegistry.GetService(ctx, name)
+	if err == nil || !errors.Is(err, registry.ErrNotFound) {
+		return svc, err
+	}
+	remote, remoteErr := r.discover(ctx, name, nil)
+	if remoteErr != nil {
+		return nil, remoteErr
+	}
+	if len(remote) == 0 {
+		return nil, err
+	}
+	return remote[0], nil
+}
+
+// GetServiceVersion returns the newest local version of name satisfying constraint or,
+// if there is none, the newest matching version offered by another node.
+func (r *Registry) GetServiceVersion(ctx context.Context, name string, constraint string) (interface{}, error) {
+	svc, err := r.DefaultRegistry.GetServiceVersion(ctx, name, constraint)
+	if err == nil || !(errors.Is(err, registry.ErrNotFound) || errors.Is(err, registry.ErrNoMatchingVersion)) {
+		return svc, err
+	}
+	c, cErr := semver.NewConstraint(constraint)
+	if cErr != nil {
+		return nil, fmt.Errorf("invalid version constraint %q for service '%s': %w", constraint, name, cErr)
+	}
+	remote, remoteErr := r.discover(ctx, name, c)
+	if remoteErr != nil {
+		return nil, remoteErr
+	}
+	if len(remote) == 0 {
+		return nil, err
+	}
+	return remote[0], nil
+}
+
+// GetAll returns the accessible local providers of name followed by RemoteService values
+// for the providers offered by other nodes.
+func (r *Registry) GetAll(ctx context.Context, name string) ([]interface{}, error) {
+	services, err := r.DefaultRegistry.GetAll(ctx, name)
+	if err != nil && !errors.Is(err, registry.ErrNotFound) {
+		return nil, err
+	}
+	remote, remoteErr := r.discover(ctx, name, nil)
+	if remoteErr != nil {
+		return nil, remoteErr
+	}
+	for _, svc := range remote {
+		services = append(services, svc)
+	}
+	if len(services) == 0 {
+		return nil, err
+	}
+	return services, nil
+}
+
+// Discover lists the services named name offered by other nodes that the principal in ctx
+// may access, newest version first. An empty name lists every remote service.
+func (r *Registry) Discover(ctx context.Context, name string) ([]RemoteService, error) {
+	return r.discover(ctx, name, nil)
+}
+
+// discover queries the store for services offered by other nodes, filtered by access and,
+// if non-nil, by a version constraint.
+func (r *Registry) discover(ctx context.Context, name string, constraint *semver.Constraints) ([]RemoteService, error) {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil, fmt.Errorf("no principal found in context for service access check for '%s'", name)
+	}
+
+	ads, err := r.store.List(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("list remote services '%s': %w", name, err)
+	}
+
+	type candidate struct {
+		svc     RemoteService
+		version *semver.Version
+	}
+	var candidates []candidate
+	for _, ad := range ads {
+		if ad.NodeID == r.opts.NodeID {
+			continue // Local services are served by the embedded registry
+		}
+		if !r.ac.HasPermission(p, registry.ServicePermission(ad.ModuleName, ad.Name)) {
+			continue
+		}
+		var v *semver.Version
+		if ad.Version != "" {
+			if v, err = semver.NewVersion(ad.Version); err != nil {
+				continue // Ignore advertisements with malformed versions
+			}
+		}
+		if constraint != nil && (v == nil || !constraint.Check(v)) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			svc: RemoteService{
+				NodeID:     ad.NodeID,
+				Name:       ad.Name,
+				ModuleName: ad.ModuleName,
+				Version:    ad.Version,
+				Endpoint:   ad.Endpoint,
+				Metadata:   ad.Metadata,
+			},
+			version: v,
+		})
+	}
+
+	// Newest version first, unversioned last; the store's ordering breaks ties.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		vi, vj := candidates[i].version, candidates[j].version
+		switch {
+		case vi == nil:
+			return false
+		case vj == nil:
+			return true
+		default:
+			return vi.GreaterThan(vj)
+		}
+	})
+
+	result := make([]RemoteService, 0, len(candidates))
+	for _, c := range candidates {
+		result = append(result, c.svc)
+	}
+	return result, nil
+}
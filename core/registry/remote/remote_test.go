@@ -0,0 +1,227 @@
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"acacia/core/auth"
+	"acacia/core/registry"
+	"acacia/core/registry/remote"
+)
+
+type scoreService struct{}
+
+func adminContext() context.Context {
+	return auth.ContextWithPrincipal(context.Background(), auth.NewDefaultPrincipal("test", "user", []string{"admin"}))
+}
+
+func newNode(t *testing.T, store remote.Store, id string, ttl time.Duration) *remote.Registry {
+	t.Helper()
+	reg, err := remote.NewRegistry(nil, store, remote.Options{
+		NodeID:   id,
+		Endpoint: remote.Endpoint{Host: id + ".local", Port: 7000, Protocol: "grpc"},
+		TTL:      ttl,
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry(%s): %v", id, err)
+	}
+	return reg
+}
+
+func TestDiscoverAcrossNodes(t *testing.T) {
+	store := remote.NewMemoryStore()
+	a := newNode(t, store, "node-a", time.Minute)
+	b := newNode(t, store, "node-b", time.Minute)
+
+	if err := a.RegisterServiceWithOptions("scores", scoreService{}, "leaderboard", registry.ServiceOptions{Version: "1.2.0"}); err != nil {
+		t.Fatalf("RegisterServiceWithOptions: %v", err)
+	}
+	a.Flush()
+
+	// Node A resolves its own service locally.
+	svc, err := a.GetService(adminContext(), "scores")
+	if err != nil {
+		t.Fatalf("local GetService: %v", err)
+	}
+	if _, ok := svc.(scoreService); !ok {
+		t.Errorf("local GetService returned %T, want scoreService", svc)
+	}
+
+	// Node B only knows about it through the store.
+	svc, err = b.GetService(adminContext(), "scores")
+	if err != nil {
+		t.Fatalf("remote GetService: %v", err)
+	}
+	rs, ok := svc.(remote.RemoteService)
+	if !ok {
+		t.Fatalf("remote GetService returned %T, want RemoteService", svc)
+	}
+	if rs.NodeID != "node-a" || rs.Endpoint.Host != "node-a.local" || rs.Version != "1.2.0" {
+		t.Errorf("unexpected remote service %+v", rs)
+	}
+
+	if _, err := b.GetServiceVersion(adminContext(), "scores", "^2"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("GetServiceVersion(^2): got %v, want ErrNotFound", err)
+	}
+
+	a.UnregisterService("scores")
+	a.Flush()
+	if _, err := b.GetService(adminContext(), "scores"); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("after unregister: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileStoreSharedBetweenNodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	storeA, err := remote.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	storeB, err := remote.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	a := newNode(t, storeA, "node-a", time.Minute)
+	b := newNode(t, storeB, "node-b", time.Minute)
+
+	if err := a.RegisterService("scores", scoreService{}, "leaderboard"); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	a.Flush()
+	found, err := b.Discover(adminContext(), "scores")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if len(found) != 1 || found[0].NodeID != "node-a" {
+		t.Fatalf("Discover = %+v, want one service from node-a", found)
+	}
+
+	if err := a.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if found, _ := b.Discover(adminContext(), "scores"); len(found) != 0 {
+		t.Errorf("Discover after Stop = %+v, want none", found)
+	}
+}
+
+func TestAdvertisementTTL(t *testing.T) {
+	const ttl = 300 * time.Millisecond
+	store := remote.NewMemoryStore()
+	stale := newNode(t, store, "stale", ttl)
+	live := newNode(t, store, "live", ttl)
+	observer := newNode(t, store, "observer", ttl)
+
+	if err := live.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer live.Stop(context.Background())
+
+	if err := stale.RegisterService("stale-svc", scoreService{}, "mod"); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	if err := live.RegisterService("live-svc", scoreService{}, "mod"); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	stale.Flush()
+	live.Flush()
+
+	time.Sleep(2 * ttl)
+
+	if found, _ := observer.Discover(adminContext(), "stale-svc"); len(found) != 0 {
+		t.Errorf("advertisement without heartbeats should expire, got %+v", found)
+	}
+	if found, _ := observer.Discover(adminContext(), "live-svc"); len(found) != 1 {
+		t.Errorf("heartbeats should keep the advertisement alive, got %+v", found)
+	}
+}
+
+func TestDiscoverRequiresPermission(t *testing.T) {
+	store := remote.NewMemoryStore()
+	a := newNode(t, store, "node-a", time.Minute)
+	ac := auth.NewDefaultAccessController(auth.NewConfigRBACProvider([]auth.Role{
+		{Name: "player", Permissions: []auth.Permission{"service.chat.*"}},
+	}))
+	b, err := remote.NewRegistry(ac, store, remote.Options{NodeID: "node-b"})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	if err := a.RegisterService("scores", scoreService{}, "leaderboard"); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	a.Flush()
+	ctx := auth.ContextWithPrincipal(context.Background(), auth.NewDefaultPrincipal("p1", "user", []string{"player"}))
+	if found, _ := b.Discover(ctx, "scores"); len(found) != 0 {
+		t.Errorf("unauthorized principal discovered %+v", found)
+	}
+}
+
+// blockingStore is a MemoryStore whose writes wait until release is closed.
+type blockingStore struct {
+	*remote.MemoryStore
+	release chan struct{}
+}
+
+func (s *blockingStore) Put(ctx context.Context, ad remote.Advertisement) error {
+	<-s.release
+	return s.MemoryStore.Put(ctx, ad)
+}
+
+func (s *blockingStore) Delete(ctx context.Context, ad remote.Advertisement) error {
+	<-s.release
+	return s.MemoryStore.Delete(ctx, ad)
+}
+
+func TestSlowStoreDoesNotBlockRegistration(t *testing.T) {
+	store := &blockingStore{MemoryStore: remote.NewMemoryStore(), release: make(chan struct{})}
+	a := newNode(t, store, "node-a", time.Minute)
+	b := newNode(t, store.MemoryStore, "node-b", time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		_ = a.RegisterService("scores", scoreService{}, "leaderboard")
+		a.UnregisterService("scores")
+		_ = a.RegisterService("chat", scoreService{}, "social")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("registration blocked on a slow store")
+	}
+
+	close(store.release)
+	a.Flush()
+	if found, _ := b.Discover(adminContext(), "scores"); len(found) != 0 {
+		t.Errorf("withdrawn service still advertised: %+v", found)
+	}
+	if found, _ := b.Discover(adminContext(), "chat"); len(found) != 1 {
+		t.Errorf("Discover(chat) = %+v, want one service", found)
+	}
+}
+
+func TestHeartbeatDoesNotRepublishWithdrawnService(t *testing.T) {
+	const ttl = 30 * time.Millisecond
+	store := remote.NewMemoryStore()
+	a := newNode(t, store, "node-a", ttl)
+	b := newNode(t, store, "node-b", ttl)
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer a.Stop(context.Background())
+
+	for i := 0; i < 20; i++ {
+		if err := a.RegisterService("scores", scoreService{}, "leaderboard"); err != nil {
+			t.Fatalf("RegisterService: %v", err)
+		}
+		time.Sleep(ttl / 5)
+		a.UnregisterService("scores")
+		a.Flush()
+		if found, _ := b.Discover(adminContext(), "scores"); len(found) != 0 {
+			t.Fatalf("iteration %d: withdrawn service still advertised: %+v", i, found)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package remote provides a distributed implementation of registry.Registry. Services registered
+// locally are advertised through a pluggable Store with TTL heartbeats, so that Acacia instances
+// sharing the same store can discover services offered by modules running on other nodes.
+package remote
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Endpoint describes where a remotely offered service can be reached.
+type Endpoint struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"` // e.g. "grpc", "http", "tcp"
+}
+
+// Advertisement is a single service offered by a node, as stored in the backend.
+type Advertisement struct {
+	NodeID     string            `json:"node_id"`
+	Name       string            `json:"name"`
+	ModuleName string            `json:"module"`
+	Version    string            `json:"version,omitempty"`
+	Endpoint   Endpoint          `json:"endpoint"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+}
+
+// key uniquely identifies an advertisement within a store.
+func (a Advertisement) key() string {
+	return a.NodeID + "/" + a.Name + "/" + a.ModuleName + "/" + a.Version
+}
+
+// expired reports whether the advertisement's TTL has elapsed at now.
+func (a Advertisement) expired(now time.Time) bool {
+	return !a.ExpiresAt.IsZero() && !now.Before(a.ExpiresAt)
+}
+
+// Store is the pluggable backend in which nodes advertise their services.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Put creates or refreshes an advertisement.
+	Put(ctx context.Context, ad Advertisement) error
+	// Delete removes an advertisement. Deleting a missing advertisement is not an error.
+	Delete(ctx context.Context, ad Advertisement) error
+	// List returns the unexpired advertisements for the named service, or for every service
+	// if name is empty, ordered by name, node and module.
+	List(ctx context.Context, name string) ([]Advertisement, error)
+}
+
+// MemoryStore is an in-process Store. Several registries sharing one MemoryStore behave like
+// nodes sharing a remote backend, which makes it a convenient stand-in for tests.
+type MemoryStore struct {
+	mu  sync.Mutex
+	ads map[string]Advertisement
+	now func() time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{ads: make(map[string]Advertisement), now: time.Now}
+}
+
+// Put creates or refreshes an advertisement.
+func (s *MemoryStore) Put(ctx context.Context, ad Advertisement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ads[ad.key()] = ad
+	return nil
+}
+
+// Delete removes an advertisement.
+func (s *MemoryStore) Delete(ctx context.Context, ad Advertisement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ads, ad.key())
+	return nil
+}
+
+// List returns unexpired advertisements, pruning expired ones as a side effect.
+func (s *MemoryStore) List(ctx context.Context, name string) ([]Advertisement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var result []Advertisement
+	for k, ad := range s.ads {
+		if ad.expired(now) {
+			delete(s.ads, k)
+			continue
+		}
+		if name == "" || ad.Name == name {
+			result = append(result, ad)
+		}
+	}
+	sortAdvertisements(result)
+	return result, nil
+}
+
+// sortAdvertisements orders advertisements deterministically by name, node and module.
+func sortAdvertisements(ads []Advertisement) {
+	sort.Slice(ads, func(i, j int) bool {
+		return ads[i].key() < ads[j].key()
+	})
+}
@@ -0,0 +1,159 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"acacia/core/auth"
+)
+
+// Strategy selects one provider when several modules provide the same service.
+type Strategy string
+
+const (
+	// StrategyPriority picks the provider with the highest priority (the default).
+	StrategyPriority Strategy = "priority"
+	// StrategyRoundRobin cycles through providers on successive lookups.
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyRandom picks a provider uniformly at random.
+	StrategyRandom Strategy = "random"
+	// StrategyHealthAware picks the highest priority provider that reports itself healthy.
+	StrategyHealthAware Strategy = "health"
+)
+
+// ErrNoHealthyProvider is returned by health-aware resolution when every provider is unhealthy.
+var ErrNoHealthyProvider = errors.New("no healthy service provider")
+
+// HealthCheckFunc reports whether the provider of a service is healthy enough to receive calls.
+// The kernel installs one that consults the service's or owning module's HealthReporter.
+type HealthCheckFunc func(ctx context.Context, moduleName string, service interface{}) bool
+
+// SetResolutionStrategy sets the strategy used to pick a provider of the named service.
+func (r *DefaultRegistry) SetResolutionStrategy(name string, strategy Strategy) error {
+	switch strategy {
+	case StrategyPriority, StrategyRoundRobin, StrategyRandom, StrategyHealthAware:
+	default:
+		return fmt.Errorf("unknown resolution strategy %q for service '%s'", strategy, name)
+	}
+
+	r.strategyMu.Lock()
+	defer r.strategyMu.Unlock()
+	r.strategies[name] = strategy
+	return nil
+}
+
+// SetHealthCheck installs the function used by StrategyHealthAware. A nil function treats
+// every provider as healthy.
+func (r *DefaultRegistry) SetHealthCheck(fn HealthCheckFunc) {
+	r.strategyMu.Lock()
+	defer r.strategyMu.Unlock()
+	r.healthCheck = fn
+}
+
+// GetAll returns every provider of the named service that the principal in ctx may access,
+// across all versions, newest version and highest priority first. It is intended for fan-out calls.
+func (r *DefaultRegistry) GetAll(ctx context.Context, name string) ([]interface{}, error) {
+	r.mu.RLock()
+	entries := r.services[name]
+	r.mu.RUnlock()
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("service with name '%s' %w", name, ErrNotFound)
+	}
+
+	accessible, err := r.accessibleEntries(ctx, name, entries)
+	if err != nil {
+		return nil, err
+	}
+	services := make([]interface{}, 0, len(accessible))
+	for _, entry := range accessible {
+		services = append(services, entry.service)
+	}
+	return services, nil
+}
+
+// newestProviders returns the leading entries that share the newest version.
+// entries must be sorted by sortEntries.
+func newestProviders(entries []serviceEntry) []serviceEntry {
+	n := 1
+	for n < len(entries) && sameVersion(entries[n].version, entries[0].version) {
+		n++
+	}
+	return entries[:n]
+}
+
+// accessibleEntries filters candidates down to those the principal in ctx may access.
+// If none are accessible, the access error for the first candidate is returned.
+func (r *DefaultRegistry) accessibleEntries(ctx context.Context, name string, candidates []serviceEntry) ([]serviceEntry, error) {
+	if auth.PrincipalFromContext(ctx) == nil {
+		return nil, fmt.Errorf("no principal found in context for service access check for '%s'", name)
+	}
+
+	var accessible []serviceEntry
+	var firstErr error
+	for _, entry := range candidates {
+		if err := r.checkServiceAccess(ctx, name, entry); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		accessible = append(accessible, entry)
+	}
+	if len(accessible) == 0 {
+		return nil, firstErr
+	}
+	return accessible, nil
+}
+
+// forgetPositions drops the round-robin positions of services that no longer have any provider,
+// so names that come and go do not accumulate.
+func (r *DefaultRegistry) forgetPositions(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	r.strategyMu.Lock()
+	defer r.strategyMu.Unlock()
+	for _, name := range names {
+		delete(r.counters, name)
+	}
+}
+
+// resolve picks one of the candidate providers using the service's resolution strategy.
+func (r *DefaultRegistry) resolve(ctx context.Context, name string, candidates []serviceEntry) (serviceEntry, error) {
+	accessible, err := r.accessibleEntries(ctx, name, candidates)
+	if err != nil {
+		return serviceEntry{}, err
+	}
+
+	r.strategyMu.Lock()
+	strategy := r.strategies[name]
+	healthCheck := r.healthCheck
+	var position uint64
+	if strategy == StrategyRoundRobin {
+		position = r.counters[name]
+		r.counters[name]++
+	}
+	r.strategyMu.Unlock()
+
+	switch strategy {
+	case StrategyRoundRobin:
+		return accessible[position%uint64(len(accessible))], nil
+	case StrategyRandom:
+		return accessible[rand.IntN(len(accessible))], nil
+	case StrategyHealthAware:
+		if healthCheck == nil {
+			return accessible[0], nil
+		}
+		for _, entry := range accessible {
+			if healthCheck(ctx, entry.moduleName, entry.service) {
+				return entry, nil
+			}
+		}
+		return serviceEntry{}, fmt.Errorf("service '%s': %w", name, ErrNoHealthyProvider)
+	default:
+		return accessible[0], nil // Sorted by priority
+	}
+}
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// contractOf returns the reflect.Type of T, including interface types.
+func contractOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Register registers service under name with T as its declared contract.
+// T is usually the interface consumers will look the service up by, e.g.
+//
+//	registry.Register[payments.Service](reg, "payments", impl, "payments")
+func Register[T any](reg Registry, name string, service T, moduleName string) error {
+	return reg.RegisterServiceWithContract(name, service, contractOf[T](), moduleName)
+}
+
+// Get retrieves the service registered under name and asserts it to T.
+// It performs the same access check as Registry.GetService and returns an error
+// wrapping ErrTypeMismatch instead of panicking when the service is not a T.
+func Get[T any](ctx context.Context, reg Registry, name string) (T, error) {
+	svc, err := reg.GetService(ctx, name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return assertService[T](name, svc)
+}
+
+// GetVersion retrieves the newest version of the service matching constraint and asserts it to T.
+func GetVersion[T any](ctx context.Context, reg Registry, name string, constraint string) (T, error) {
+	svc, err := reg.GetServiceVersion(ctx, name, constraint)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return assertService[T](name, svc)
+}
+
+// GetAllTyped returns every accessible provider of the named service that is a T.
+// Providers of another type are skipped.
+func GetAllTyped[T any](ctx context.Context, reg Registry, name string) ([]T, error) {
+	services, err := reg.GetAll(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, 0, len(services))
+	for _, svc := range services {
+		if typed, ok := svc.(T); ok {
+			result = append(result, typed)
+		}
+	}
+	return result, nil
+}
+
+// assertService asserts svc to T, returning an error wrapping ErrTypeMismatch on failure.
+func assertService[T any](name string, svc interface{}) (T, error) {
+	typed, ok := svc.(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("service '%s' is %T, not %s: %w", name, svc, contractOf[T](), ErrTypeMismatch)
+	}
+	return typed, nil
+}
+
+// List returns every service the principal in ctx may access that satisfies T, keyed by service name.
+func List[T any](ctx context.Context, reg Registry) (map[string]T, error) {
+	services, err := reg.GetServicesByType(ctx, contractOf[T]())
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]T, len(services))
+	for name, svc := range services {
+		if typed, ok := svc.(T); ok {
+			result[name] = typed
+		}
+	}
+	return result, nil
+}
+
+// GetProxy retrieves a proxy for the named service and asserts it to T. The service must have
+// a ProxyFactory registered whose proxies implement T; otherwise use GetServiceProxy and the
+// reflective *ServiceProxy.
+func GetProxy[T any](ctx context.Context, reg Registry, name string) (T, error) {
+	proxy, err := reg.GetServiceProxy(ctx, name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return assertService[T](name, proxy)
+}
@@ -0,0 +1,199 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"acacia/core/auth"
+)
+
+// ChangeType identifies the kind of change applied to a service registration.
+type ChangeType string
+
+const (
+	// ServiceRegistered is emitted when a new service (or service version) is registered.
+	ServiceRegistered ChangeType = "registered"
+	// ServiceUnregistered is emitted when a service (or service version) is removed.
+	ServiceUnregistered ChangeType = "unregistered"
+	// ServiceReplaced is emitted when an existing registration is overwritten in place.
+	ServiceReplaced ChangeType = "replaced"
+)
+
+// ServiceChange describes a single change to the registry.
+// Service is nil for ServiceUnregistered changes.
+type ServiceChange struct {
+	Type       ChangeType
+	Name       string
+	ModuleName string
+	Version    string
+	Metadata   map[string]string
+	Service    interface{}
+}
+
+// watchBufferSize is the number of pending changes buffered per watcher.
+// Like the event bus, changes are dropped for watchers that fall further behind.
+const watchBufferSize = 64
+
+// watcher is a filtered subscription to registry changes.
+type watcher struct {
+	ch         chan ServiceChange
+	name       string // Service name filter, empty for any
+	moduleName string // Module name filter, empty for any
+	principal  auth.Principal
+}
+
+// Watch streams changes for the service registered under name until ctx is done,
+// at which point the channel is closed. An empty name watches every service.
+// Only changes the principal in ctx is authorized to access are delivered.
+func (r *DefaultRegistry) Watch(ctx context.Context, name string) (<-chan ServiceChange, error) {
+	return r.watch(ctx, name, "")
+}
+
+// WatchModule streams changes for every service owned by moduleName until ctx is done.
+func (r *DefaultRegistry) WatchModule(ctx context.Context, moduleName string) (<-chan ServiceChange, error) {
+	if moduleName == "" {
+		return nil, fmt.Errorf("module name is empty")
+	}
+	return r.watch(ctx, "", moduleName)
+}
+
+func (r *DefaultRegistry) watch(ctx context.Context, name, moduleName string) (<-chan ServiceChange, error) {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil, fmt.Errorf("no principal found in context for registry watch")
+	}
+
+	w := &watcher{
+		ch:         make(chan ServiceChange, watchBufferSize),
+		name:       name,
+		moduleName: moduleName,
+		principal:  p,
+	}
+
+	r.watchMu.Lock()
+	r.watchers[w] = struct{}{}
+	r.watchMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		r.watchMu.Lock()
+		delete(r.watchers, w)
+		close(w.ch)
+		r.watchMu.Unlock()
+	}()
+	return w.ch, nil
+}
+
+// OnChange registers a listener that is called for every registry change, without any access
+// filtering, in the order the changes were applied. A change is delivered by the goroutine that
+// made it, or by one already delivering earlier changes when several are made concurrently. It
+// is intended for trusted in-process components such as the kernel, which republishes changes
+// on the event bus.
+func (r *DefaultRegistry) OnChange(listener func(ServiceChange)) {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// enqueue queues changes for delivery. It must be called with r.mu held, so that changes are
+// queued in the order they are applied.
+func (r *DefaultRegistry) enqueue(changes []ServiceChange) {
+	if len(changes) == 0 {
+		return
+	}
+	r.queueMu.Lock()
+	defer r.queueMu.Unlock()
+	r.queued = append(r.queued, changes...)
+}
+
+// notify delivers queued changes unless another goroutine is already doing so, in which case
+// that goroutine delivers them after the ones it holds. It must be called without holding r.mu.
+func (r *DefaultRegistry) notify() {
+	r.queueMu.Lock()
+	if r.delivering {
+		r.queueMu.Unlock()
+		return
+	}
+	r.delivering = true
+	finished := false
+	defer func() {
+		if !finished {
+			// A listener panicked: hand delivery over to the next caller
+			r.queueMu.Lock()
+			r.delivering = false
+			r.queueMu.Unlock()
+		}
+	}()
+	for len(r.queued) > 0 {
+		changes := r.queued
+		r.queued = nil
+		r.queueMu.Unlock()
+		r.deliverChanges(changes)
+		r.queueMu.Lock()
+	}
+	// Cleared under the same lock as the empty check, so no queued change is left undelivered
+	r.delivering = false
+	finished = true
+	r.queueMu.Unlock()
+}
+
+// deliverChanges passes changes to listeners and matching watchers. Listeners and permission
+// checks run without r.watchMu held, so a listener may itself call Watch, OnChange or register
+// services; changes it makes are delivered once it returns.
+func (r *DefaultRegistry) deliverChanges(changes []ServiceChange) {
+	r.watchMu.Lock()
+	listeners := append([]func(ServiceChange){}, r.listeners...)
+	watchers := make([]*watcher, 0, len(r.watchers))
+	for w := range r.watchers {
+		watchers = append(watchers, w)
+	}
+	r.watchMu.Unlock()
+
+	for _, change := range changes {
+		for _, listener := range listeners {
+			listener(change)
+		}
+		for _, w := range watchers {
+			if w.name != "" && w.name != change.Name {
+				continue
+			}
+			if w.moduleName != "" && w.moduleName != change.ModuleName {
+				continue
+			}
+			if !r.accessController.HasPermission(w.principal, ServicePermission(change.ModuleName, change.Name)) {
+				continue
+			}
+			r.deliver(w, change)
+		}
+	}
+}
+
+// deliver performs a non-blocking send to w, skipping watchers whose channel
+// has been closed since deliverChanges took its snapshot.
+func (r *DefaultRegistry) deliver(w *watcher, change ServiceChange) {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	if _, ok := r.watchers[w]; !ok {
+		return
+	}
+	select {
+	case w.ch <- change:
+	default:
+		// drop if watcher is slow
+	}
+}
+
+// changeFor builds a ServiceChange for the given entry.
+func changeFor(t ChangeType, name string, entry serviceEntry) ServiceChange {
+	change := ServiceChange{
+		Type:       t,
+		Name:       name,
+		ModuleName: entry.moduleName,
+		Version:    entry.versionString(),
+		Metadata:   copyMetadata(entry.metadata),
+	}
+	if t != ServiceUnregistered {
+		change.Service = entry.service
+	}
+	return change
+}
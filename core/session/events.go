@@ -0,0 +1,44 @@
+package session
+
+// Event types published on the event bus passed in Options.
+const (
+	StartedEventType = "session.started"
+	EndedEventType   = "session.ended"
+)
+
+// EndReason tells why a session ended.
+type EndReason string
+
+const (
+	EndReasonClosed  EndReason = "closed"  // Ended through Manager.End, e.g. logout or disconnect
+	EndReasonRevoked EndReason = "revoked" // Ended through Manager.Revoke or RevokePrincipal
+	EndReasonExpired EndReason = "expired" // Idle or absolute expiry passed
+)
+
+// Event is the common part of session events.
+type Event struct {
+	SessionID     string
+	PrincipalID   string
+	PrincipalType string
+}
+
+func (e Event) EventType() string { return "" } // Base implementation, overridden by specific events
+
+// StartedEvent is published when a session is created.
+type StartedEvent struct {
+	Event
+}
+
+func (e StartedEvent) EventType() string { return StartedEventType }
+
+// EndedEvent is published exactly once when a session ends, whatever the reason.
+type EndedEvent struct {
+	Event
+	Reason EndReason
+}
+
+func (e EndedEvent) EventType() string { return EndedEventType }
+
+func eventFor(s *Session) Event {
+	return Event{SessionID: s.ID, PrincipalID: s.PrincipalID, PrincipalType: s.PrincipalType}
+}
@@ -0,0 +1,304 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRedisPrefix is the key prefix RedisStore uses when none is given.
+const DefaultRedisPrefix = "acacia:session:"
+
+// redisRetention keeps session keys around for a while after the session expires, so that a
+// manager's sweep still finds them and publishes their end. It is only a safety net that
+// stops abandoned sessions from piling up when no manager is sweeping.
+const redisRetention = time.Hour
+
+// ErrRedisNil is returned by RedisClient.Get for missing keys, like redis.Nil in client libraries.
+var ErrRedisNil = errors.New("redis: nil")
+
+// RedisClient is the subset of Redis commands RedisStore needs. An adapter for a real client
+// library is a few lines per method; InProcessRedis implements it in memory.
+type RedisClient interface {
+	Get(ctx context.Context, key string) (string, error) // ErrRedisNil if the key does not exist
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) // SET ... XX; false if the key does not exist
+	Del(ctx context.Context, keys ...string) (int64, error)                        // Returns how many keys were removed
+	SAdd(ctx context.Context, key string, members ...string) error
+	SRem(ctx context.Context, key string, members ...string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+}
+
+// RedisStore is a Store on top of Redis, so that several gateway instances share sessions.
+// Each session is a JSON string key with a TTL; sets index all sessions and the sessions of
+// each principal.
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisStore creates a RedisStore. An empty prefix uses DefaultRedisPrefix.
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (r *RedisStore) sessionKey(id string) string   { return r.prefix + "s:" + id }
+func (r *RedisStore) indexKey() string              { return r.prefix + "index" }
+func (r *RedisStore) principalKey(id string) string { return r.prefix + "p:" + id }
+
+// Create stores a new session and indexes it.
+func (r *RedisStore) Create(ctx context.Context, s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+	ttl := time.Until(s.ExpiresAt) + redisRetention
+	if err := r.client.Set(ctx, r.sessionKey(s.ID), string(data), ttl); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	if err := r.client.SAdd(ctx, r.indexKey(), s.ID); err != nil {
+		return fmt.Errorf("index session: %w", err)
+	}
+	if err := r.client.SAdd(ctx, r.principalKey(s.PrincipalID), s.ID); err != nil {
+		return fmt.Errorf("index session: %w", err)
+	}
+	return nil
+}
+
+// Update replaces an existing session. It writes with SET XX, so a session another instance
+// has deleted in the meantime stays deleted. The indexes are left alone: the principal of a
+// session never changes.
+func (r *RedisStore) Update(ctx context.Context, s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+	ttl := time.Until(s.ExpiresAt) + redisRetention
+	ok, err := r.client.SetXX(ctx, r.sessionKey(s.ID), string(data), ttl)
+	if err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Load returns the session with id.
+func (r *RedisStore) Load(ctx context.Context, id string) (*Session, error) {
+	data, err := r.client.Get(ctx, r.sessionKey(id))
+	if errors.Is(err, ErrRedisNil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+	var s Session
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("parse session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// Delete removes a session and its index entries, and reports whether this call removed the
+// session key. Of several instances deleting the same session, only one gets true.
+func (r *RedisStore) Delete(ctx context.Context, s *Session) (bool, error) {
+	n, err := r.client.Del(ctx, r.sessionKey(s.ID))
+	if err != nil {
+		return false, fmt.Errorf("delete session: %w", err)
+	}
+	if err := r.client.SRem(ctx, r.indexKey(), s.ID); err != nil {
+		return n > 0, fmt.Errorf("unindex session: %w", err)
+	}
+	if err := r.client.SRem(ctx, r.principalKey(s.PrincipalID), s.ID); err != nil {
+		return n > 0, fmt.Errorf("unindex session: %w", err)
+	}
+	return n > 0, nil
+}
+
+// List returns all indexed sessions ordered by creation time.
+func (r *RedisStore) List(ctx context.Context) ([]*Session, error) {
+	return r.loadIndexed(ctx, r.indexKey())
+}
+
+// ListByPrincipal returns the sessions of principalID ordered by creation time.
+func (r *RedisStore) ListByPrincipal(ctx context.Context, principalID string) ([]*Session, error) {
+	return r.loadIndexed(ctx, r.principalKey(principalID))
+}
+
+// loadIndexed loads the sessions listed in the set at key, pruning entries whose session key
+// has already expired in Redis.
+func (r *RedisStore) loadIndexed(ctx context.Context, key string) ([]*Session, error) {
+	ids, err := r.client.SMembers(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	var sessions []*Session
+	for _, id := range ids {
+		s, err := r.Load(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			_ = r.client.SRem(ctx, key, id) // Stale index entry
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+// InProcessRedis is an in-memory RedisClient with Redis semantics for the commands it
+// supports, including key expiry. It stands in for a Redis server in tests and in
+// single-process deployments that want RedisStore's behavior without running Redis.
+type InProcessRedis struct {
+	mu   sync.Mutex
+	keys map[string]*redisValue
+	now  func() time.Time
+}
+
+type redisValue struct {
+	str       string
+	set       map[string]struct{} // Non-nil for set values
+	expiresAt time.Time           // Zero means no expiry
+}
+
+// errWrongType mirrors Redis' WRONGTYPE error.
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// NewInProcessRedis creates an empty InProcessRedis.
+func NewInProcessRedis() *InProcessRedis {
+	return &InProcessRedis{keys: make(map[string]*redisValue), now: time.Now}
+}
+
+// lookup returns the live value at key, dropping it if it has expired. Callers hold mu.
+func (r *InProcessRedis) lookup(key string) *redisValue {
+	v, ok := r.keys[key]
+	if !ok {
+		return nil
+	}
+	if !v.expiresAt.IsZero() && !r.now().Before(v.expiresAt) {
+		delete(r.keys, key)
+		return nil
+	}
+	return v
+}
+
+// Get returns the string stored at key.
+func (r *InProcessRedis) Get(ctx context.Context, key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := r.lookup(key)
+	if v == nil {
+		return "", ErrRedisNil
+	}
+	if v.set != nil {
+		return "", errWrongType
+	}
+	return v.str, nil
+}
+
+// Set stores a string at key. A positive ttl expires the key, like SET with PX.
+func (r *InProcessRedis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := &redisValue{str: value}
+	if ttl > 0 {
+		v.expiresAt = r.now().Add(ttl)
+	}
+	r.keys[key] = v
+	return nil
+}
+
+// SetXX stores a string at key only if the key exists, like SET with XX, and reports whether
+// it did. A positive ttl expires the key, like SET with PX.
+func (r *InProcessRedis) SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lookup(key) == nil {
+		return false, nil
+	}
+	v := &redisValue{str: value}
+	if ttl > 0 {
+		v.expiresAt = r.now().Add(ttl)
+	}
+	r.keys[key] = v
+	return true, nil
+}
+
+// Del removes keys of any type and returns how many existed.
+func (r *InProcessRedis) Del(ctx context.Context, keys ...string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if r.lookup(key) != nil {
+			delete(r.keys, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// SAdd adds members to the set at key, creating it if needed.
+func (r *InProcessRedis) SAdd(ctx context.Context, key string, members ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := r.lookup(key)
+	if v == nil {
+		v = &redisValue{set: make(map[string]struct{})}
+		r.keys[key] = v
+	}
+	if v.set == nil {
+		return errWrongType
+	}
+	for _, m := range members {
+		v.set[m] = struct{}{}
+	}
+	return nil
+}
+
+// SRem removes members from the set at key. Like Redis, an emptied set is deleted.
+func (r *InProcessRedis) SRem(ctx context.Context, key string, members ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := r.lookup(key)
+	if v == nil {
+		return nil
+	}
+	if v.set == nil {
+		return errWrongType
+	}
+	for _, m := range members {
+		delete(v.set, m)
+	}
+	if len(v.set) == 0 {
+		delete(r.keys, key)
+	}
+	return nil
+}
+
+// SMembers returns the members of the set at key in no particular order.
+func (r *InProcessRedis) SMembers(ctx context.Context, key string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := r.lookup(key)
+	if v == nil {
+		return nil, nil
+	}
+	if v.set == nil {
+		return nil, errWrongType
+	}
+	members := make([]string, 0, len(v.set))
+	for m := range v.set {
+		members = append(members, m)
+	}
+	return members, nil
+}
@@ -0,0 +1,344 @@
+// Package session tracks player connections. A session is created for an authenticated
+// principal, expires after a period of inactivity (sliding expiry) and at the latest after a
+// fixed lifetime (absolute expiry), can be revoked, and carries per-session key/value data.
+// Sessions live in a pluggable Store so that several gateway instances can share them.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"acacia/core/auth"
+	"acacia/core/events"
+)
+
+const (
+	// DefaultIdleTimeout is how long a session survives without activity when none is configured.
+	DefaultIdleTimeout = 30 * time.Minute
+	// DefaultMaxLifetime is the absolute session lifetime when none is configured.
+	DefaultMaxLifetime = 24 * time.Hour
+	// DefaultSweepInterval is how often Run looks for expired sessions when none is configured.
+	DefaultSweepInterval = time.Minute
+)
+
+var (
+	// ErrSessionNotFound is returned for unknown, ended or revoked sessions.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionExpired is returned when a session's idle or absolute expiry has passed.
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// Session is a single authenticated connection. Sessions returned by the Manager are copies;
+// change them through the Manager, not by modifying the struct.
+type Session struct {
+	ID            string                 `json:"id"`
+	PrincipalID   string                 `json:"principal_id"`
+	PrincipalType string                 `json:"principal_type"`
+	Roles         []string               `json:"roles,omitempty"`
+	Permissions   []auth.Permission      `json:"permissions,omitempty"` // Direct permissions of the principal, if any
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Data          map[string]string      `json:"data,omitempty"` // Per-session key/value data
+
+	CreatedAt         time.Time `json:"created_at"`
+	LastSeenAt        time.Time `json:"last_seen_at"`
+	ExpiresAt         time.Time `json:"expires_at"`          // Earliest of the idle and absolute expiry
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"` // Never moves, however active the session is
+}
+
+// Principal rebuilds the principal the session was created for, so gateways can place it in
+// request contexts with auth.ContextWithPrincipal.
+func (s *Session) Principal() auth.Principal {
+	p := auth.NewPrincipalWithAttributes(s.PrincipalID, s.PrincipalType, append([]string{}, s.Roles...), s.Attributes)
+	if len(s.Permissions) > 0 {
+		p = p.WithPermissions(s.Permissions...)
+	}
+	return p
+}
+
+// Value returns the session data stored under key.
+func (s *Session) Value(key string) (string, bool) {
+	v, ok := s.Data[key]
+	return v, ok
+}
+
+// expired reports whether the session is no longer valid at now.
+func (s *Session) expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// clone returns a deep copy, so stores and callers never share maps or slices.
+func (s *Session) clone() *Session {
+	c := *s
+	c.Roles = append([]string(nil), s.Roles...)
+	c.Permissions = append([]auth.Permission(nil), s.Permissions...)
+	if s.Attributes != nil {
+		c.Attributes = make(map[string]interface{}, len(s.Attributes))
+		for k, v := range s.Attributes {
+			c.Attributes[k] = v
+		}
+	}
+	if s.Data != nil {
+		c.Data = make(map[string]string, len(s.Data))
+		for k, v := range s.Data {
+			c.Data[k] = v
+		}
+	}
+	return &c
+}
+
+// Options configures a Manager.
+type Options struct {
+	IdleTimeout   time.Duration // Sliding expiry, extended by Touch; defaults to DefaultIdleTimeout
+	MaxLifetime   time.Duration // Absolute expiry from creation; defaults to DefaultMaxLifetime
+	SweepInterval time.Duration // How often Run ends expired sessions; defaults to DefaultSweepInterval
+	Bus           events.Bus    // Receives StartedEvent and EndedEvent, nil to publish nothing
+}
+
+// Manager creates, validates and ends sessions. It is safe for concurrent use.
+type Manager struct {
+	store Store
+	opts  Options
+	now   func() time.Time
+	mu    sync.Mutex // Serializes this manager's updates, so concurrent data changes are not lost
+}
+
+// NewManager creates a Manager backed by store.
+func NewManager(store Store, opts Options) *Manager {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.MaxLifetime <= 0 {
+		opts.MaxLifetime = DefaultMaxLifetime
+	}
+	if opts.SweepInterval <= 0 {
+		opts.SweepInterval = DefaultSweepInterval
+	}
+	return &Manager{store: store, opts: opts, now: time.Now}
+}
+
+// Create starts a session for p and publishes a StartedEvent.
+func (m *Manager) Create(ctx context.Context, p auth.Principal) (*Session, error) {
+	if p == nil {
+		return nil, fmt.Errorf("create session: principal is required")
+	}
+	id, err := newSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+	now := m.now()
+	s := &Session{
+		ID:                id,
+		PrincipalID:       p.ID(),
+		PrincipalType:     p.Type(),
+		Roles:             append([]string{}, p.Roles()...),
+		Attributes:        auth.PrincipalAttributes(p),
+		CreatedAt:         now,
+		AbsoluteExpiresAt: now.Add(m.opts.MaxLifetime),
+	}
+	if holder, ok := p.(auth.PermissionHolder); ok {
+		s.Permissions = holder.Permissions()
+	}
+	m.slide(s, now)
+	if err := m.store.Create(ctx, s.clone()); err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+	m.publish(ctx, StartedEventType, StartedEvent{Event: eventFor(s)})
+	return s, nil
+}
+
+// Get returns the session with id without extending it. Expired sessions are ended and
+// reported as ErrSessionExpired.
+func (m *Manager) Get(ctx context.Context, id string) (*Session, error) {
+	s, err := m.store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.expired(m.now()) {
+		m.expire(ctx, id)
+		return nil, ErrSessionExpired
+	}
+	return s, nil
+}
+
+// Touch records activity on the session and extends its idle expiry, never beyond the
+// absolute expiry. Gateways call it for every message or request of the session.
+func (m *Manager) Touch(ctx context.Context, id string) (*Session, error) {
+	return m.update(ctx, id, func(s *Session) {})
+}
+
+// SetValue stores value under key in the session's data. Like Touch, it counts as activity.
+func (m *Manager) SetValue(ctx context.Context, id, key, value string) error {
+	_, err := m.update(ctx, id, func(s *Session) {
+		if s.Data == nil {
+			s.Data = make(map[string]string)
+		}
+		s.Data[key] = value
+	})
+	return err
+}
+
+// DeleteValue removes key from the session's data.
+func (m *Manager) DeleteValue(ctx context.Context, id, key string) error {
+	_, err := m.update(ctx, id, func(s *Session) {
+		delete(s.Data, key)
+	})
+	return err
+}
+
+// End closes a session normally, e.g. when the player logs out or disconnects.
+func (m *Manager) End(ctx context.Context, id string) error {
+	return m.end(ctx, id, EndReasonClosed)
+}
+
+// Revoke ends a session forcibly, e.g. when a player is kicked or banned.
+func (m *Manager) Revoke(ctx context.Context, id string) error {
+	return m.end(ctx, id, EndReasonRevoked)
+}
+
+// RevokePrincipal revokes every session of the principal with principalID and returns how
+// many were revoked.
+func (m *Manager) RevokePrincipal(ctx context.Context, principalID string) (int, error) {
+	sessions, err := m.store.ListByPrincipal(ctx, principalID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke sessions of %s: %w", principalID, err)
+	}
+	revoked := 0
+	for _, s := range sessions {
+		err := m.end(ctx, s.ID, EndReasonRevoked)
+		if errors.Is(err, ErrSessionNotFound) {
+			continue // Ended concurrently
+		}
+		if err != nil {
+			return revoked, fmt.Errorf("revoke sessions of %s: %w", principalID, err)
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// Sweep ends every expired session, publishing an EndedEvent for each, and returns how many
+// were ended. Expired sessions are also ended lazily when they are next accessed, but only
+// a sweep notices sessions whose players simply went away.
+func (m *Manager) Sweep(ctx context.Context) (int, error) {
+	sessions, err := m.store.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("sweep sessions: %w", err)
+	}
+	now := m.now()
+	ended := 0
+	for _, s := range sessions {
+		if s.expired(now) && m.expire(ctx, s.ID) {
+			ended++
+		}
+	}
+	return ended, nil
+}
+
+// Run sweeps expired sessions every SweepInterval until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = m.Sweep(ctx) // Failed sweeps are retried on the next tick
+		}
+	}
+}
+
+// update applies fn to a valid session, slides its expiry and saves it. The store refuses to
+// update a session that has ended since it was loaded, here or on another instance sharing the
+// store, so an update never revives a revoked session.
+func (m *Manager) update(ctx context.Context, id string, fn func(*Session)) (*Session, error) {
+	m.mu.Lock()
+	s, err := m.store.Load(ctx, id)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	now := m.now()
+	if s.expired(now) {
+		m.mu.Unlock()
+		m.expire(ctx, id)
+		return nil, ErrSessionExpired
+	}
+	fn(s)
+	m.slide(s, now)
+	err = m.store.Update(ctx, s.clone())
+	m.mu.Unlock()
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, err // Ended concurrently
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update session %s: %w", id, err)
+	}
+	return s, nil
+}
+
+// slide marks the session as seen at now and moves its expiry accordingly.
+func (m *Manager) slide(s *Session, now time.Time) {
+	s.LastSeenAt = now
+	s.ExpiresAt = now.Add(m.opts.IdleTimeout)
+	if s.ExpiresAt.After(s.AbsoluteExpiresAt) {
+		s.ExpiresAt = s.AbsoluteExpiresAt
+	}
+}
+
+// expire ends a session that was found to be expired. It reports whether this call ended it.
+func (m *Manager) expire(ctx context.Context, id string) bool {
+	return m.end(ctx, id, EndReasonExpired) == nil
+}
+
+// end removes a session and publishes an EndedEvent. Only the caller whose Delete actually
+// removes the session publishes, so every session ends exactly once, even when several
+// instances sharing the store end it at the same time.
+func (m *Manager) end(ctx context.Context, id string, reason EndReason) error {
+	s, err := m.store.Load(ctx, id)
+	if err != nil {
+		return err
+	}
+	removed, err := m.store.Delete(ctx, s)
+	if err != nil {
+		return fmt.Errorf("end session %s: %w", id, err)
+	}
+	if !removed {
+		return ErrSessionNotFound // Ended concurrently
+	}
+	m.publish(ctx, EndedEventType, EndedEvent{Event: eventFor(s), Reason: reason})
+	return nil
+}
+
+func (m *Manager) publish(ctx context.Context, topic string, event events.TypedEvent) {
+	if m.opts.Bus != nil {
+		m.opts.Bus.Publish(ctx, topic, event)
+	}
+}
+
+// newSessionID returns 256 random bits, URL-safe encoded.
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type sessionContextKey struct{}
+
+// ContextWithSession returns a new context carrying s.
+func ContextWithSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, s)
+}
+
+// FromContext returns the session stored in ctx, or nil if there is none.
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionContextKey{}).(*Session)
+	return s
+}
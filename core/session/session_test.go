@@ -0,0 +1,282 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"acacia/core/auth"
+	"acacia/core/events"
+	"acacia/core/session"
+)
+
+var stores = []struct {
+	name string
+	new  func() session.Store
+}{
+	{"memory", func() session.Store { return session.NewMemoryStore() }},
+	{"redis", func() session.Store { return session.NewRedisStore(session.NewInProcessRedis(), "") }},
+}
+
+func TestManager_Lifecycle(t *testing.T) {
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			ctx := context.Background()
+			bus := events.New()
+			defer bus.Close()
+			started, cancelStarted, _ := bus.Subscribe(session.StartedEventType)
+			defer cancelStarted()
+			ended, cancelEnded, _ := bus.Subscribe(session.EndedEventType)
+			defer cancelEnded()
+
+			m := session.NewManager(st.new(), session.Options{Bus: bus})
+			p := auth.NewPrincipalWithAttributes("alice", "user", []string{"player"}, map[string]interface{}{"shard": "eu"})
+			s, err := m.Create(ctx, p)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if e := (<-started).(session.StartedEvent); e.SessionID != s.ID || e.PrincipalID != "alice" {
+				t.Errorf("started event = %+v", e)
+			}
+
+			if err := m.SetValue(ctx, s.ID, "room", "lobby-1"); err != nil {
+				t.Fatalf("SetValue: %v", err)
+			}
+			got, err := m.Get(ctx, s.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if v, _ := got.Value("room"); v != "lobby-1" {
+				t.Errorf("room = %q", v)
+			}
+			restored := got.Principal()
+			if restored.ID() != "alice" || restored.Roles()[0] != "player" || auth.PrincipalAttributes(restored)["shard"] != "eu" {
+				t.Errorf("principal = %s %v %v", restored.ID(), restored.Roles(), auth.PrincipalAttributes(restored))
+			}
+			if err := m.DeleteValue(ctx, s.ID, "room"); err != nil {
+				t.Fatalf("DeleteValue: %v", err)
+			}
+			if got, _ := m.Get(ctx, s.ID); len(got.Data) != 0 {
+				t.Errorf("data = %v, want empty", got.Data)
+			}
+
+			if err := m.Revoke(ctx, s.ID); err != nil {
+				t.Fatalf("Revoke: %v", err)
+			}
+			if e := (<-ended).(session.EndedEvent); e.SessionID != s.ID || e.Reason != session.EndReasonRevoked {
+				t.Errorf("ended event = %+v", e)
+			}
+			if _, err := m.Get(ctx, s.ID); !errors.Is(err, session.ErrSessionNotFound) {
+				t.Errorf("Get after revoke = %v, want ErrSessionNotFound", err)
+			}
+			if err := m.End(ctx, s.ID); !errors.Is(err, session.ErrSessionNotFound) {
+				t.Errorf("ending twice = %v, want ErrSessionNotFound", err)
+			}
+		})
+	}
+}
+
+func TestManager_Expiry(t *testing.T) {
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := session.NewManager(st.new(), session.Options{IdleTimeout: 60 * time.Millisecond, MaxLifetime: 150 * time.Millisecond})
+			p := auth.NewDefaultPrincipal("bob", "user", nil)
+
+			// Touching keeps the session alive past the idle timeout...
+			active, _ := m.Create(ctx, p)
+			idle, _ := m.Create(ctx, p)
+			for i := 0; i < 3; i++ {
+				time.Sleep(30 * time.Millisecond)
+				if _, err := m.Touch(ctx, active.ID); err != nil {
+					t.Fatalf("Touch %d: %v", i, err)
+				}
+			}
+			if _, err := m.Get(ctx, idle.ID); !errors.Is(err, session.ErrSessionExpired) {
+				t.Errorf("idle session = %v, want ErrSessionExpired", err)
+			}
+
+			// ...but never past the absolute expiry.
+			deadline := time.Now().Add(time.Second)
+			for {
+				_, err := m.Touch(ctx, active.ID)
+				if errors.Is(err, session.ErrSessionExpired) {
+					break
+				}
+				if err != nil || time.Now().After(deadline) {
+					t.Fatalf("active session should hit its absolute expiry, got %v", err)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if _, err := m.Get(ctx, active.ID); !errors.Is(err, session.ErrSessionNotFound) {
+				t.Errorf("expired session should be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManager_SweepAndRevokePrincipal(t *testing.T) {
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			ctx := context.Background()
+			bus := events.New()
+			defer bus.Close()
+			ended, cancel, _ := bus.Subscribe(session.EndedEventType)
+			defer cancel()
+
+			m := session.NewManager(st.new(), session.Options{IdleTimeout: 40 * time.Millisecond, Bus: bus})
+			carol := auth.NewDefaultPrincipal("carol", "user", nil)
+			dave := auth.NewDefaultPrincipal("dave", "user", nil)
+			m.Create(ctx, carol)
+			m.Create(ctx, carol)
+			kept, _ := m.Create(ctx, dave)
+
+			n, err := m.RevokePrincipal(ctx, "carol")
+			if err != nil || n != 2 {
+				t.Fatalf("RevokePrincipal = %d, %v; want 2", n, err)
+			}
+			for i := 0; i < 2; i++ {
+				if e := (<-ended).(session.EndedEvent); e.PrincipalID != "carol" || e.Reason != session.EndReasonRevoked {
+					t.Errorf("ended event = %+v", e)
+				}
+			}
+
+			time.Sleep(60 * time.Millisecond)
+			if n, err := m.Sweep(ctx); err != nil || n != 1 {
+				t.Fatalf("Sweep = %d, %v; want 1", n, err)
+			}
+			if e := (<-ended).(session.EndedEvent); e.SessionID != kept.ID || e.Reason != session.EndReasonExpired {
+				t.Errorf("ended event = %+v", e)
+			}
+			if n, _ := m.Sweep(ctx); n != 0 {
+				t.Errorf("second sweep ended %d sessions", n)
+			}
+		})
+	}
+}
+
+// gatedRedis runs hooks before writes, to interleave two managers deterministically.
+type gatedRedis struct {
+	*session.InProcessRedis
+	beforeSetXX func()
+	beforeDel   func()
+}
+
+func (g *gatedRedis) SetXX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	if g.beforeSetXX != nil {
+		g.beforeSetXX()
+	}
+	return g.InProcessRedis.SetXX(ctx, key, value, ttl)
+}
+
+func (g *gatedRedis) Del(ctx context.Context, keys ...string) (int64, error) {
+	if g.beforeDel != nil {
+		g.beforeDel()
+	}
+	return g.InProcessRedis.Del(ctx, keys...)
+}
+
+func TestManager_SharedRedisStore(t *testing.T) {
+	ctx := context.Background()
+	shared := session.NewInProcessRedis()
+	bus := events.New()
+	defer bus.Close()
+	ended, cancel, _ := bus.Subscribe(session.EndedEventType)
+	defer cancel()
+
+	gated := &gatedRedis{InProcessRedis: shared}
+	a := session.NewManager(session.NewRedisStore(gated, ""), session.Options{Bus: bus})
+	b := session.NewManager(session.NewRedisStore(shared, ""), session.Options{Bus: bus})
+	s, err := a.Create(ctx, auth.NewDefaultPrincipal("erin", "user", nil))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// A loads the session for a Touch, B revokes it, then A writes: the revocation holds
+	loaded, resume := make(chan struct{}), make(chan struct{})
+	gated.beforeSetXX = func() {
+		close(loaded)
+		<-resume
+	}
+	touched := make(chan error, 1)
+	go func() {
+		_, err := a.Touch(ctx, s.ID)
+		touched <- err
+	}()
+	<-loaded
+	if err := b.Revoke(ctx, s.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	close(resume)
+	if err := <-touched; !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("Touch racing a revocation = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := b.Get(ctx, s.ID); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("revoked session came back: Get = %v", err)
+	}
+	<-ended // The revocation
+	gated.beforeSetXX = nil
+
+	// A and B end the same session at once: only one of them ends it and publishes
+	s, err = a.Create(ctx, auth.NewDefaultPrincipal("erin", "user", nil))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	atDel, bothLoaded := make(chan struct{}), make(chan struct{})
+	gated.beforeDel = func() {
+		close(atDel) // A has loaded the session
+		<-bothLoaded
+	}
+	results := make(chan error, 1)
+	go func() { results <- a.End(ctx, s.ID) }()
+	<-atDel
+	if err := b.End(ctx, s.ID); err != nil {
+		t.Fatalf("End on B: %v", err)
+	}
+	close(bothLoaded)
+	if err := <-results; !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("second End = %v, want ErrSessionNotFound", err)
+	}
+	if e := (<-ended).(session.EndedEvent); e.SessionID != s.ID || e.Reason != session.EndReasonClosed {
+		t.Errorf("ended event = %+v", e)
+	}
+	select {
+	case e := <-ended:
+		t.Errorf("session ended twice: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestInProcessRedis_Expiry(t *testing.T) {
+	ctx := context.Background()
+	r := session.NewInProcessRedis()
+	if err := r.Set(ctx, "k", "v", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.Get(ctx, "k"); err != nil || v != "v" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, err := r.Get(ctx, "k"); !errors.Is(err, session.ErrRedisNil) {
+		t.Errorf("expired key: %v, want ErrRedisNil", err)
+	}
+	r.SAdd(ctx, "set", "a", "b")
+	if _, err := r.Get(ctx, "set"); err == nil {
+		t.Error("Get on a set should fail with WRONGTYPE")
+	}
+	r.SRem(ctx, "set", "a", "b")
+	if members, _ := r.SMembers(ctx, "set"); len(members) != 0 {
+		t.Errorf("members = %v", members)
+	}
+}
+
+func TestContextWithSession(t *testing.T) {
+	if session.FromContext(context.Background()) != nil {
+		t.Fatal("empty context should carry no session")
+	}
+	s := &session.Session{ID: "x"}
+	if session.FromContext(session.ContextWithSession(context.Background(), s)) != s {
+		t.Error("FromContext should return the stored session")
+	}
+}
@@ -0,0 +1,114 @@
+package session
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Store persists sessions. Implementations must be safe for concurrent use, must return
+// copies rather than shared sessions, and must keep a session at least until its ExpiresAt;
+// the Manager removes expired sessions itself so that it can publish their end.
+//
+// Update and Delete must be atomic with respect to each other, including across processes
+// sharing the store: a session that has been deleted is never brought back by an Update, and
+// of several concurrent Deletes of one session only one reports that it removed it.
+type Store interface {
+	// Create stores a new session.
+	Create(ctx context.Context, s *Session) error
+	// Update replaces an existing session, or returns ErrSessionNotFound if it no longer exists.
+	Update(ctx context.Context, s *Session) error
+	// Load returns the session with id, or ErrSessionNotFound.
+	Load(ctx context.Context, id string) (*Session, error)
+	// Delete removes a session and reports whether it was still there to remove.
+	Delete(ctx context.Context, s *Session) (bool, error)
+	// List returns all stored sessions, including expired ones not yet removed.
+	List(ctx context.Context) ([]*Session, error)
+	// ListByPrincipal returns the sessions of the principal with principalID.
+	ListByPrincipal(ctx context.Context, principalID string) ([]*Session, error)
+}
+
+// MemoryStore is an in-process Store for single-instance deployments and tests.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*Session)}
+}
+
+// Create stores a copy of a new session.
+func (m *MemoryStore) Create(ctx context.Context, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = s.clone()
+	return nil
+}
+
+// Update replaces an existing session with a copy of s.
+func (m *MemoryStore) Update(ctx context.Context, s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[s.ID]; !ok {
+		return ErrSessionNotFound
+	}
+	m.sessions[s.ID] = s.clone()
+	return nil
+}
+
+// Load returns a copy of the session with id.
+func (m *MemoryStore) Load(ctx context.Context, id string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return s.clone(), nil
+}
+
+// Delete removes a session and reports whether it was there.
+func (m *MemoryStore) Delete(ctx context.Context, s *Session) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[s.ID]; !ok {
+		return false, nil
+	}
+	delete(m.sessions, s.ID)
+	return true, nil
+}
+
+// List returns copies of all sessions ordered by creation time.
+func (m *MemoryStore) List(ctx context.Context) ([]*Session, error) {
+	return m.filter(func(*Session) bool { return true }), nil
+}
+
+// ListByPrincipal returns copies of the sessions of principalID ordered by creation time.
+func (m *MemoryStore) ListByPrincipal(ctx context.Context, principalID string) ([]*Session, error) {
+	return m.filter(func(s *Session) bool { return s.PrincipalID == principalID }), nil
+}
+
+func (m *MemoryStore) filter(keep func(*Session) bool) []*Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*Session
+	for _, s := range m.sessions {
+		if keep(s) {
+			result = append(result, s.clone())
+		}
+	}
+	sortSessions(result)
+	return result
+}
+
+// sortSessions orders sessions by creation time, then ID.
+func sortSessions(sessions []*Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+}
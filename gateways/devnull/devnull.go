@@ -7,13 +7,11 @@ import (
 	"fmt"
 	"sync"
 	"time"
-
-	"github.com/mitchellh/mapstructure"
 )
 
 // DevNullConfig holds configuration settings specific to the DevNull gateway.
 type DevNullConfig struct {
-	Enabled      bool   `mapstructure:"enabled"`
+	Enabled      bool   `mapstructure:"enabled" default:"true"`
 	ListenTopic  string `mapstructure:"listen_topic"`
 	ResponseData string `mapstructure:"response_data"`
 }
@@ -48,18 +46,17 @@ func (g *DevNullGateway) SetEventBus(bus events.Bus) {
 	g.eventBus = bus
 }
 
+// ConfigSpec declares the gateway's typed configuration; the kernel decodes, defaults and
+// validates it before calling Configure.
+func (g *DevNullGateway) ConfigSpec() interface{} { return &DevNullConfig{} }
+
 // Configure is called by the kernel to provide the gateway with its specific configuration.
 func (g *DevNullGateway) Configure(cfg interface{}) error {
-	if cfg == nil {
-		fmt.Printf("DevNull gateway %s: No specific configuration found, using defaults.\n", g.name)
-		return nil
-	}
-
-	var devnullCfg DevNullConfig
-	if err := mapstructure.Decode(cfg, &devnullCfg); err != nil {
-		return fmt.Errorf("failed to decode DevNullGateway config: %w", err)
+	devnullCfg, ok := cfg.(*DevNullConfig)
+	if !ok {
+		return fmt.Errorf("unexpected DevNullGateway config type %T", cfg)
 	}
-	g.config = devnullCfg
+	g.config = *devnullCfg
 	fmt.Printf("DevNull gateway %s: Kernel invoked Configure with config: %+v\n", g.name, g.config)
 	return nil
 }
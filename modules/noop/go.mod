@@ -1,5 +1,3 @@
 module acacia/modules/noop
 
 go 1.24.6
-
-require github.com/mitchellh/mapstructure v1.5.0
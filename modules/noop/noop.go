@@ -1,6 +1,7 @@
 package main
 
 import (
+	"acacia/core/config"
 	"acacia/core/events"
 	"acacia/core/kernel"
 	"acacia/core/registry"
@@ -8,8 +9,6 @@ import (
 	"fmt"
 	"sync"
 	"time"
-
-	"github.com/mitchellh/mapstructure"
 )
 
 // TestEvent is a simple event for testing.
@@ -23,10 +22,10 @@ func (e *TestEvent) EventType() string {
 
 // NoopConfig holds configuration settings specific to the Noop module.
 type NoopConfig struct {
-	Enabled bool          `mapstructure:"enabled"`
-	Message string        `mapstructure:"message"`
-	Retries int           `mapstructure:"retries"`
-	Timeout time.Duration `mapstructure:"timeout"`
+	Enabled bool          `mapstructure:"enabled" default:"true"`
+	Message string        `mapstructure:"message" default:"noop"`
+	Retries int           `mapstructure:"retries" default:"3" validate:"min=0,max=10"`
+	Timeout time.Duration `mapstructure:"timeout" default:"5s" validate:"min=1ms"`
 }
 
 // A simple service for testing.
@@ -85,17 +84,17 @@ func (m *NoopModule) OnLoad(ctx context.Context) error {
 	return nil
 }
 
+// ConfigSpec declares the module's typed configuration; the kernel decodes, defaults and
+// validates it before calling Configure.
+func (m *NoopModule) ConfigSpec() interface{} { return &NoopConfig{} }
+
 // Configure is called by the kernel to provide the module with its specific configuration.
 func (m *NoopModule) Configure(cfg interface{}) error {
-	if cfg == nil {
-		return nil
+	noopCfg, ok := cfg.(*NoopConfig)
+	if !ok {
+		return fmt.Errorf("unexpected NoopModule config type %T", cfg)
 	}
-
-	var noopCfg NoopConfig
-	if err := mapstructure.Decode(cfg, &noopCfg); err != nil {
-		return fmt.Errorf("failed to decode NoopModule config: %w", err)
-	}
-	m.config = noopCfg
+	m.config = *noopCfg
 	fmt.Printf("Noop module %s: Kernel invoked Configure with config: %+v\n", m.name, m.config)
 	return nil
 }
@@ -179,10 +178,12 @@ func (m *NoopModule) Stop(ctx context.Context) error {
 	return nil
 }
 
-// OnConfigChanged is called when the application's configuration is reloaded.
-func (m *NoopModule) OnConfigChanged(ctx context.Context, newCfg interface{}) error {
-	fmt.Printf("Noop module %s: Kernel invoked OnConfigChanged.\n", m.name)
-	return m.Configure(newCfg)
+// OnConfigChanged is called when the module's section of the configuration changed.
+func (m *NoopModule) OnConfigChanged(ctx context.Context, change config.ComponentChange) error {
+	for _, c := range change.Changes {
+		fmt.Printf("Noop module %s: config change %s\n", m.name, c)
+	}
+	return m.Configure(change.Config)
 }
 
 // ShutdownTimeout returns the duration to wait for the module to stop gracefully.